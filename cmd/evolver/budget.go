@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/gitops"
+	"github.com/mmrzaf/evolver/internal/pathglob"
+)
+
+// diffStats totals every staged change; files keeps the per-file numbers the
+// budgets are evaluated on.
+type diffStats struct {
+	FilesChanged int
+	LinesChanged int
+	NewFiles     int
+	files        []gitops.FileStat
+}
+
+func newDiffStats(files []gitops.FileStat) diffStats {
+	stats := diffStats{files: files}
+	for _, f := range files {
+		stats.FilesChanged++
+		stats.LinesChanged += f.Added + f.Deleted
+		if f.New {
+			stats.NewFiles++
+		}
+	}
+	return stats
+}
+
+// changedPaths returns the changed files relative to the repository root.
+func (s diffStats) changedPaths() []string {
+	paths := make([]string, 0, len(s.files))
+	for _, f := range s.files {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+func computeAndCheckBudget(cfg *config.Config) (diffStats, error) {
+	slog.Info("computing diff stats")
+	files, err := gitops.FileStats()
+	if err != nil {
+		return diffStats{}, err
+	}
+	stats := newDiffStats(files)
+	slog.Info("diff stats computed", "files_changed", stats.FilesChanged, "lines_changed", stats.LinesChanged, "new_files", stats.NewFiles)
+
+	var exceeded []string
+	for _, c := range budgetChecks(cfg.Budgets, stats) {
+		if c.exceeded() {
+			exceeded = append(exceeded, c.String())
+		}
+	}
+	if len(exceeded) > 0 {
+		slog.Error("budget exceeded; resetting working tree",
+			"files_changed", stats.FilesChanged,
+			"lines_changed", stats.LinesChanged,
+			"new_files", stats.NewFiles,
+			"exceeded", strings.Join(exceeded, "; "),
+		)
+		return stats, fmt.Errorf("budget exceeded: %s", strings.Join(exceeded, "; "))
+	}
+	return stats, nil
+}
+
+// budgetCheck is one limit and how much of it a diff uses. A global limit of 0
+// is enforced (nothing allowed); optional limits of 0 are left out entirely.
+type budgetCheck struct {
+	name  string
+	used  int
+	limit int
+}
+
+func (c budgetCheck) exceeded() bool { return c.used > c.limit }
+
+func (c budgetCheck) String() string {
+	return fmt.Sprintf("%s %d > %d", c.name, c.used, c.limit)
+}
+
+// budgetUsage sums the changes of a group of files.
+type budgetUsage struct {
+	files, added, deleted, newFiles int
+}
+
+func (u *budgetUsage) add(f gitops.FileStat) {
+	u.files++
+	u.added += f.Added
+	u.deleted += f.Deleted
+	if f.New {
+		u.newFiles++
+	}
+}
+
+// checks returns the optional limits of l applied to u, named with prefix.
+func (u budgetUsage) checks(prefix string, l config.BudgetLimits) []budgetCheck {
+	var out []budgetCheck
+	for _, c := range []budgetCheck{
+		{"files", u.files, l.MaxFiles},
+		{"lines", u.added + u.deleted, l.MaxLines},
+		{"added lines", u.added, l.MaxAddedLines},
+		{"deleted lines", u.deleted, l.MaxDeletedLines},
+		{"new files", u.newFiles, l.MaxNewFiles},
+	} {
+		if c.limit > 0 {
+			c.name = prefix + c.name
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// budgetChecks evaluates every configured budget against stats. Files under an
+// unlimited path budget are left out of all other budgets.
+func budgetChecks(b config.Budgets, stats diffStats) []budgetCheck {
+	var total, tests, code budgetUsage
+	byRule := make([]budgetUsage, len(b.Paths))
+	for _, f := range stats.files {
+		if i := pathBudgetIndex(b.Paths, f.Path); i >= 0 {
+			if b.Paths[i].Unlimited {
+				continue
+			}
+			byRule[i].add(f)
+		}
+		total.add(f)
+		if isTestPath(f.Path) {
+			tests.add(f)
+		} else {
+			code.add(f)
+		}
+	}
+
+	checks := []budgetCheck{
+		{"files", total.files, b.MaxFilesChanged},
+		{"lines", total.added + total.deleted, b.MaxLinesChanged},
+		{"new files", total.newFiles, b.MaxNewFiles},
+	}
+	checks = append(checks, total.checks("", config.BudgetLimits{MaxAddedLines: b.MaxAddedLines, MaxDeletedLines: b.MaxDeletedLines})...)
+	checks = append(checks, tests.checks("test ", b.Tests)...)
+	checks = append(checks, code.checks("non-test ", b.Code)...)
+	for i, rule := range b.Paths {
+		if !rule.Unlimited {
+			checks = append(checks, byRule[i].checks(rule.Glob+" ", rule.BudgetLimits)...)
+		}
+	}
+	return checks
+}
+
+func pathBudgetIndex(rules []config.PathBudget, p string) int {
+	for i, rule := range rules {
+		if pathglob.Match(rule.Glob, p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isTestPath reports whether p looks like test code in the common Go, Python
+// and JavaScript layouts.
+func isTestPath(p string) bool {
+	base := path.Base(p)
+	switch {
+	case strings.HasSuffix(base, "_test.go"),
+		strings.HasSuffix(base, "_test.py"),
+		strings.HasPrefix(base, "test_") && strings.HasSuffix(base, ".py"),
+		strings.Contains(base, ".test."),
+		strings.Contains(base, ".spec."):
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(p), "/") {
+		if dir == "test" || dir == "tests" || dir == "__tests__" || dir == "testdata" {
+			return true
+		}
+	}
+	return false
+}
+
+// budgetHeadroom is the smallest remaining fraction of any positive budget limit.
+func budgetHeadroom(b config.Budgets, stats diffStats) float64 {
+	headroom := 1.0
+	for _, c := range budgetChecks(b, stats) {
+		if c.limit <= 0 {
+			continue
+		}
+		if h := 1 - float64(c.used)/float64(c.limit); h < headroom {
+			headroom = h
+		}
+	}
+	return headroom
+}
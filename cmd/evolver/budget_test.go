@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/gitops"
+)
+
+func TestBudgetChecksApplyPathAndCategoryLimits(t *testing.T) {
+	b := config.Budgets{
+		MaxFilesChanged: 3,
+		MaxLinesChanged: 100,
+		MaxNewFiles:     1,
+		MaxDeletedLines: 10,
+		Tests:           config.BudgetLimits{MaxLines: 40},
+		Paths: []config.PathBudget{
+			{Glob: "docs/**", Unlimited: true},
+			{Glob: "internal/core/**", BudgetLimits: config.BudgetLimits{MaxLines: 50}},
+		},
+	}
+	stats := newDiffStats([]gitops.FileStat{
+		{Path: "docs/guide.md", Added: 900, Deleted: 400, New: true},
+		{Path: "docs/more.md", Added: 5, New: true},
+		{Path: "internal/core/core.go", Added: 40, Deleted: 20},
+		{Path: "internal/core/core_test.go", Added: 30, New: true},
+	})
+	if stats.FilesChanged != 4 || stats.LinesChanged != 1395 || stats.NewFiles != 3 {
+		t.Fatalf("unexpected totals: %+v", stats)
+	}
+
+	var exceeded []string
+	for _, c := range budgetChecks(b, stats) {
+		if c.exceeded() {
+			exceeded = append(exceeded, c.String())
+		}
+	}
+	want := "deleted lines 20 > 10; internal/core/** lines 90 > 50"
+	if got := strings.Join(exceeded, "; "); got != want {
+		t.Fatalf("unexpected exceeded budgets:\n got  %s\n want %s", got, want)
+	}
+}
+
+func TestIsTestPath(t *testing.T) {
+	for p, want := range map[string]bool{
+		"pkg/a_test.go":          true,
+		"tests/test_api.py":      true,
+		"src/app.spec.ts":        true,
+		"web/__tests__/x.js":     true,
+		"pkg/a.go":               false,
+		"cmd/contest/main.go":    false,
+		"internal/testutil/x.go": false,
+	} {
+		if got := isTestPath(p); got != want {
+			t.Errorf("isTestPath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
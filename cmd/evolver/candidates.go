@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/mmrzaf/evolver/internal/apply"
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/gitops"
+	"github.com/mmrzaf/evolver/internal/llm/gemini"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/policy"
+	"github.com/mmrzaf/evolver/internal/repair"
+	"github.com/mmrzaf/evolver/internal/repoctx"
+	"github.com/mmrzaf/evolver/internal/security"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+// candidate is one independently generated plan and how it fared when it was
+// applied and verified in an isolated worktree.
+type candidate struct {
+	index    int
+	plan     *plan.Plan
+	err      error
+	empty    bool
+	stats    diffStats
+	budgetOK bool
+	// headroom is the smallest remaining fraction of any budget limit.
+	headroom float64
+	passed   bool
+	score    repair.Score
+}
+
+// generatePlan asks the provider for cfg.Planning.Candidates plans. With more
+// than one, each plan is evaluated in its own git worktree with its own copy
+// of v and the best is returned; the caller applies and verifies it on the
+// main tree with v as usual.
+func generatePlan(cfg *config.Config, repo *repoctx.Context, client *gemini.Client, v *verifier) (*plan.Plan, error) {
+	n := cfg.Planning.Candidates
+	if n <= 1 {
+		return client.GeneratePlan(repo, cfg)
+	}
+
+	var plans []*plan.Plan
+	var lastErr error
+	for i := 0; i < n; i++ {
+		p, err := client.WithSampling(candidateTemperature(i, n), i+1).GeneratePlan(repo, cfg)
+		if err == nil {
+			err = checkPlan(cfg, p)
+		}
+		if err == nil {
+			err = checkRoadmapPlan(repo, p)
+		}
+		if err != nil {
+			slog.Warn("plan candidate rejected", "candidate", i+1, "candidates", n, "error", err)
+			lastErr = err
+			continue
+		}
+		plans = append(plans, precheckPlan(cfg, repo, client, p))
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("no usable plan among %d candidates: %w", n, lastErr)
+	}
+	if len(plans) == 1 {
+		return plans[0], nil
+	}
+
+	// Candidate worktrees start from the current tree, including bootstrap
+	// files that are not committed yet.
+	tree, err := gitops.Checkpoint()
+	if err != nil {
+		return nil, fmt.Errorf("checkpoint before candidate evaluation: %w", err)
+	}
+	cands := make([]candidate, 0, len(plans))
+	for i, p := range plans {
+		c := evaluateCandidate(cfg, v.forCandidate(), tree, i+1, p)
+		slog.Info("plan candidate evaluated",
+			"candidate", c.index,
+			"error", c.err,
+			"passed", c.passed,
+			"budget_ok", c.budgetOK,
+			"files_changed", c.stats.FilesChanged,
+			"lines_changed", c.stats.LinesChanged,
+		)
+		cands = append(cands, c)
+	}
+	best := pickCandidate(cands)
+	slog.Info("plan candidate selected", "candidate", best.index, "candidates", len(cands), "passed", best.passed, "summary", best.plan.Summary)
+	return best.plan, nil
+}
+
+// candidateTemperature spreads sampling temperatures across [0.2, 1.0].
+func candidateTemperature(i, n int) float64 {
+	if n <= 1 {
+		return 0.2
+	}
+	return 0.2 + 0.8*float64(i)/float64(n-1)
+}
+
+func checkPlan(cfg *config.Config, p *plan.Plan) error {
+	if cfg.Security.SecretScan {
+		if err := security.ScanPlan(p); err != nil {
+			return err
+		}
+	}
+	if err := checkChangelogEntry(p); err != nil {
+		return err
+	}
+	return plan.ValidatePaths(p, cfg)
+}
+
+// evaluateCandidate applies p on top of tree in a temporary worktree and
+// verifies it there. The process working directory is restored afterwards.
+func evaluateCandidate(cfg *config.Config, v *verifier, tree string, index int, p *plan.Plan) candidate {
+	c := candidate{index: index, plan: p}
+
+	prefix, err := gitops.Prefix()
+	if err != nil {
+		c.err = err
+		return c
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		c.err = err
+		return c
+	}
+	tmp, err := os.MkdirTemp("", "evolver-candidate-")
+	if err != nil {
+		c.err = err
+		return c
+	}
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	wt := filepath.Join(tmp, "worktree")
+	if err := gitops.AddWorktree(wt); err != nil {
+		c.err = err
+		return c
+	}
+	defer func() {
+		if err := os.Chdir(wd); err != nil {
+			slog.Error("failed to return to workdir after candidate evaluation", "error", err)
+		}
+		if err := gitops.RemoveWorktree(wt); err != nil {
+			slog.Warn("failed to remove candidate worktree", "dir", wt, "error", err)
+		}
+	}()
+
+	if err := os.Chdir(wt); err != nil {
+		c.err = err
+		return c
+	}
+	if err := gitops.Restore(tree); err != nil {
+		c.err = err
+		return c
+	}
+	if err := os.Chdir(filepath.Join(wt, prefix)); err != nil {
+		c.err = err
+		return c
+	}
+
+	v.declaredBreaks = p.BreakingChanges
+	v.changelogPending = p.ChangelogEntry != ""
+	if err := applyPlan(v, p); err != nil {
+		c.err = err
+		return c
+	}
+	stats, berr := computeAndCheckBudget(cfg)
+	c.stats = stats
+	c.empty = stats.FilesChanged == 0 && stats.LinesChanged == 0 && stats.NewFiles == 0
+	c.budgetOK = berr == nil
+	c.headroom = budgetHeadroom(cfg.Budgets, stats)
+	if !c.budgetOK || c.empty {
+		return c
+	}
+
+	report, verr := v.run()
+	var cf *verify.CommandFailureError
+	switch {
+	case verr == nil:
+		c.passed = true
+		c.score = repair.Score{Passed: len(report.Commands)}
+	case errors.As(verr, &cf):
+		c.score = repair.ScoreReport(report, cf.Result)
+	default:
+		c.err = verr
+	}
+	return c
+}
+
+// applyPlan writes and formats the plan's files, then applies its roadmap
+// ops. The changelog entry is only written once the chosen plan verifies.
+func applyPlan(v *verifier, p *plan.Plan) error {
+	written, err := apply.Execute(p)
+	if err != nil {
+		return err
+	}
+	if err := v.format(written); err != nil {
+		return err
+	}
+	return policy.ApplyRoadmap(p.RoadmapOps)
+}
+
+// pickCandidate returns the best evaluated candidate: one that applied, made
+// changes, fit the budget and passed verification, preferring the smaller diff
+// and then the larger budget headroom. Earlier candidates win ties.
+func pickCandidate(cands []candidate) candidate {
+	best := cands[0]
+	for _, c := range cands[1:] {
+		if betterCandidate(c, best) {
+			best = c
+		}
+	}
+	return best
+}
+
+func betterCandidate(a, b candidate) bool {
+	if (a.err == nil) != (b.err == nil) {
+		return a.err == nil
+	}
+	if a.empty != b.empty {
+		return !a.empty
+	}
+	if a.budgetOK != b.budgetOK {
+		return a.budgetOK
+	}
+	if a.passed != b.passed {
+		return a.passed
+	}
+	if !a.passed && a.score != b.score {
+		return a.score.Better(b.score)
+	}
+	if a.stats.LinesChanged != b.stats.LinesChanged {
+		return a.stats.LinesChanged < b.stats.LinesChanged
+	}
+	return a.headroom > b.headroom
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/deps"
+	"github.com/mmrzaf/evolver/internal/gitops"
+	"github.com/mmrzaf/evolver/internal/policy"
+	"github.com/mmrzaf/evolver/internal/repair"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+func TestPickCandidatePrefersPassingThenSmallerDiff(t *testing.T) {
+	cands := []candidate{
+		{index: 1, err: errors.New("apply failed")},
+		{index: 2, budgetOK: true, score: repair.Score{Passed: 1, Severity: 2}, stats: diffStats{FilesChanged: 1, LinesChanged: 5}},
+		{index: 3, budgetOK: true, passed: true, stats: diffStats{FilesChanged: 3, LinesChanged: 80}},
+		{index: 4, budgetOK: true, passed: true, stats: diffStats{FilesChanged: 1, LinesChanged: 20}},
+		{index: 5, budgetOK: true, passed: true, empty: true},
+		{index: 6, passed: false, stats: diffStats{FilesChanged: 1, LinesChanged: 2}},
+	}
+	if got := pickCandidate(cands).index; got != 4 {
+		t.Fatalf("expected smallest passing candidate 4, got %d", got)
+	}
+}
+
+func TestPickCandidateRanksFailuresByScore(t *testing.T) {
+	cands := []candidate{
+		{index: 1, budgetOK: true, score: repair.Score{Passed: 0, Severity: 4, ErrorLines: 3}},
+		{index: 2, budgetOK: true, score: repair.Score{Passed: 1, Severity: 2, ErrorLines: 10}},
+		{index: 3, budgetOK: true, score: repair.Score{Passed: 1, Severity: 2, ErrorLines: 10}},
+	}
+	if got := pickCandidate(cands).index; got != 2 {
+		t.Fatalf("expected highest-scoring earliest candidate 2, got %d", got)
+	}
+}
+
+func TestBudgetHeadroomUsesTightestLimit(t *testing.T) {
+	b := config.Budgets{MaxFilesChanged: 10, MaxLinesChanged: 100, MaxNewFiles: 0}
+	got := budgetHeadroom(b, newDiffStats([]gitops.FileStat{{Path: "a.go", Added: 70, New: true}, {Path: "b.go", Added: 3, Deleted: 2}}))
+	if got < 0.249 || got > 0.251 {
+		t.Fatalf("expected headroom 0.25, got %v", got)
+	}
+	if candidateTemperature(0, 3) != 0.2 || candidateTemperature(2, 3) != 1.0 {
+		t.Fatalf("unexpected temperature spread")
+	}
+}
+
+func TestForCandidateSharesBaselineOnly(t *testing.T) {
+	baseline := &verify.Report{}
+	v := &verifier{baseline: baseline, rules: policy.Rules{ForbidTODO: true}}
+	c := v.forCandidate()
+	c.declaredBreaks = []string{"pkg.Old"}
+	c.depChanges = []deps.Change{{}}
+	c.changelogPending = true
+	c.formatFailure = &verify.CommandResult{}
+	if c.baseline != baseline || !c.rules.ForbidTODO {
+		t.Fatalf("expected the candidate to share the baseline and rules")
+	}
+	if v.declaredBreaks != nil || v.depChanges != nil || v.changelogPending || v.formatFailure != nil {
+		t.Fatalf("candidate evaluation leaked into the run's verifier: %+v", v)
+	}
+}
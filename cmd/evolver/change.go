@@ -0,0 +1,149 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/mmrzaf/evolver/internal/apply"
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/gitops"
+	"github.com/mmrzaf/evolver/internal/llm/gemini"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/policy"
+	"github.com/mmrzaf/evolver/internal/repoctx"
+	"github.com/mmrzaf/evolver/internal/runstate"
+	"github.com/mmrzaf/evolver/internal/security"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+// verifiedChange is a plan applied to the working tree and verified, ready
+// to commit.
+type verifiedChange struct {
+	stats     diffStats
+	report    *verify.Report
+	protected []string
+}
+
+// applyVerifiedPlan is the part of a run shared by evolve and
+// address-review: it validates p, applies it with formatters and roadmap
+// ops, checks budgets, verifies with repair, files the changelog entry and
+// looks for protected files. It returns nil when p changed nothing. A
+// failure after the budget check resets the tree. prechecked skips the Go
+// pre-check for a plan that already had it; recorder may be nil.
+func applyVerifiedPlan(cfg *config.Config, repo *repoctx.Context, client *gemini.Client, p *plan.Plan, prechecked bool, v *verifier, hist *runHistory, recorder *runstate.Recorder) (*verifiedChange, error) {
+	if cfg.Security.SecretScan {
+		if err := logStep("security_scan_plan", func() error { return security.ScanPlan(p) }); err != nil {
+			return nil, err
+		}
+	}
+	if err := logStep("validate_roadmap", func() error { return checkRoadmapPlan(repo, p) }); err != nil {
+		return nil, err
+	}
+	if err := logStep("validate_changelog", func() error { return checkChangelogEntry(p) }); err != nil {
+		return nil, err
+	}
+	if err := logStep("validate_paths", func() error { return plan.ValidatePaths(p, cfg) }); err != nil {
+		return nil, err
+	}
+	if !prechecked {
+		p = precheckPlan(cfg, repo, client, p)
+	}
+	v.declaredBreaks = p.BreakingChanges
+	v.changelogPending = p.ChangelogEntry != ""
+
+	var written []string
+	if err := logStep("apply_plan", func() error {
+		paths, applyErr := apply.Execute(p)
+		written = paths
+		return applyErr
+	}); err != nil {
+		return nil, err
+	}
+	if len(cfg.Formatters) > 0 {
+		if err := logStep("format_files", func() error { return v.format(written) }); err != nil {
+			return nil, err
+		}
+	}
+	if err := logStep("update_roadmap", func() error { return policy.ApplyRoadmap(p.RoadmapOps) }); err != nil {
+		return nil, err
+	}
+
+	stats, err := computeAndCheckBudget(cfg)
+	if err != nil {
+		gitops.ResetHard()
+		return nil, err
+	}
+	if stats.FilesChanged == 0 && stats.LinesChanged == 0 && stats.NewFiles == 0 {
+		return nil, nil
+	}
+
+	var report *verify.Report
+	if err := logStep("verify_with_repair", func() error {
+		r, verr := verifyWithRepair(cfg, repo, client, p, v)
+		report = r
+		return verr
+	}); err != nil {
+		gitops.ResetHard()
+		return nil, err
+	}
+	if flaky := report.FlakyTests(); len(flaky) > 0 {
+		slog.Warn("flaky tests detected", "tests", strings.Join(flaky, ","))
+		if recorder != nil {
+			if err := recorder.RecordFlakyTests(flaky); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := logStep("append_changelog", func() error {
+		return policy.AppendChangelog(p.ChangelogCategory, p.ChangelogEntry, cfg.Mode, commandsOutcome(report))
+	}); err != nil {
+		gitops.ResetHard()
+		return nil, err
+	}
+
+	// Recompute final stats after any repair edits/actions.
+	stats, err = computeAndCheckBudget(cfg)
+	if err != nil {
+		gitops.ResetHard()
+		return nil, err
+	}
+	hist.rec.Diff = &runstate.DiffStats{FilesChanged: stats.FilesChanged, LinesChanged: stats.LinesChanged, NewFiles: stats.NewFiles}
+	hist.rec.Verification = runstate.SummarizeReport(report)
+	hist.rec.Files, _ = gitops.ChangedFiles()
+
+	var protected []string
+	if err := logStep("check_protected", func() error {
+		var perr error
+		protected, perr = checkProtected(cfg)
+		return perr
+	}); err != nil {
+		gitops.ResetHard()
+		return nil, err
+	}
+	return &verifiedChange{stats: stats, report: report, protected: protected}, nil
+}
+
+// captureBaseline verifies the untouched tree when the baseline or the
+// coverage gate needs it, and reports whether verify.on_baseline_failure
+// skips the run.
+func captureBaseline(cfg *config.Config, v *verifier) bool {
+	if !cfg.Verify.Baseline && !cfg.Coverage.Enabled {
+		return false
+	}
+	_ = logStep("verify_baseline", func() error {
+		v.captureBaseline()
+		return nil
+	})
+	failures := v.baseline.Failures()
+	if len(failures) == 0 {
+		return false
+	}
+	slog.Warn("baseline verification is failing on the untouched tree",
+		"failed_commands", len(failures),
+		"first_command", failures[0].Command,
+		"first_kind", failures[0].Kind,
+		"on_baseline_failure", cfg.Verify.OnBaselineFailure,
+	)
+	return cfg.Verify.OnBaselineFailure == "skip"
+}
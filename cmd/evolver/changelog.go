@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/mmrzaf/evolver/internal/changelog"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+// checkChangelogEntry rejects a plan whose changelog entry or category would
+// not fit under Unreleased, or that writes CHANGELOG.md itself.
+func checkChangelogEntry(p *plan.Plan) error {
+	if err := checkChangelogFiles(p); err != nil {
+		return err
+	}
+	_, err := changelog.ParseEntry(p.ChangelogCategory, p.ChangelogEntry)
+	return err
+}
+
+// checkChangelogFiles keeps CHANGELOG.md to categorized entries: plans only
+// change it through changelog_entry.
+func checkChangelogFiles(p *plan.Plan) error {
+	for _, f := range p.Files {
+		if filepath.Clean(f.Path) == "CHANGELOG.md" {
+			return fmt.Errorf("plan edits CHANGELOG.md directly; use changelog_entry")
+		}
+	}
+	return nil
+}
+
+// commandsOutcome is the commands field of a changelog entry.
+func commandsOutcome(report *verify.Report) string {
+	if report == nil || len(report.Commands) == 0 {
+		return "none"
+	}
+	for _, c := range report.Commands {
+		if !c.Passed {
+			return "fail"
+		}
+	}
+	return "pass"
+}
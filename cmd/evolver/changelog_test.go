@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+func TestCommandsOutcome(t *testing.T) {
+	if got := commandsOutcome(nil); got != "none" {
+		t.Fatalf("expected none without a report, got %q", got)
+	}
+	report := &verify.Report{Commands: []verify.CommandResult{{Command: "go vet ./...", Passed: true}, {Command: "go test ./...", Passed: true, Flaky: true}}}
+	if got := commandsOutcome(report); got != "pass" {
+		t.Fatalf("expected pass, got %q", got)
+	}
+	report.Commands[1].Passed = false
+	if got := commandsOutcome(report); got != "fail" {
+		t.Fatalf("expected fail, got %q", got)
+	}
+}
+
+func TestCheckChangelogEntry(t *testing.T) {
+	if err := checkChangelogEntry(&plan.Plan{ChangelogEntry: "- Retry on 503", ChangelogCategory: "Fixed"}); err != nil {
+		t.Fatalf("expected valid entry to pass: %v", err)
+	}
+	if err := checkChangelogEntry(&plan.Plan{ChangelogEntry: "Retry on 503", ChangelogCategory: "Misc"}); err == nil {
+		t.Fatalf("expected unknown category to fail")
+	}
+	if err := checkChangelogEntry(&plan.Plan{Files: []plan.File{{Path: "./CHANGELOG.md", Content: "# Changelog\n"}}}); err == nil {
+		t.Fatalf("expected a direct CHANGELOG.md edit to fail")
+	}
+}
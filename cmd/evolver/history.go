@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/gitops"
+	"github.com/mmrzaf/evolver/internal/llm/gemini"
+	"github.com/mmrzaf/evolver/internal/runstate"
+)
+
+// runHistory builds a run's ledger record as the run progresses; usage and
+// v are attached once the run creates them. The ledger is kept out of git
+// through info/exclude, so it is never staged, counted against budgets or
+// removed by ResetHard.
+type runHistory struct {
+	path      string
+	rec       runstate.Record
+	startedAt time.Time
+	usage     *gemini.Usage
+	v         *verifier
+}
+
+func newRunHistory(cfg *config.Config, trigger string, startedAt time.Time) *runHistory {
+	if trigger == "" {
+		trigger = "run"
+	}
+	h := &runHistory{path: cfg.Reliability.HistoryFile, startedAt: startedAt, rec: runstate.Record{RunID: runID(startedAt), Trigger: trigger, Mode: cfg.Mode, StartedAt: startedAt.UTC()}}
+	h.rec.SHABefore, _ = gitops.Head()
+	if err := gitops.ExcludeLocal(h.path); err != nil {
+		slog.Warn("excluding run history from git failed", "path", h.path, "error", err)
+	}
+	return h
+}
+
+// runID is the GitHub Actions run and attempt, or the start time outside
+// Actions.
+func runID(startedAt time.Time) string {
+	if id := os.Getenv("GITHUB_RUN_ID"); id != "" {
+		if attempt := os.Getenv("GITHUB_RUN_ATTEMPT"); attempt != "" {
+			return id + "-" + attempt
+		}
+		return id
+	}
+	return fmt.Sprintf("local-%s-%d", startedAt.UTC().Format("20060102T150405Z"), os.Getpid())
+}
+
+// finish completes the record and appends it to the ledger. Failing to
+// write history never fails the run.
+func (h *runHistory) finish(res runResult, runErr error) {
+	rec := &h.rec
+	rec.DurationMS = time.Since(h.startedAt).Milliseconds()
+	rec.PRURL = res.prURL
+	if rec.Summary == "" {
+		rec.Summary = res.summary
+	}
+	switch {
+	case runErr != nil:
+		rec.Outcome, rec.Error = "error", runErr.Error()
+	case res.changed:
+		rec.Outcome = "changed"
+		rec.SHAAfter, _ = gitops.Head()
+	case strings.HasPrefix(res.summary, "Skipped"):
+		rec.Outcome = "skipped"
+	default:
+		rec.Outcome = "noop"
+	}
+	if calls, prompt, output, total := h.usage.Snapshot(); calls > 0 {
+		rec.Tokens = &runstate.Tokens{Calls: calls, Prompt: prompt, Output: output, Total: total}
+	}
+	if h.v != nil && h.v.transcript != nil {
+		rec.Repairs = h.v.transcript.Attempts
+	}
+	if err := runstate.AppendHistory(h.path, *rec); err != nil {
+		slog.Warn("writing run history failed", "path", h.path, "error", err)
+	}
+}
+
+// history is the history command: it prints ledger records, newest first.
+func history(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	fs.SetOutput(w)
+	var f runstate.HistoryFilter
+	var since string
+	var asJSON bool
+	fs.StringVar(&f.Outcome, "outcome", "", "only runs with this outcome: changed, noop, skipped or error")
+	fs.StringVar(&f.Trigger, "trigger", "", "only runs whose trigger starts with this, e.g. run or /evolver")
+	fs.StringVar(&f.Kind, "kind", "", "only runs that hit this failure kind in verification or repair")
+	fs.StringVar(&since, "since", "", "only runs started after this date (2006-01-02) or within this duration (72h)")
+	fs.IntVar(&f.Limit, "limit", 20, "show at most this many runs; 0 shows all")
+	fs.BoolVar(&asJSON, "json", false, "print records as JSON lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if since != "" {
+		t, err := parseSince(since, time.Now())
+		if err != nil {
+			return err
+		}
+		f.Since = t
+	}
+
+	cfg := config.Load()
+	if err := os.Chdir(cfg.Workdir); err != nil {
+		return err
+	}
+	recs, err := runstate.ReadHistory(cfg.Reliability.HistoryFile, f)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		enc := json.NewEncoder(w)
+		for _, r := range recs {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return printHistory(w, recs)
+}
+
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -since %q: want a date (2006-01-02) or a duration (72h)", s)
+	}
+	return t, nil
+}
+
+func printHistory(w io.Writer, recs []runstate.Record) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tRUN\tTRIGGER\tOUTCOME\tDIFF\tREPAIRS\tTOKENS\tSUMMARY")
+	for _, r := range recs {
+		diff, tokens := "-", "-"
+		if r.Diff != nil {
+			diff = fmt.Sprintf("%df/%dl", r.Diff.FilesChanged, r.Diff.LinesChanged)
+		}
+		if r.Tokens != nil {
+			tokens = fmt.Sprint(r.Tokens.Total)
+		}
+		summary := r.Summary
+		switch {
+		case r.Error != "":
+			summary = r.Error
+		case r.PRURL != "":
+			summary += " " + r.PRURL
+		}
+		if i := strings.IndexByte(summary, '\n'); i >= 0 {
+			summary = summary[:i]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\n", r.StartedAt.Format("2006-01-02 15:04"), r.RunID, r.Trigger, r.Outcome, diff, len(r.Repairs), tokens, summary)
+	}
+	return tw.Flush()
+}
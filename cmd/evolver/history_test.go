@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/runstate"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	if got, err := parseSince("72h", now); err != nil || !got.Equal(now.Add(-72*time.Hour)) {
+		t.Fatalf("duration: got %v (%v)", got, err)
+	}
+	if got, err := parseSince("2026-03-01", now); err != nil || !got.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("date: got %v (%v)", got, err)
+	}
+	if _, err := parseSince("last week", now); err == nil {
+		t.Fatalf("expected invalid -since to fail")
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	started := time.Date(2026, 3, 4, 12, 30, 0, 0, time.UTC)
+	recs := []runstate.Record{
+		{RunID: "7-1", Trigger: "/evolver retry", Outcome: "error", Error: "verify failed\ndetails", StartedAt: started},
+		{RunID: "6-1", Trigger: "run", Outcome: "changed", Summary: "Add retries", PRURL: "https://example.com/pr/3", StartedAt: started,
+			Diff: &runstate.DiffStats{FilesChanged: 2, LinesChanged: 40}, Tokens: &runstate.Tokens{Total: 1234}},
+	}
+	var b strings.Builder
+	if err := printHistory(&b, recs); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected header and two rows, got:\n%s", b.String())
+	}
+	if f := strings.Fields(lines[1]); strings.Join(f[3:], " ") != "/evolver retry error - 0 - verify failed" {
+		t.Fatalf("unexpected error row: %q", lines[1])
+	}
+	if f := strings.Fields(lines[2]); strings.Join(f[3:], " ") != "run changed 2f/40l 0 1234 Add retries https://example.com/pr/3" {
+		t.Fatalf("unexpected changed row: %q", lines[2])
+	}
+}
@@ -8,8 +8,8 @@ import (
 	"io"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -21,38 +21,115 @@ import (
 	"github.com/mmrzaf/evolver/internal/logging"
 	"github.com/mmrzaf/evolver/internal/plan"
 	"github.com/mmrzaf/evolver/internal/policy"
+	"github.com/mmrzaf/evolver/internal/repair"
 	"github.com/mmrzaf/evolver/internal/repoctx"
 	"github.com/mmrzaf/evolver/internal/runstate"
+	"github.com/mmrzaf/evolver/internal/sandbox"
 	"github.com/mmrzaf/evolver/internal/security"
 	"github.com/mmrzaf/evolver/internal/verify"
 )
 
 func main() {
-	if err := run(); err != nil {
+	var err error
+	command := "run"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+	switch command {
+	case "run":
+		err = run()
+	case "address-review":
+		err = addressReview(os.Args[2:])
+	case "slash-command":
+		err = handleSlashCommand()
+	case "history":
+		err = history(os.Args[2:], os.Stdout)
+	default:
+		err = fmt.Errorf("unknown command %q (want run, address-review, slash-command or history)", command)
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-func run() (err error) {
-	startedAt := time.Now()
-	changed := false
-	summary := ""
-
+// setup loads the configuration and configures logging. The returned
+// function closes the log file.
+func setup() (*config.Config, func() error, error) {
 	cfg := config.Load()
 	if cfg.Workdir != "" && cfg.Workdir != "." && cfg.Logging.File != "" && !filepath.IsAbs(cfg.Logging.File) {
 		cfg.Logging.File = filepath.Join(cfg.Workdir, cfg.Logging.File)
 	}
 	closeLogger, err := logging.Configure(cfg.Logging)
 	if err != nil {
-		return fmt.Errorf("configure logger: %w", err)
+		return nil, nil, fmt.Errorf("configure logger: %w", err)
+	}
+	return cfg, closeLogger, nil
+}
+
+func run() (err error) {
+	cfg, closeLogger, err := setup()
+	if err != nil {
+		return err
 	}
 	defer func() {
 		if closeErr := closeLogger(); err == nil && closeErr != nil {
 			err = closeErr
 		}
 	}()
+	return evolve(cfg, runOptions{})
+}
+
+// runOptions steer a run started by a slash command.
+type runOptions struct {
+	// pr is the evolver pull request to continue instead of looking one up.
+	pr *ghapi.PullRequest
+	// goal replaces repo_goal for this run.
+	goal string
+	// trigger names what started the run in the history ledger; empty
+	// means run.
+	trigger string
+	// result, when set, receives the outcome of the run.
+	result *runResult
+}
+
+// runResult is the outcome of a run, as written to the action outputs.
+type runResult struct {
+	changed bool
+	summary string
+	prURL   string
+}
+
+// enterWorkdir changes to cfg.Workdir and takes the run lock. Every command
+// that touches the working tree does this before checking anything out.
+func enterWorkdir(cfg *config.Config) (unlock func(), err error) {
+	if err := logStep("change_workdir", func() error { return os.Chdir(cfg.Workdir) }); err != nil {
+		return nil, err
+	}
+	if err := logStep("acquire_lock", func() error {
+		lockFn, lockErr := runstate.AcquireLock(cfg.Reliability.LockFile, time.Duration(cfg.Reliability.LockStaleMinutes)*time.Minute)
+		if lockErr != nil {
+			return lockErr
+		}
+		unlock = lockFn
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// evolve is one run: plan, apply, verify, commit and push or open a pull
+// request.
+func evolve(cfg *config.Config, opts runOptions) (err error) {
+	startedAt := time.Now()
+	changed := false
+	summary := ""
+	prURL := ""
 
+	if opts.goal != "" {
+		cfg.RepoGoal = opts.goal
+	}
 	slog.Info("evolver run started",
 		"provider", cfg.Provider,
 		"mode", cfg.Mode,
@@ -60,6 +137,9 @@ func run() (err error) {
 		"workdir", cfg.Workdir,
 	)
 	defer func() {
+		if opts.result != nil {
+			*opts.result = runResult{changed: changed, summary: summary, prURL: prURL}
+		}
 		fields := []any{
 			"changed", changed,
 			"summary", summary,
@@ -73,25 +153,85 @@ func run() (err error) {
 		slog.Info("evolver run finished", fields...)
 	}()
 
-	if err := logStep("change_workdir", func() error { return os.Chdir(cfg.Workdir) }); err != nil {
+	unlock, err := enterWorkdir(cfg)
+	if err != nil {
 		return err
 	}
-	if err := logStep("policy_bootstrap", func() error { return policy.Bootstrap(cfg) }); err != nil {
-		return err
+	defer unlock()
+
+	// The history record is written under the lock, after the run's commit
+	// and push, so it carries the final SHA and pull request URL.
+	hist := newRunHistory(cfg, opts.trigger, startedAt)
+	defer func() {
+		hist.finish(runResult{changed: changed, summary: summary, prURL: prURL}, err)
+	}()
+
+	// In pr mode, an open evolver pull request is continued (pr.strategy
+	// update) or counted against pr.max_open before anything is written.
+	existing := opts.pr
+	if existing == nil && cfg.Mode == "pr" && (cfg.PR.Strategy == "update" || cfg.PR.MaxOpen > 0) {
+		var skip bool
+		if err := logStep("find_open_pull_requests", func() error {
+			var findErr error
+			existing, skip, findErr = findOpenPullRequest(cfg.PR)
+			return findErr
+		}); err != nil {
+			return err
+		}
+		if skip {
+			summary = fmt.Sprintf("Skipped: %d or more evolver pull requests are open", cfg.PR.MaxOpen)
+			slog.Info("run skipped", "summary", summary)
+			setOutput("changed", "false")
+			setOutput("summary", summary)
+			return nil
+		}
+	}
+	// lease is the fetched head of an existing pull request branch; a
+	// rebased branch is only force-pushed while origin is still there.
+	rebased, lease := false, ""
+	if existing != nil {
+		if err := logStep("git_checkout_existing_branch", func() error { return gitops.CheckoutRemote(existing.Head.Ref) }); err != nil {
+			return err
+		}
+		// A branch that no longer rebases cleanly is continued as-is; the
+		// pull request then shows the conflict for a human to resolve.
+		lease, err = gitops.Head()
+		if err != nil {
+			return err
+		}
+		if err := logStep("git_rebase_existing_branch", func() error { return gitops.RebaseOnto(ghapi.DefaultBranch()) }); err != nil {
+			slog.Warn("continuing open pull request without rebase", "error", err)
+		}
+		after, _ := gitops.Head()
+		rebased = after != lease
+		hist.rec.SHABefore = after
+		slog.Info("continuing open pull request", "number", existing.Number, "branch", existing.Head.Ref, "rebased", rebased)
 	}
 
-	var unlock func()
-	if err := logStep("acquire_lock", func() error {
-		lockFn, lockErr := runstate.AcquireLock(cfg.Reliability.LockFile, time.Duration(cfg.Reliability.LockStaleMinutes)*time.Minute)
-		if lockErr != nil {
-			return lockErr
+	var task *repoctx.Task
+	if cfg.Tasks.Source == "issues" {
+		if err := logStep("select_issue_task", func() error {
+			var taskErr error
+			task, taskErr = selectIssueTask(cfg)
+			return taskErr
+		}); err != nil {
+			return err
 		}
-		unlock = lockFn
-		return nil
-	}); err != nil {
+		if task == nil {
+			summary = fmt.Sprintf("Skipped: no open issues labeled %s", cfg.Tasks.Label)
+			slog.Info("run skipped", "summary", summary)
+			setOutput("changed", "false")
+			setOutput("summary", summary)
+			return nil
+		}
+		slog.Info("working on issue", "issue", task.Issue, "title", task.Title)
+		hist.rec.Issue = task.Issue
+		defer func() { reportIssueOutcome(task, runResult{changed: changed, summary: summary, prURL: prURL}, err) }()
+	}
+
+	if err := logStep("policy_bootstrap", func() error { return policy.Bootstrap(cfg) }); err != nil {
 		return err
 	}
-	defer unlock()
 
 	var recorder *runstate.Recorder
 	if err := logStep("init_runstate_recorder", func() error {
@@ -114,6 +254,16 @@ func run() (err error) {
 		}
 	}()
 
+	v := newVerifier(cfg)
+	hist.v = v
+	if captureBaseline(cfg, v) {
+		summary = "Skipped: baseline verification is failing"
+		slog.Info("run skipped", "summary", summary)
+		setOutput("changed", "false")
+		setOutput("summary", summary)
+		return nil
+	}
+
 	var repo *repoctx.Context
 	if err := logStep("gather_repo_context", func() error {
 		repoContext, gatherErr := repoctx.Gather(cfg)
@@ -121,10 +271,14 @@ func run() (err error) {
 			return gatherErr
 		}
 		repo = repoContext
+		repo.Task = task
 		return nil
 	}); err != nil {
 		return err
 	}
+	if repo.Rules != nil {
+		v.rules = *repo.Rules
+	}
 	slog.Info("repository context ready", "files", len(repo.Files), "excerpts", len(repo.Excerpts))
 
 	var (
@@ -135,8 +289,9 @@ func run() (err error) {
 	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
 	case "", "gemini":
 		client = gemini.NewClient(os.Getenv("GEMINI_API_KEY"), cfg.Model)
+		hist.usage = client.Usage
 		if err := logStep("generate_plan_gemini", func() error {
-			planResult, planErr := client.GeneratePlan(repo, cfg)
+			planResult, planErr := generatePlan(cfg, repo, client, v)
 			if planErr != nil {
 				return planErr
 			}
@@ -148,10 +303,11 @@ func run() (err error) {
 	default:
 		return fmt.Errorf("unsupported provider: %s", cfg.Provider)
 	}
-	slog.Info("plan generated", "files", len(p.Files), "has_changelog", p.ChangelogEntry != "", "has_roadmap_update", p.RoadmapUpdate != "")
+	slog.Info("plan generated", "files", len(p.Files), "has_changelog", p.ChangelogEntry != "", "roadmap_item", p.RoadmapItem, "roadmap_ops", len(p.RoadmapOps))
+	hist.rec.Summary, hist.rec.RoadmapItem = p.Summary, p.RoadmapItem
 
 	// If the LLM proposes no changes, we still might have bootstrap changes to commit.
-	if len(p.Files) == 0 && p.ChangelogEntry == "" && p.RoadmapUpdate == "" {
+	if len(p.Files) == 0 && p.ChangelogEntry == "" && len(p.RoadmapOps) == 0 {
 		slog.Info("plan proposed no direct file changes")
 		dirty, derr := gitops.HasChanges()
 		if derr == nil && !dirty {
@@ -166,40 +322,25 @@ func run() (err error) {
 		}
 	}
 
-	if cfg.Security.SecretScan {
-		if err := logStep("security_scan_plan", func() error { return security.ScanPlan(p) }); err != nil {
-			return err
-		}
-	}
-	if err := logStep("validate_paths", func() error { return plan.ValidatePaths(p, cfg) }); err != nil {
-		return err
-	}
-
-	branchName := fmt.Sprintf("evolve/%s", time.Now().Format("2006-01-02-150405"))
-	if cfg.Mode == "pr" {
+	branchName := cfg.PR.BranchPrefix + time.Now().Format("2006-01-02-150405")
+	if existing != nil {
+		branchName = existing.Head.Ref
+	} else if cfg.Mode == "pr" {
 		if err := logStep("git_checkout_branch", func() error { return gitops.CheckoutNew(branchName) }); err != nil {
 			return err
 		}
 	}
-
-	if err := logStep("apply_plan", func() error { return apply.Execute(p) }); err != nil {
-		return err
-	}
-	if err := logStep("append_changelog", func() error { return policy.AppendChangelog(p.ChangelogEntry) }); err != nil {
-		return err
-	}
-	if p.RoadmapUpdate != "" {
-		if err := logStep("update_roadmap", func() error { return policy.UpdateRoadmap(p.RoadmapUpdate) }); err != nil {
-			return err
-		}
+	if cfg.Mode == "pr" {
+		hist.rec.Branch = branchName
 	}
 
-	stats, err := computeAndCheckBudget(cfg)
+	// Candidates are pre-checked before evaluation; the winner is not
+	// checked again.
+	ch, err := applyVerifiedPlan(cfg, repo, client, p, cfg.Planning.Candidates > 1, v, hist, recorder)
 	if err != nil {
-		gitops.ResetHard()
 		return err
 	}
-	if stats.FilesChanged == 0 && stats.LinesChanged == 0 && stats.NewFiles == 0 {
+	if ch == nil {
 		summary = "No changes produced"
 		slog.Info("run ended with no produced changes", "summary", summary)
 		setOutput("changed", "false")
@@ -207,44 +348,38 @@ func run() (err error) {
 		return nil
 	}
 
-	if err := logStep("verify_with_repair", func() error {
-		return verifyWithRepair(cfg, repo, client, p)
-	}); err != nil {
-		gitops.ResetHard()
-		return err
-	}
-
-	// Recompute final stats after any repair edits/actions.
-	stats, err = computeAndCheckBudget(cfg)
-	if err != nil {
-		gitops.ResetHard()
-		return err
-	}
-
 	if strings.TrimSpace(p.Summary) == "" {
 		p.Summary = "evolver changes"
 	}
-	if err := logStep("git_commit", func() error { return gitops.Commit(p.Summary) }); err != nil {
+	message := p.Summary
+	if task != nil {
+		message += "\n\n" + closesLine(task)
+	}
+	if err := logStep("git_commit", func() error { return gitops.Commit(message) }); err != nil {
 		return err
 	}
 
 	if cfg.Mode == "pr" {
-		if err := logStep("git_push_branch", func() error { return gitops.Push(branchName) }); err != nil {
+		push := func() error { return gitops.Push(branchName) }
+		if rebased {
+			push = func() error { return gitops.ForcePush(branchName, lease) }
+		}
+		if err := logStep("git_push_branch", push); err != nil {
 			return err
 		}
-		var url string
 		if err := logStep("create_pull_request", func() error {
-			prURL, prErr := ghapi.CreatePR(branchName, p.Summary, generatePRBody(p, stats.FilesChanged, stats.LinesChanged, stats.NewFiles))
-			if prErr != nil {
-				return prErr
+			var prErr error
+			if existing != nil {
+				prURL, prErr = updatePullRequest(cfg, existing, p, ch.stats, ch.report, v, ch.protected, task)
+			} else {
+				prURL, prErr = openPullRequest(cfg, branchName, p, ch.stats, ch.report, v, ch.protected, task)
 			}
-			url = prURL
-			return nil
+			return prErr
 		}); err != nil {
 			return err
 		}
-		slog.Info("pull request created", "url", url)
-		setOutput("pr_url", url)
+		slog.Info("pull request created", "url", prURL)
+		setOutput("pr_url", prURL)
 	} else {
 		if err := logStep("git_push_head", func() error { return gitops.Push("HEAD") }); err != nil {
 			return err
@@ -258,63 +393,75 @@ func run() (err error) {
 	return nil
 }
 
-type diffStats struct {
-	FilesChanged int
-	LinesChanged int
-	NewFiles     int
-}
-
-func computeAndCheckBudget(cfg *config.Config) (diffStats, error) {
-	slog.Info("computing diff stats")
-	filesChanged, linesChanged, err := gitops.DiffStats()
-	if err != nil {
-		return diffStats{}, err
-	}
-	newFiles, err := gitops.NewFilesCount()
-	if err != nil {
-		return diffStats{}, err
-	}
-	stats := diffStats{FilesChanged: filesChanged, LinesChanged: linesChanged, NewFiles: newFiles}
-	slog.Info("diff stats computed", "files_changed", stats.FilesChanged, "lines_changed", stats.LinesChanged, "new_files", stats.NewFiles)
-
-	if stats.FilesChanged > cfg.Budgets.MaxFilesChanged || stats.LinesChanged > cfg.Budgets.MaxLinesChanged || stats.NewFiles > cfg.Budgets.MaxNewFiles {
-		slog.Error("budget exceeded; resetting working tree",
-			"files_changed", stats.FilesChanged,
-			"lines_changed", stats.LinesChanged,
-			"new_files", stats.NewFiles,
-			"max_files", cfg.Budgets.MaxFilesChanged,
-			"max_lines", cfg.Budgets.MaxLinesChanged,
-			"max_new_files", cfg.Budgets.MaxNewFiles,
-		)
-		return stats, fmt.Errorf("budget exceeded: %d files, %d lines, %d new files", stats.FilesChanged, stats.LinesChanged, stats.NewFiles)
-	}
-	return stats, nil
-}
-
-func verifyWithRepair(cfg *config.Config, repo *repoctx.Context, client *gemini.Client, rootPlan *plan.Plan) error {
+func verifyWithRepair(cfg *config.Config, repo *repoctx.Context, client *gemini.Client, rootPlan *plan.Plan, v *verifier) (*verify.Report, error) {
 	maxAttempts := cfg.Repair.MaxAttempts
 	if maxAttempts <= 0 {
 		maxAttempts = 2
 	}
 
+	// transcript remembers earlier attempts; pending is the attempt whose
+	// outcome the next verification run reveals, and lastFailure what it tried to fix.
+	transcript := &repair.Transcript{}
+	v.transcript = transcript
+	var pending *repair.Attempt
+	var lastFailure *verify.CommandResult
+	// best is the highest-scoring tree seen so far; attempts that score
+	// worse are rolled back to it before the next repair.
+	var best *checkpoint
+
 	for attempt := 0; ; attempt++ {
-		report, err := verify.RunCommandsReport(cfg.Commands)
-		if err == nil {
-			return nil
-		}
+		report, err := v.run()
 
 		var cf *verify.CommandFailureError
-		if !errors.As(err, &cf) {
-			return err
+		if err != nil && !errors.As(err, &cf) {
+			return report, err
+		}
+		if pending != nil {
+			var result *verify.CommandResult
+			if cf != nil {
+				result = &cf.Result
+			}
+			transcript.Record(*pending, lastFailure, result)
+			pending = nil
 		}
+		if err == nil {
+			return report, nil
+		}
+
 		failure := cf.Result
+		score := repair.ScoreReport(report, failure)
+		rolledBack := false
+		if best != nil && best.score.Better(score) {
+			slog.Warn("repair attempt regressed; rolling back to best checkpoint",
+				"attempt", attempt,
+				"command", failure.Command,
+				"kind", failure.Kind,
+				"best_kind", best.failure.Kind,
+			)
+			if rerr := gitops.Restore(best.tree); rerr != nil {
+				return report, fmt.Errorf("repair rollback failed: %w", rerr)
+			}
+			transcript.MarkRolledBack()
+			report, failure, err = best.report, best.failure, best.err
+			v.declaredBreaks = best.declaredBreaks
+			rolledBack = true
+		}
+		lastFailure = &failure
+		if transcript.Stalled() {
+			slog.Error("repair stalled: consecutive attempts produced an identical failure",
+				"attempt", attempt,
+				"command", failure.Command,
+				"kind", failure.Kind,
+			)
+			return report, fmt.Errorf("repair made no progress after %d attempts: %w", attempt, err)
+		}
 		if isTerminalVerifyFailure(failure.Kind) {
 			slog.Error("verification failed with terminal kind; not attempting repair",
 				"command", failure.Command,
 				"exit_code", failure.ExitCode,
 				"kind", failure.Kind,
 			)
-			return err
+			return report, err
 		}
 		if attempt >= maxAttempts {
 			slog.Error("verification failed and repair budget exhausted",
@@ -323,12 +470,20 @@ func verifyWithRepair(cfg *config.Config, repo *repoctx.Context, client *gemini.
 				"command", failure.Command,
 				"kind", failure.Kind,
 			)
-			return err
+			return report, err
 		}
 		if client == nil {
-			return err
+			return report, err
+		}
+		if !rolledBack {
+			tree, cerr := gitops.Checkpoint()
+			if cerr != nil {
+				return report, fmt.Errorf("repair checkpoint failed: %w", cerr)
+			}
+			best = &checkpoint{tree: tree, score: score, report: report, failure: failure, err: err, declaredBreaks: slices.Clone(v.declaredBreaks)}
 		}
 
+		v.repairedKinds = append(v.repairedKinds, failure.Kind)
 		slog.Warn("verification failed; starting repair attempt",
 			"attempt", attempt+1,
 			"max_attempts", maxAttempts,
@@ -342,41 +497,114 @@ func verifyWithRepair(cfg *config.Config, repo *repoctx.Context, client *gemini.
 
 		repairRepo := repo
 		if freshRepo, gerr := repoctx.Gather(cfg); gerr == nil {
+			freshRepo.Task = repo.Task
 			repairRepo = freshRepo
 		} else {
 			slog.Warn("repair context refresh failed; using initial context", "error", gerr)
 		}
 
-		repairPlan, rerr := client.GenerateRepairPlan(repairRepo, cfg, rootPlan.Summary, repairFailureContext, allowedCaps)
+		repairPlan, rerr := client.GenerateRepairPlan(repairRepo, cfg, rootPlan.Summary, repairFailureContext, allowedCaps, transcript.Attempts)
 		if rerr != nil {
-			return fmt.Errorf("repair generation failed (attempt %d/%d): %w", attempt+1, maxAttempts, rerr)
+			return report, fmt.Errorf("repair generation failed (attempt %d/%d): %w", attempt+1, maxAttempts, rerr)
 		}
 		slog.Info("repair plan generated", "attempt", attempt+1, "files", len(repairPlan.Files), "repair_actions", len(repairPlan.RepairActions))
 
 		if cfg.Security.SecretScan {
 			if err := security.ScanPlan(repairPlan); err != nil {
-				return fmt.Errorf("repair plan secret scan failed: %w", err)
+				return report, fmt.Errorf("repair plan secret scan failed: %w", err)
 			}
 		}
 		if err := plan.ValidatePaths(repairPlan, cfg); err != nil {
-			return fmt.Errorf("repair plan path validation failed: %w", err)
+			return report, fmt.Errorf("repair plan path validation failed: %w", err)
+		}
+		if err := checkChangelogFiles(repairPlan); err != nil {
+			return report, fmt.Errorf("repair plan changelog validation failed: %w", err)
+		}
+		// Repairs serve the root plan's roadmap item; their ops are not applied.
+		if repairPlan.RoadmapItem == "" {
+			repairPlan.RoadmapItem = rootPlan.RoadmapItem
+		}
+		if err := checkRoadmapPlan(repo, repairPlan); err != nil {
+			return report, fmt.Errorf("repair plan roadmap validation failed: %w", err)
+		}
+		repairPlan = precheckPlan(cfg, repo, client, repairPlan)
+		v.declaredBreaks = append(v.declaredBreaks, repairPlan.BreakingChanges...)
+		// A repair for require_changelog supplies the entry the plan lacked.
+		if rootPlan.ChangelogEntry == "" && repairPlan.ChangelogEntry != "" && checkChangelogEntry(repairPlan) == nil {
+			rootPlan.ChangelogEntry, rootPlan.ChangelogCategory = repairPlan.ChangelogEntry, repairPlan.ChangelogCategory
+			v.changelogPending = true
+		}
+
+		pending = &repair.Attempt{
+			Number:  attempt + 1,
+			Summary: repairPlan.Summary,
+		}
+		for _, a := range repairPlan.RepairActions {
+			pending.Actions = append(pending.Actions, a.String())
+		}
+		for _, f := range repairPlan.Files {
+			pending.Files = append(pending.Files, f.Path)
 		}
 
-		if err := apply.Execute(repairPlan); err != nil {
-			return fmt.Errorf("repair apply failed: %w", err)
+		written, err := apply.Execute(repairPlan)
+		if err != nil {
+			return report, fmt.Errorf("repair apply failed: %w", err)
+		}
+		if err := v.format(written); err != nil {
+			return report, fmt.Errorf("repair formatting failed: %w", err)
 		}
 		if strings.TrimSpace(repairPlan.Summary) != "" {
 			rootPlan.Summary = repairPlan.Summary
 		}
 
 		if err := executeRepairActions(cfg, repairPlan.RepairActions, allowedCaps); err != nil {
-			return fmt.Errorf("repair action failed: %w", err)
+			return report, fmt.Errorf("repair action failed: %w", err)
 		}
 
 		if _, err := computeAndCheckBudget(cfg); err != nil {
-			return err
+			return report, err
+		}
+	}
+}
+
+// checkpoint is a snapshot of the working tree together with the
+// verification outcome observed on it and the breaking changes declared by
+// the plans that produced it.
+type checkpoint struct {
+	tree           string
+	score          repair.Score
+	report         *verify.Report
+	failure        verify.CommandResult
+	err            error
+	declaredBreaks []string
+}
+
+func verifyOptions(cfg *config.Config) verify.Options {
+	return verify.Options{Retries: cfg.Verify.Retries, MaxParallel: cfg.Verify.MaxParallel, Sandbox: sandboxPolicy(cfg)}
+}
+
+// sandboxPolicy builds the isolation policy for untrusted commands. The
+// repository root and the Go caches stay writable; network access is
+// granted per command.
+func sandboxPolicy(cfg *config.Config) sandbox.Policy {
+	if !cfg.Sandbox.Enabled {
+		return sandbox.Policy{}
+	}
+	root, err := gitops.TopLevel()
+	if err != nil {
+		if root, err = os.Getwd(); err != nil {
+			root = "."
 		}
 	}
+	return sandbox.Policy{
+		Enabled:        true,
+		Backend:        cfg.Sandbox.Backend,
+		Root:           root,
+		WritablePaths:  append(sandbox.GoCacheDirs(), cfg.Sandbox.WritablePaths...),
+		CPUSeconds:     cfg.Sandbox.CPUSeconds,
+		MemoryMB:       cfg.Sandbox.MemoryMB,
+		MaxOutputBytes: cfg.Sandbox.MaxOutputBytes,
+	}
 }
 
 func isTerminalVerifyFailure(kind string) bool {
@@ -447,16 +675,16 @@ func filterRepairCapabilities(all []config.RepairCapability, failureKind string)
 	return out
 }
 
-func executeRepairActions(cfg *config.Config, actionIDs []string, allowed []config.RepairCapability) error {
-	if len(actionIDs) == 0 {
+func executeRepairActions(cfg *config.Config, actions []plan.RepairAction, allowed []config.RepairCapability) error {
+	if len(actions) == 0 {
 		return nil
 	}
 	maxActions := cfg.Repair.MaxActionsPerAttempt
 	if maxActions <= 0 {
 		maxActions = 2
 	}
-	if len(actionIDs) > maxActions {
-		return fmt.Errorf("too many repair actions requested: %d > %d", len(actionIDs), maxActions)
+	if len(actions) > maxActions {
+		return fmt.Errorf("too many repair actions requested: %d > %d", len(actions), maxActions)
 	}
 
 	capsByID := make(map[string]config.RepairCapability, len(allowed))
@@ -470,9 +698,16 @@ func executeRepairActions(cfg *config.Config, actionIDs []string, allowed []conf
 		capsByID[c.ID] = c
 	}
 
+	// Resolve every action before running any, so a bad argument on a later
+	// action does not leave the tree half-repaired.
+	type resolved struct {
+		cap  config.RepairCapability
+		argv []string
+	}
+	runs := make([]resolved, 0, len(actions))
 	runCounts := make(map[string]int)
-	for i, rawID := range actionIDs {
-		id := strings.TrimSpace(rawID)
+	for i, a := range actions {
+		id := strings.TrimSpace(a.ID)
 		if id == "" {
 			return fmt.Errorf("repair action %d has empty id", i)
 		}
@@ -484,15 +719,24 @@ func executeRepairActions(cfg *config.Config, actionIDs []string, allowed []conf
 		if cap.MaxRunsPerAttempt > 0 && runCounts[id] > cap.MaxRunsPerAttempt {
 			return fmt.Errorf("repair action %q exceeded max_runs_per_attempt (%d)", id, cap.MaxRunsPerAttempt)
 		}
-		if err := runRepairCapability(cap); err != nil {
-			return fmt.Errorf("%s: %w", id, err)
+		argv, err := plan.ResolveArgv(a, cap)
+		if err != nil {
+			return fmt.Errorf("repair action %q rejected: %w", id, err)
+		}
+		runs = append(runs, resolved{cap: cap, argv: argv})
+	}
+	for _, r := range runs {
+		if err := runRepairCapability(cfg, r.cap, r.argv); err != nil {
+			return fmt.Errorf("%s: %w", r.cap.ID, err)
 		}
 	}
 	return nil
 }
 
-func runRepairCapability(cap config.RepairCapability) error {
-	if len(cap.Argv) == 0 {
+// runRepairCapability runs argv, the capability's argv with action arguments
+// substituted, under the capability's cwd, timeout and network settings.
+func runRepairCapability(cfg *config.Config, cap config.RepairCapability, argv []string) error {
+	if len(argv) == 0 {
 		return fmt.Errorf("empty argv")
 	}
 	cwd, err := resolveSafeCapabilityCwd(cap.Cwd)
@@ -502,19 +746,21 @@ func runRepairCapability(cap config.RepairCapability) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cap.TimeoutSeconds)*time.Second)
 	defer cancel()
 
-	cmd := exec.CommandContext(ctx, cap.Argv[0], cap.Argv[1:]...)
-	if cwd != "" {
-		cmd.Dir = cwd
+	policy := sandboxPolicy(cfg)
+	policy.AllowNetwork = cap.AllowNetwork
+	cmd, err := sandbox.Command(ctx, policy, cwd, argv)
+	if err != nil {
+		return err
 	}
 
 	var stdoutBuf bytes.Buffer
 	var stderrBuf bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
-	cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+	cmd.Stdout = sandbox.LimitOutput(policy, io.MultiWriter(os.Stdout, &stdoutBuf))
+	cmd.Stderr = sandbox.LimitOutput(policy, io.MultiWriter(os.Stderr, &stderrBuf))
 
-	display := strings.Join(cap.Argv, " ")
+	display := strings.Join(argv, " ")
 	startedAt := time.Now()
-	slog.Info("repair capability command started", "id", cap.ID, "command", display, "cwd", valueOrDot(cwd), "timeout_seconds", cap.TimeoutSeconds)
+	slog.Info("repair capability command started", "id", cap.ID, "command", display, "cwd", valueOrDot(cwd), "timeout_seconds", cap.TimeoutSeconds, "sandboxed", policy.Enabled)
 	runErr := cmd.Run()
 	durMS := time.Since(startedAt).Milliseconds()
 
@@ -571,8 +817,62 @@ func logStep(name string, fn func() error) error {
 	return nil
 }
 
-func generatePRBody(p *plan.Plan, filesChanged, linesChanged, newFiles int) string {
-	return fmt.Sprintf("## Summary\n%s\n\n## Stats\n- Files changed: %d\n- Lines changed: %d\n- New files: %d\n\n## Roadmap Update\n%s\n", p.Summary, filesChanged, linesChanged, newFiles, p.RoadmapUpdate)
+func generatePRBody(p *plan.Plan, stats diffStats, report *verify.Report, v *verifier, protected []string, task *repoctx.Task) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Summary\n%s\n", p.Summary)
+	if task != nil {
+		fmt.Fprintf(&b, "\n%s\n", closesLine(task))
+	}
+	fmt.Fprintf(&b, "\n## Stats\n- Files changed: %d\n- Lines changed: %d\n- New files: %d\n", stats.FilesChanged, stats.LinesChanged, stats.NewFiles)
+	if flaky := report.FlakyTests(); len(flaky) > 0 {
+		b.WriteString("\n## Flaky tests\nThese tests failed during verification and passed on rerun; they were not repaired:\n")
+		for _, t := range flaky {
+			fmt.Fprintf(&b, "- `%s`\n", t)
+		}
+	}
+	if v == nil {
+		v = &verifier{}
+	}
+	if cov := v.coverageResult; cov != nil {
+		fmt.Fprintf(&b, "\n## Coverage\n- Total: %.2f%% -> %.2f%%\n", cov.TotalBefore, cov.TotalAfter)
+		for _, w := range cov.Warnings {
+			fmt.Fprintf(&b, "- Warning: %s\n", w)
+		}
+	}
+	if len(p.BreakingChanges) > 0 {
+		b.WriteString("\n## Breaking API changes\nThis plan declares these exported API changes as intentional:\n")
+		for _, sym := range p.BreakingChanges {
+			fmt.Fprintf(&b, "- `%s`\n", sym)
+		}
+	}
+	if len(v.depChanges) > 0 {
+		b.WriteString("\n## Dependency changes\n")
+		for _, c := range v.depChanges {
+			fmt.Fprintf(&b, "- %s\n", c)
+		}
+	}
+	if len(v.depApproval) > 0 {
+		b.WriteString("\n## Dependency approval required\nThese changes violate the dependency policy and need a reviewer's approval:\n")
+		for _, s := range v.depApproval {
+			fmt.Fprintf(&b, "- %s\n", s)
+		}
+	}
+	if len(protected) > 0 {
+		b.WriteString("\n## Protected files\nThese files are protected and need review from their code owners:\n")
+		for _, f := range protected {
+			fmt.Fprintf(&b, "- `%s`\n", f)
+		}
+	}
+	if p.RoadmapItem != "" || len(p.RoadmapOps) > 0 {
+		b.WriteString("\n## Roadmap\n")
+		if p.RoadmapItem != "" {
+			fmt.Fprintf(&b, "Roadmap item: `%s`\n", p.RoadmapItem)
+		}
+		for _, op := range p.RoadmapOps {
+			fmt.Fprintf(&b, "- %s\n", op)
+		}
+	}
+	return b.String()
 }
 
 func setOutput(key, value string) {
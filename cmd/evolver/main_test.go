@@ -6,15 +6,20 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/mmrzaf/evolver/internal/coverage"
+	"github.com/mmrzaf/evolver/internal/deps"
 	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/roadmap"
+	"github.com/mmrzaf/evolver/internal/verify"
 )
 
 func TestGeneratePRBodyIncludesCoreSections(t *testing.T) {
 	p := &plan.Plan{
-		Summary:       "Improve retry logic",
-		RoadmapUpdate: "- [x] Added backoff",
+		Summary:     "Improve retry logic",
+		RoadmapItem: "R3",
+		RoadmapOps:  []roadmap.Op{{Op: "check", ID: "R3"}, {Op: "add", Text: "Retry metrics", Section: "next"}},
 	}
-	body := generatePRBody(p, 3, 42, 1)
+	body := generatePRBody(p, diffStats{FilesChanged: 3, LinesChanged: 42, NewFiles: 1}, nil, nil, nil, nil)
 
 	mustContain := []string{
 		"## Summary",
@@ -22,8 +27,10 @@ func TestGeneratePRBodyIncludesCoreSections(t *testing.T) {
 		"- Files changed: 3",
 		"- Lines changed: 42",
 		"- New files: 1",
-		"## Roadmap Update",
-		"- [x] Added backoff",
+		"## Roadmap",
+		"Roadmap item: `R3`",
+		"- check R3",
+		"- add to next: Retry metrics",
 	}
 	for _, s := range mustContain {
 		if !strings.Contains(body, s) {
@@ -32,6 +39,42 @@ func TestGeneratePRBodyIncludesCoreSections(t *testing.T) {
 	}
 }
 
+func TestGeneratePRBodyListsFlakyTests(t *testing.T) {
+	p := &plan.Plan{Summary: "Tidy"}
+	report := &verify.Report{Commands: []verify.CommandResult{
+		{Command: "go test ./...", Passed: true, Flaky: true, FlakyTests: []string{"TestRetry"}},
+	}}
+	body := generatePRBody(p, diffStats{}, report, nil, nil, nil)
+	if !strings.Contains(body, "## Flaky tests") || !strings.Contains(body, "`TestRetry`") {
+		t.Fatalf("expected flaky tests section, got %q", body)
+	}
+	if strings.Contains(generatePRBody(p, diffStats{}, nil, nil, nil, nil), "## Flaky tests") {
+		t.Fatalf("expected no flaky section without flaky tests")
+	}
+}
+
+func TestGeneratePRBodyIncludesCoverageWarnings(t *testing.T) {
+	cov := &coverage.Comparison{TotalBefore: 81.5, TotalAfter: 81.25, Warnings: []string{"total coverage dropped 0.25 points"}}
+	body := generatePRBody(&plan.Plan{Summary: "x"}, diffStats{}, nil, &verifier{coverageResult: cov}, nil, nil)
+	if !strings.Contains(body, "- Total: 81.50% -> 81.25%") || !strings.Contains(body, "- Warning: total coverage dropped 0.25 points") {
+		t.Fatalf("expected coverage section, got %q", body)
+	}
+}
+
+func TestGeneratePRBodyListsDependencyChanges(t *testing.T) {
+	v := &verifier{
+		depChanges:  []deps.Change{{File: "go.mod", Name: "github.com/acme/util", Kind: "added", After: "v1.2.0"}},
+		depApproval: []string{"go.mod: added github.com/acme/util v1.2.0 (new dependencies are forbidden)"},
+	}
+	body := generatePRBody(&plan.Plan{Summary: "x"}, diffStats{}, nil, v, nil, nil)
+	if !strings.Contains(body, "## Dependency changes\n- go.mod: added github.com/acme/util v1.2.0\n") {
+		t.Fatalf("expected dependency changes section, got %q", body)
+	}
+	if !strings.Contains(body, "## Dependency approval required") || !strings.Contains(body, "(new dependencies are forbidden)") {
+		t.Fatalf("expected approval section, got %q", body)
+	}
+}
+
 func TestSetOutputWritesGithubOutputFile(t *testing.T) {
 	f, err := os.CreateTemp(t.TempDir(), "github_output_*")
 	if err != nil {
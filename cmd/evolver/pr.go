@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/ghapi"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/repoctx"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+// openPullRequest creates the pull request for a pushed branch and applies
+// labels, assignees, milestone and reviewers.
+func openPullRequest(cfg *config.Config, branch string, p *plan.Plan, stats diffStats, report *verify.Report, v *verifier, protected []string, task *repoctx.Task) (string, error) {
+	pr, err := ghapi.CreatePR(branch, p.Summary, generatePRBody(p, stats, report, v, protected, task), prDraft(cfg.PR, v))
+	if err != nil {
+		return "", err
+	}
+	return pr.URL, finishPullRequest(cfg, pr, stats, v, protected)
+}
+
+// updatePullRequest appends this run's section to the body of an existing
+// evolver pull request whose branch received this run's commit, then applies
+// the same follow-ups as a new one. Labels and reviewers are only ever added.
+func updatePullRequest(cfg *config.Config, pr *ghapi.PullRequest, p *plan.Plan, stats diffStats, report *verify.Report, v *verifier, protected []string, task *repoctx.Task) (string, error) {
+	body := appendRunSection(pr.Body, generatePRBody(p, stats, report, v, protected, task), time.Now())
+	if err := ghapi.UpdatePRBody(pr.Number, body); err != nil {
+		return "", err
+	}
+	return pr.URL, finishPullRequest(cfg, pr, stats, v, protected)
+}
+
+// finishPullRequest applies labels, assignees, milestone and reviewers.
+// Labels carry policy decisions (dependency approval, protected files), so
+// failing to add them is an error; the other follow-ups are logged.
+func finishPullRequest(cfg *config.Config, pr *ghapi.PullRequest, stats diffStats, v *verifier, protected []string) error {
+	if err := ghapi.AddLabels(pr.Number, prLabels(cfg, v, protected)); err != nil {
+		return err
+	}
+	if err := ghapi.AddAssignees(pr.Number, cfg.PR.Assignees); err != nil {
+		slog.Warn("adding pull request assignees failed", "error", err)
+	}
+	if err := ghapi.SetMilestone(pr.Number, cfg.PR.Milestone); err != nil {
+		slog.Warn("setting pull request milestone failed", "error", err)
+	}
+	requestReviewers(cfg, pr.Number, pr.User.Login, stats.changedPaths(), protected)
+	return nil
+}
+
+// appendRunSection adds a run's pull request body to the existing body as
+// an update section, one heading level down, so earlier runs stay visible.
+func appendRunSection(existing, run string, at time.Time) string {
+	var b strings.Builder
+	if existing = strings.TrimRight(existing, "\n"); existing != "" {
+		b.WriteString(existing)
+		b.WriteString("\n\n---\n\n")
+	}
+	fmt.Fprintf(&b, "## Update %s\n", at.UTC().Format("2006-01-02 15:04 UTC"))
+	for _, line := range strings.Split(strings.TrimRight(run, "\n"), "\n") {
+		if strings.HasPrefix(line, "#") {
+			line = "#" + line
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// evolverPRs returns the open pull requests opened by evolver: those from a
+// branch with the configured prefix. A label alone is not enough, since
+// evolver pushes to these branches. Order is preserved.
+func evolverPRs(prs []ghapi.PullRequest, branchPrefix string) []ghapi.PullRequest {
+	var out []ghapi.PullRequest
+	for _, pr := range prs {
+		if branchPrefix != "" && strings.HasPrefix(pr.Head.Ref, branchPrefix) {
+			out = append(out, pr)
+		}
+	}
+	return out
+}
+
+// findOpenPullRequest looks up open evolver pull requests. It returns the one
+// to continue under pr.strategy update (the newest), and whether the run must
+// be skipped because pr.max_open is reached.
+func findOpenPullRequest(c config.PR) (*ghapi.PullRequest, bool, error) {
+	all, err := ghapi.ListOpenPRs()
+	if err != nil {
+		return nil, false, err
+	}
+	open := evolverPRs(all, c.BranchPrefix)
+	slog.Info("open evolver pull requests", "count", len(open), "strategy", c.Strategy, "max_open", c.MaxOpen)
+	existing, skip := selectOpenPullRequest(c, open)
+	return existing, skip, nil
+}
+
+// selectOpenPullRequest never continues a pull request stopped with
+// /evolver stop, but still counts it against max_open.
+func selectOpenPullRequest(c config.PR, open []ghapi.PullRequest) (*ghapi.PullRequest, bool) {
+	if c.Strategy == "update" {
+		for i := range open {
+			if !open[i].HasLabel(stoppedLabel) {
+				return &open[i], false
+			}
+		}
+	}
+	return nil, c.MaxOpen > 0 && len(open) >= c.MaxOpen
+}
+
+// prDraft reports whether the pull request should open as a draft.
+func prDraft(c config.PR, v *verifier) bool {
+	switch c.Draft {
+	case "always":
+		return true
+	case "auto":
+		return len(v.repairedKinds) > 0 || (v.coverageResult != nil && len(v.coverageResult.Warnings) > 0)
+	}
+	return false
+}
+
+// prLabels returns the configured labels, the automatic ones and those
+// required by policy, without duplicates.
+func prLabels(cfg *config.Config, v *verifier, protected []string) []string {
+	labels := append([]string{}, cfg.PR.Labels...)
+	if cfg.PR.AutoLabels {
+		labels = append(labels, "evolver")
+		if len(v.repairedKinds) > 0 {
+			labels = append(labels, "evolver:repair-used")
+		}
+		for _, kind := range v.repairedKinds {
+			if kind != "" {
+				labels = append(labels, "evolver:"+kind)
+			}
+		}
+	}
+	if len(v.depApproval) > 0 {
+		labels = append(labels, cfg.Dependencies.ApprovalLabel)
+	}
+	if len(protected) > 0 {
+		labels = append(labels, cfg.Protection.Label)
+	}
+
+	seen := map[string]bool{}
+	out := labels[:0]
+	for _, l := range labels {
+		l = strings.TrimSpace(l)
+		if l == "" || seen[l] {
+			continue
+		}
+		seen[l] = true
+		out = append(out, l)
+	}
+	return out
+}
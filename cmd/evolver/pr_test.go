@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/coverage"
+	"github.com/mmrzaf/evolver/internal/ghapi"
+)
+
+func TestPRLabelsCombineConfiguredAutomaticAndPolicyLabels(t *testing.T) {
+	cfg := &config.Config{
+		PR:           config.PR{Labels: []string{"automation", "evolver"}, AutoLabels: true},
+		Dependencies: config.Dependencies{ApprovalLabel: "deps-approval"},
+		Protection:   config.Protection{Label: "evolver:protected"},
+	}
+	v := &verifier{repairedKinds: []string{"compile_failure", "test_failure", "compile_failure"}, depApproval: []string{"x"}}
+	got := prLabels(cfg, v, []string{"LICENSE"})
+	want := []string{"automation", "evolver", "evolver:repair-used", "evolver:compile_failure", "evolver:test_failure", "deps-approval", "evolver:protected"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected labels:\n got  %v\n want %v", got, want)
+	}
+
+	cfg.PR.AutoLabels = false
+	if got := prLabels(cfg, &verifier{}, nil); !reflect.DeepEqual(got, []string{"automation", "evolver"}) {
+		t.Fatalf("expected only configured labels, got %v", got)
+	}
+}
+
+func TestPRDraft(t *testing.T) {
+	clean := &verifier{}
+	repaired := &verifier{repairedKinds: []string{"vet_failure"}}
+	warned := &verifier{coverageResult: &coverage.Comparison{Warnings: []string{"dropped"}}}
+	for _, tc := range []struct {
+		mode string
+		v    *verifier
+		want bool
+	}{
+		{"never", repaired, false},
+		{"always", clean, true},
+		{"auto", clean, false},
+		{"auto", repaired, true},
+		{"auto", warned, true},
+	} {
+		if got := prDraft(config.PR{Draft: tc.mode}, tc.v); got != tc.want {
+			t.Errorf("prDraft(%s, %+v) = %v, want %v", tc.mode, tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestSelectOpenPullRequest(t *testing.T) {
+	var prs []ghapi.PullRequest
+	for _, ref := range []string{"evolve/2026-02-02-000000", "feature/x", "evolve/2026-01-01-000000"} {
+		pr := ghapi.PullRequest{Number: len(prs) + 1}
+		pr.Head.Ref = ref
+		prs = append(prs, pr)
+	}
+	prs[1].Labels = []ghapi.Label{{Name: "evolver"}}
+	prs = append(prs, ghapi.PullRequest{Number: 4})
+
+	// A human branch labeled evolver is not evolver's to push to.
+	open := evolverPRs(prs, "evolve/")
+	if len(open) != 2 || open[0].Number != 1 || open[1].Number != 3 {
+		t.Fatalf("unexpected evolver pull requests: %+v", open)
+	}
+
+	if pr, skip := selectOpenPullRequest(config.PR{Strategy: "update", MaxOpen: 1}, open); pr == nil || pr.Number != 1 || skip {
+		t.Fatalf("expected newest pull request to be continued, got %+v skip=%v", pr, skip)
+	}
+	if pr, skip := selectOpenPullRequest(config.PR{Strategy: "new", MaxOpen: 2}, open); pr != nil || !skip {
+		t.Fatalf("expected skip at max_open, got %+v skip=%v", pr, skip)
+	}
+	if pr, skip := selectOpenPullRequest(config.PR{Strategy: "new", MaxOpen: 3}, open); pr != nil || skip {
+		t.Fatalf("expected a new pull request below max_open, got %+v skip=%v", pr, skip)
+	}
+
+	open[0].Labels = []ghapi.Label{{Name: stoppedLabel}}
+	if pr, _ := selectOpenPullRequest(config.PR{Strategy: "update"}, open); pr == nil || pr.Number != 3 {
+		t.Fatalf("expected stopped pull request to be passed over, got %+v", pr)
+	}
+}
+
+func TestAppendRunSectionKeepsEarlierRuns(t *testing.T) {
+	at := time.Date(2026, 3, 4, 15, 4, 0, 0, time.UTC)
+	got := appendRunSection("## Summary\nFirst run\n", "## Summary\nSecond run\n\n## Stats\n- Files changed: 1\n", at)
+	want := "## Summary\nFirst run\n\n---\n\n## Update 2026-03-04 15:04 UTC\n### Summary\nSecond run\n\n### Stats\n- Files changed: 1\n"
+	if got != want {
+		t.Fatalf("unexpected body:\n%s", got)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/gocheck"
+	"github.com/mmrzaf/evolver/internal/llm/gemini"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/repoctx"
+)
+
+// maxPrecheckDiagnostics bounds how many diagnostics go into a fixup prompt.
+const maxPrecheckDiagnostics = 30
+
+// precheckPlan type-checks the plan's Go files before they are written and
+// sends any diagnostics back to the provider for a fixup, up to
+// precheck.max_fixups times. A fixup goes through the same plan and roadmap
+// checks as p and must keep its roadmap_item. A plan that still has problems
+// is returned as is; verification and the repair loop deal with it.
+func precheckPlan(cfg *config.Config, repo *repoctx.Context, client *gemini.Client, p *plan.Plan) *plan.Plan {
+	if !cfg.Precheck.Go {
+		return p
+	}
+	for fixups := 0; ; fixups++ {
+		diags, err := gocheck.Check(plannedGoFiles(p))
+		if err != nil {
+			slog.Warn("go pre-check unavailable", "error", err)
+			return p
+		}
+		if len(diags) == 0 {
+			if fixups > 0 {
+				slog.Info("go pre-check passed after fixup", "fixups", fixups)
+			}
+			return p
+		}
+
+		lines := make([]string, 0, len(diags))
+		for i, d := range diags {
+			if i == maxPrecheckDiagnostics {
+				break
+			}
+			lines = append(lines, d.String())
+		}
+		slog.Warn("go pre-check found problems in planned files", "diagnostics", len(diags), "first", lines[0], "fixups", fixups)
+		if client == nil || fixups >= cfg.Precheck.MaxFixups {
+			return p
+		}
+
+		fixed, err := client.FixPlan(cfg, p, lines)
+		if err != nil {
+			slog.Warn("go pre-check fixup failed; keeping plan", "error", err)
+			return p
+		}
+		if err := checkFixup(cfg, repo, p, fixed); err != nil {
+			slog.Warn("go pre-check fixup rejected; keeping plan", "error", err)
+			return p
+		}
+		p = fixed
+	}
+}
+
+// checkFixup validates a pre-check fixup of p before it replaces p.
+func checkFixup(cfg *config.Config, repo *repoctx.Context, p, fixed *plan.Plan) error {
+	if err := checkPlan(cfg, fixed); err != nil {
+		return err
+	}
+	if fixed.RoadmapItem != p.RoadmapItem {
+		return fmt.Errorf("fixup changed roadmap_item from %q to %q", p.RoadmapItem, fixed.RoadmapItem)
+	}
+	return checkRoadmapPlan(repo, fixed)
+}
+
+func plannedGoFiles(p *plan.Plan) map[string]string {
+	files := map[string]string{}
+	for _, f := range p.Files {
+		if f.Mode == "write" {
+			files[f.Path] = f.Content
+		}
+	}
+	return files
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/gitops"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/protect"
+)
+
+// checkProtected returns the changed files that are protected, judged by
+// protected_paths and by markers in their HEAD content. This also covers edits
+// made by repair capabilities, which never pass through plan.ValidatePaths.
+// Unless protected edits are allowed, any such file is an error.
+func checkProtected(cfg *config.Config) ([]string, error) {
+	changed, err := gitops.ChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	var protected []string
+	for _, f := range changed {
+		head, err := gitops.HeadFile(f)
+		if err != nil {
+			// New file: only a pattern can protect it.
+			head = nil
+		}
+		if reason := protect.Reason(cfg.ProtectedPaths, f, head); reason != "" {
+			slog.Warn("protected file changed", "path", f, "reason", reason)
+			protected = append(protected, f)
+		}
+	}
+	if len(protected) > 0 && !plan.ProtectedEditsAllowed(cfg) {
+		return protected, fmt.Errorf("protected files changed: %s", strings.Join(protected, ", "))
+	}
+	return protected, nil
+}
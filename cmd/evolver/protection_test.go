@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/mmrzaf/evolver/internal/config"
+)
+
+func TestCheckProtectedUsesHeadMarkersAndPatterns(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v (%s)", args, err, out)
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	git("init", "-q")
+	git("config", "user.name", "tester")
+	git("config", "user.email", "tester@example.com")
+	write("keep.go", "// evolver:protected\npackage main\n")
+	write("main.go", "package main\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	// Removing the marker does not unprotect the file: HEAD content counts.
+	write("keep.go", "package main\n")
+	write("main.go", "package main\n\nfunc main() {}\n")
+	write("LICENSE", "MIT\n")
+
+	cfg := &config.Config{Mode: "push", ProtectedPaths: []string{"LICENSE"}}
+	protected, err := checkProtected(cfg)
+	if err == nil || strings.Join(protected, ",") != "LICENSE,keep.go" {
+		t.Fatalf("expected LICENSE and keep.go to be rejected, got %v (%v)", protected, err)
+	}
+
+	cfg.Mode = "pr"
+	cfg.Protection.AllowInPR = true
+	if protected, err := checkProtected(cfg); err != nil || len(protected) != 2 {
+		t.Fatalf("expected protected edits to be allowed in pr mode, got %v (%v)", protected, err)
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/ghapi"
+	"github.com/mmrzaf/evolver/internal/ghevent"
+	"github.com/mmrzaf/evolver/internal/gitops"
+	"github.com/mmrzaf/evolver/internal/llm/gemini"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/repoctx"
+)
+
+// maxReviewComments caps how many comments one address-review run takes on;
+// the rest are picked up by the next run.
+const maxReviewComments = 20
+
+// addressedMarker tags evolver's replies with the comment they answer, so a
+// comment is never addressed twice.
+var addressedMarker = regexp.MustCompile(`<!-- evolver:addressed ([a-z]+-\d+) -->`)
+
+// reviewItem is a comment to address, identified in the prompt by ID.
+type reviewItem struct {
+	ID      string
+	Kind    string // review_comment, issue_comment or review
+	Comment ghapi.Comment
+}
+
+// addressReview is the address-review command: it turns unanswered review
+// feedback on an evolver pull request into a verified commit on its branch
+// and replies to each comment. args may name the pull request number;
+// otherwise it comes from the triggering GitHub event.
+func addressReview(args []string) (err error) {
+	startedAt := time.Now()
+	summary := ""
+	changed := false
+
+	cfg, closeLogger, err := setup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := closeLogger(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	slog.Info("evolver address-review started", "provider", cfg.Provider, "model", cfg.Model, "workdir", cfg.Workdir)
+	defer func() {
+		fields := []any{"summary", summary, "duration_ms", time.Since(startedAt).Milliseconds()}
+		if err != nil {
+			fields = append(fields, "error", err)
+			slog.Error("evolver address-review failed", fields...)
+			return
+		}
+		slog.Info("evolver address-review finished", fields...)
+	}()
+
+	skip := func(reason string) error {
+		summary = "Skipped: " + reason
+		slog.Info("address-review skipped", "summary", summary)
+		setOutput("changed", "false")
+		setOutput("summary", summary)
+		return nil
+	}
+
+	unlock, err := enterWorkdir(cfg)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	prURL := ""
+	hist := newRunHistory(cfg, "address-review", startedAt)
+	defer func() {
+		hist.finish(runResult{changed: changed, summary: summary, prURL: prURL}, err)
+	}()
+
+	number, reason, err := reviewTarget(args)
+	if err != nil {
+		return err
+	}
+	if number == 0 {
+		return skip(reason)
+	}
+
+	var pr *ghapi.PullRequest
+	if err := logStep("get_pull_request", func() error {
+		var getErr error
+		pr, getErr = ghapi.GetPR(number)
+		return getErr
+	}); err != nil {
+		return err
+	}
+	prURL = pr.URL
+	hist.rec.Branch = pr.Head.Ref
+	if len(evolverPRs([]ghapi.PullRequest{*pr}, cfg.PR.BranchPrefix)) == 0 {
+		return skip(fmt.Sprintf("pull request #%d was not opened by evolver", number))
+	}
+	if pr.HasLabel(stoppedLabel) {
+		return skip(fmt.Sprintf("pull request #%d was stopped with /evolver stop", number))
+	}
+
+	var items []reviewItem
+	if err := logStep("collect_review_comments", func() error {
+		var collectErr error
+		items, collectErr = collectReviewItems(number, cfg.SlashCommands.MinPermission)
+		return collectErr
+	}); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return skip(fmt.Sprintf("no unanswered review comments on #%d", number))
+	}
+	slog.Info("review comments to address", "number", number, "count", len(items))
+
+	if err := logStep("git_checkout_existing_branch", func() error { return gitops.CheckoutRemote(pr.Head.Ref) }); err != nil {
+		return err
+	}
+
+	var repo *repoctx.Context
+	if err := logStep("gather_repo_context", func() error {
+		var gatherErr error
+		repo, gatherErr = repoctx.Gather(cfg)
+		return gatherErr
+	}); err != nil {
+		return err
+	}
+	// The review feedback is the objective, not the roadmap item.
+	repo.Target = nil
+
+	v := newVerifier(cfg)
+	hist.v = v
+	if repo.Rules != nil {
+		v.rules = *repo.Rules
+	}
+	if captureBaseline(cfg, v) {
+		return skip("baseline verification is failing")
+	}
+
+	var (
+		p      *plan.Plan
+		client *gemini.Client
+	)
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "", "gemini":
+		client = gemini.NewClient(os.Getenv("GEMINI_API_KEY"), cfg.Model)
+		hist.usage = client.Usage
+		if err := logStep("generate_review_plan_gemini", func() error {
+			var planErr error
+			p, planErr = client.GenerateReviewPlan(repo, cfg, pr.Title, reviewComments(items))
+			return planErr
+		}); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported provider: %s", cfg.Provider)
+	}
+	slog.Info("review plan generated", "files", len(p.Files), "replies", len(p.Replies))
+
+	if len(p.Files) > 0 {
+		var commitErr error
+		changed, commitErr = commitReviewPlan(cfg, repo, client, p, pr, v, hist)
+		if commitErr != nil {
+			postFailureComment(number, commitErr)
+			return commitErr
+		}
+	}
+
+	if err := logStep("reply_to_comments", func() error { return replyToReview(number, items, p, changed) }); err != nil {
+		return err
+	}
+
+	summary = p.Summary
+	if strings.TrimSpace(summary) == "" {
+		summary = fmt.Sprintf("Answered %d review comments", len(items))
+	}
+	setOutput("changed", strconv.FormatBool(changed))
+	setOutput("summary", summary)
+	setOutput("pr_url", pr.URL)
+	return nil
+}
+
+// reviewTarget resolves the pull request to work on. A zero number with a
+// reason means the triggering event is not one to act on.
+func reviewTarget(args []string) (int, string, error) {
+	if len(args) > 0 {
+		n, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+		if err != nil || n <= 0 {
+			return 0, "", fmt.Errorf("invalid pull request number %q", args[0])
+		}
+		return n, "", nil
+	}
+	ev, err := ghevent.Load()
+	if err != nil {
+		return 0, "", fmt.Errorf("address-review needs a pull request number or a GitHub event: %w", err)
+	}
+	if !ev.IsPullRequest || ev.Number == 0 {
+		return 0, fmt.Sprintf("%s event is not on a pull request", ev.Name), nil
+	}
+	if c := ev.Comment; c != nil && !reviewFeedback(c.Body) {
+		return 0, "triggering comment is not review feedback", nil
+	}
+	return ev.Number, "", nil
+}
+
+// reviewFeedback reports whether a comment body is feedback to address:
+// not empty, not an /evolver command and not written by evolver itself.
+func reviewFeedback(body string) bool {
+	body = strings.TrimSpace(body)
+	return body != "" && !strings.HasPrefix(body, "/evolver") && !strings.Contains(body, "<!-- evolver:")
+}
+
+func collectReviewItems(number int, minPermission string) ([]reviewItem, error) {
+	reviewComments, err := ghapi.ListReviewComments(number)
+	if err != nil {
+		return nil, err
+	}
+	issueComments, err := ghapi.ListIssueComments(number)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := ghapi.ListReviews(number)
+	if err != nil {
+		return nil, err
+	}
+	allowed := authorAllowed(minPermission, ghapi.Permission)
+	return pendingReviewItems(reviewComments, issueComments, reviews, allowed), nil
+}
+
+// authorAllowed returns a check that a comment author has at least
+// minPermission on the repository, looking each author up once. Feedback
+// becomes model input and a pushed commit, so it gets the same bar as slash
+// commands; an author whose permission cannot be looked up is not allowed.
+func authorAllowed(minPermission string, lookup func(string) (string, error)) func(string) bool {
+	seen := make(map[string]bool)
+	return func(login string) bool {
+		if ok, done := seen[login]; done {
+			return ok
+		}
+		perm, err := lookup(login)
+		if err != nil {
+			slog.Warn("permission lookup failed; ignoring comments", "author", login, "error", err)
+		}
+		ok := err == nil && permissionAllows(perm, minPermission)
+		if err == nil && !ok {
+			slog.Info("ignoring review feedback from author below min_permission", "author", login, "permission", perm, "min_permission", minPermission)
+		}
+		seen[login] = ok
+		return ok
+	}
+}
+
+// pendingReviewItems returns the feedback that has no evolver reply yet,
+// skipping bots and authors allowed rejects, up to maxReviewComments.
+func pendingReviewItems(reviewComments, issueComments, reviews []ghapi.Comment, allowed func(string) bool) []reviewItem {
+	addressed := make(map[string]bool)
+	for _, list := range [][]ghapi.Comment{reviewComments, issueComments} {
+		for _, c := range list {
+			for _, m := range addressedMarker.FindAllStringSubmatch(c.Body, -1) {
+				addressed[m[1]] = true
+			}
+		}
+	}
+
+	var out []reviewItem
+	add := func(prefix, kind string, list []ghapi.Comment) {
+		for _, c := range list {
+			id := fmt.Sprintf("%s-%d", prefix, c.ID)
+			if addressed[id] || strings.EqualFold(c.User.Type, "Bot") || !reviewFeedback(c.Body) || !allowed(c.User.Login) {
+				continue
+			}
+			if len(out) < maxReviewComments {
+				out = append(out, reviewItem{ID: id, Kind: kind, Comment: c})
+			}
+		}
+	}
+	add("rc", "review_comment", reviewComments)
+	add("rv", "review", reviews)
+	add("ic", "issue_comment", issueComments)
+	return out
+}
+
+// reviewComments converts items for the prompt, with the current lines
+// around each line comment.
+func reviewComments(items []reviewItem) []gemini.ReviewComment {
+	root, err := gitops.TopLevel()
+	if err != nil {
+		root = "."
+	}
+	out := make([]gemini.ReviewComment, 0, len(items))
+	for _, it := range items {
+		c := it.Comment
+		rc := gemini.ReviewComment{ID: it.ID, Author: c.User.Login, Body: c.Body, Path: c.Path, Line: c.Line, DiffHunk: c.DiffHunk}
+		if c.Path != "" && c.Line > 0 {
+			if b, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(c.Path))); err == nil {
+				rc.Excerpt = lineExcerpt(string(b), c.Line, 10)
+			}
+		}
+		out = append(out, rc)
+	}
+	return out
+}
+
+// lineExcerpt returns the lines within radius of line (1-based), numbered.
+func lineExcerpt(content string, line, radius int) string {
+	lines := strings.Split(content, "\n")
+	start, end := max(line-radius, 1), min(line+radius, len(lines))
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&b, "%d: %s\n", i, lines[i-1])
+	}
+	return b.String()
+}
+
+// commitReviewPlan applies, verifies, commits and pushes a review plan to the
+// pull request branch, then applies the pull request follow-ups again. It
+// reports whether a commit was pushed.
+func commitReviewPlan(cfg *config.Config, repo *repoctx.Context, client *gemini.Client, p *plan.Plan, pr *ghapi.PullRequest, v *verifier, hist *runHistory) (bool, error) {
+	ch, err := applyVerifiedPlan(cfg, repo, client, p, false, v, hist, nil)
+	if err != nil || ch == nil {
+		return false, err
+	}
+	if strings.TrimSpace(p.Summary) == "" {
+		p.Summary = "Address review comments"
+	}
+	if err := logStep("git_commit", func() error { return gitops.Commit(p.Summary) }); err != nil {
+		return false, err
+	}
+	if err := logStep("git_push_branch", func() error { return gitops.Push(pr.Head.Ref) }); err != nil {
+		return false, err
+	}
+	return true, logStep("update_pull_request", func() error { return finishPullRequest(cfg, pr, ch.stats, v, ch.protected) })
+}
+
+// replyToReview answers every item: line comments in their thread, the rest
+// as a conversation comment quoting the feedback.
+func replyToReview(number int, items []reviewItem, p *plan.Plan, changed bool) error {
+	replies := make(map[string]string, len(p.Replies))
+	for _, r := range p.Replies {
+		replies[r.CommentID] = strings.TrimSpace(r.Body)
+	}
+	for _, it := range items {
+		body := reviewReply(it, replies[it.ID], changed)
+		var err error
+		if it.Kind == "review_comment" {
+			thread := it.Comment.ID
+			if it.Comment.InReplyToID != 0 {
+				thread = it.Comment.InReplyToID
+			}
+			err = ghapi.ReplyToReviewComment(number, thread, body)
+		} else {
+			err = ghapi.CreateIssueComment(number, body)
+		}
+		if err != nil {
+			return fmt.Errorf("reply to %s: %w", it.ID, err)
+		}
+	}
+	return nil
+}
+
+func reviewReply(it reviewItem, reply string, changed bool) string {
+	if reply == "" {
+		reply = "No code changes were needed for this comment."
+		if changed {
+			reply = "Addressed in the latest commit on this branch."
+		}
+	}
+	var b strings.Builder
+	if it.Kind != "review_comment" {
+		fmt.Fprintf(&b, "> @%s: %s\n\n", it.Comment.User.Login, firstLine(it.Comment.Body))
+	}
+	b.WriteString(reply)
+	fmt.Fprintf(&b, "\n\n<!-- evolver:addressed %s -->", it.ID)
+	return b.String()
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " …"
+	}
+	return s
+}
+
+// postFailureComment tells reviewers the feedback was not addressed. It
+// carries no addressed marker, so the next run tries again.
+func postFailureComment(number int, cause error) {
+	body := fmt.Sprintf("evolver could not address the review comments; nothing was pushed.\n\n```\n%s\n```\n\n<!-- evolver:failed -->", trimForPrompt(cause.Error(), 2000))
+	if err := ghapi.CreateIssueComment(number, body); err != nil {
+		slog.Warn("posting failure comment failed", "error", err)
+	}
+}
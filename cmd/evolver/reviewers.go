@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmrzaf/evolver/internal/codeowners"
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/ghapi"
+	"github.com/mmrzaf/evolver/internal/gitops"
+)
+
+// requestReviewers asks for review of a pull request. Owners of protected
+// files come first so they survive pr.max_reviewers; the remaining slots go to
+// pr.reviewers when set, else to the CODEOWNERS of the changed files (paths
+// relative to the repository root), leaving out author, the pull request's
+// author. Protected files are relative to the current directory. Failures are logged, not returned: the pull request
+// already exists and reviewers can still be added by hand.
+func requestReviewers(cfg *config.Config, number int, author string, changed, protected []string) {
+	if !cfg.PR.RequestCodeowners && len(cfg.PR.Reviewers) == 0 && len(protected) == 0 {
+		return
+	}
+	co, err := loadCodeowners()
+	if err != nil {
+		slog.Warn("cannot read CODEOWNERS", "error", err)
+	}
+
+	var owners []string
+	if len(protected) > 0 {
+		prefix, err := gitops.Prefix()
+		if err != nil {
+			slog.Warn("cannot resolve protected file paths; no owners requested for them", "error", err)
+		}
+		for _, f := range protected {
+			owners = append(owners, co.Owners(path.Join(prefix, filepath.ToSlash(f)))...)
+		}
+		if len(owners) == 0 {
+			slog.Warn("protected files have no CODEOWNERS", "files", strings.Join(protected, ","))
+		}
+	}
+	switch {
+	case len(cfg.PR.Reviewers) > 0:
+		owners = append(owners, cfg.PR.Reviewers...)
+	case cfg.PR.RequestCodeowners:
+		for _, f := range changed {
+			owners = append(owners, co.Owners(f)...)
+		}
+	}
+
+	users, teams := pickReviewers(owners, cfg.PR.MaxReviewers, author)
+	if len(users) == 0 && len(teams) == 0 {
+		slog.Info("no pull request reviewers to request")
+		return
+	}
+	if err := ghapi.RequestReviewers(number, users, teams); err != nil {
+		slog.Warn("requesting pull request reviewers failed", "error", err)
+	}
+}
+
+func loadCodeowners() (*codeowners.File, error) {
+	root, err := gitops.TopLevel()
+	if err != nil {
+		return nil, err
+	}
+	return codeowners.Load(root)
+}
+
+// pickReviewers turns owners ("@user", "@org/team", or a bare user name from
+// pr.reviewers) into at most max users and teams, in order, without the PR
+// author, who GitHub refuses as a reviewer.
+func pickReviewers(owners []string, max int, author string) (users, teams []string) {
+	normalized := make([]string, 0, len(owners))
+	for _, o := range owners {
+		o = strings.TrimSpace(o)
+		if o != "" && !strings.HasPrefix(o, "@") && !strings.Contains(o, "@") {
+			o = "@" + o
+		}
+		if author != "" && strings.EqualFold(o, "@"+author) {
+			continue
+		}
+		normalized = append(normalized, o)
+	}
+	// Reviewers keeps users and teams apart; cap their combined count in owner order.
+	var kept []string
+	seen := map[string]bool{}
+	for _, o := range normalized {
+		if seen[o] || !strings.HasPrefix(o, "@") {
+			continue
+		}
+		if max > 0 && len(kept) >= max {
+			break
+		}
+		seen[o] = true
+		kept = append(kept, o)
+	}
+	return codeowners.Reviewers(kept)
+}
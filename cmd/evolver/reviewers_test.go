@@ -0,0 +1,19 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPickReviewersCapsInOrderAndSkipsAuthor(t *testing.T) {
+	owners := []string{"@sec-lead", "@acme/core", "evolver-bot", "docs@example.com", "@gopher", "@acme/core", "@writer"}
+	users, teams := pickReviewers(owners, 3, "evolver-bot")
+	if !reflect.DeepEqual(users, []string{"sec-lead", "gopher"}) || !reflect.DeepEqual(teams, []string{"core"}) {
+		t.Fatalf("unexpected reviewers: users=%v teams=%v", users, teams)
+	}
+
+	users, _ = pickReviewers([]string{"alice", "bob"}, 0, "")
+	if !reflect.DeepEqual(users, []string{"alice", "bob"}) {
+		t.Fatalf("expected bare names without a cap, got %v", users)
+	}
+}
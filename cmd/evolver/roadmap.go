@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/repoctx"
+	"github.com/mmrzaf/evolver/internal/roadmap"
+)
+
+// checkRoadmapPlan keeps roadmap changes structured: a plan that changes
+// files on a roadmap run must name the target item, ROADMAP.md is only
+// changed through roadmap_ops, and the ops must apply to the current file.
+func checkRoadmapPlan(repo *repoctx.Context, p *plan.Plan) error {
+	for _, f := range p.Files {
+		if filepath.Clean(f.Path) == "ROADMAP.md" {
+			return fmt.Errorf("plan edits ROADMAP.md directly; use roadmap_ops")
+		}
+	}
+	if t := repo.Target; t != nil && repo.Task == nil && len(p.Files) > 0 && p.RoadmapItem != t.ID {
+		return fmt.Errorf("plan must work on roadmap item %s (%s), got roadmap_item %q", t.ID, t.Text, p.RoadmapItem)
+	}
+	if len(p.RoadmapOps) == 0 {
+		return nil
+	}
+	if repo.Roadmap == nil {
+		return fmt.Errorf("plan has roadmap_ops but there is no ROADMAP.md")
+	}
+	return roadmap.Parse(repo.Roadmap.String()).Apply(p.RoadmapOps)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/repoctx"
+	"github.com/mmrzaf/evolver/internal/roadmap"
+)
+
+func TestCheckRoadmapPlan(t *testing.T) {
+	rm := roadmap.Parse("# ROADMAP\n## Now\n- [x] R1: add config\n- [ ] R2: add retries\n")
+	repo := &repoctx.Context{Roadmap: rm, Target: rm.Target()}
+	files := []plan.File{{Path: "retry.go", Mode: "write", Content: "package x\n"}}
+
+	if err := checkRoadmapPlan(repo, &plan.Plan{Files: files, RoadmapItem: "R2", RoadmapOps: []roadmap.Op{{Op: "check", ID: "R2"}}}); err != nil {
+		t.Fatalf("expected plan for the target to pass: %v", err)
+	}
+	if err := checkRoadmapPlan(repo, &plan.Plan{Files: files, RoadmapItem: "R1"}); err == nil || !strings.Contains(err.Error(), "R2") {
+		t.Fatalf("expected plan for another item to fail, got %v", err)
+	}
+	if err := checkRoadmapPlan(repo, &plan.Plan{Files: files, RoadmapItem: "R2", RoadmapOps: []roadmap.Op{{Op: "check", ID: "R7"}}}); err == nil {
+		t.Fatalf("expected unknown roadmap item to fail")
+	}
+	direct := append(files, plan.File{Path: "./ROADMAP.md", Mode: "write", Content: "# ROADMAP\n"})
+	if err := checkRoadmapPlan(repo, &plan.Plan{Files: direct, RoadmapItem: "R2"}); err == nil {
+		t.Fatalf("expected direct ROADMAP.md edit to fail")
+	}
+
+	repo.Task = &repoctx.Task{Issue: 5}
+	if err := checkRoadmapPlan(repo, &plan.Plan{Files: files}); err != nil {
+		t.Fatalf("expected issue runs to skip the roadmap target: %v", err)
+	}
+	if rm.Item("R2").Done {
+		t.Fatalf("expected validation to leave the parsed roadmap untouched")
+	}
+}
+
+func TestCheckFixupKeepsRoadmapRules(t *testing.T) {
+	rm := roadmap.Parse("# ROADMAP\n## Now\n- [ ] R2: add retries\n- [ ] R3: add metrics\n")
+	repo := &repoctx.Context{Roadmap: rm, Target: rm.Target()}
+	cfg := &config.Config{}
+	files := []plan.File{{Path: "retry.go", Mode: "write", Content: "package x\n"}}
+	p := &plan.Plan{Files: files, RoadmapItem: "R2"}
+
+	if err := checkFixup(cfg, repo, p, &plan.Plan{Files: files, RoadmapItem: "R2"}); err != nil {
+		t.Fatalf("expected fixup for the same item to pass: %v", err)
+	}
+	if err := checkFixup(cfg, repo, p, &plan.Plan{Files: files}); err == nil {
+		t.Fatalf("expected fixup dropping roadmap_item to fail")
+	}
+	direct := append(files, plan.File{Path: "ROADMAP.md", Mode: "write", Content: "# ROADMAP\n"})
+	if err := checkFixup(cfg, repo, p, &plan.Plan{Files: direct, RoadmapItem: "R2"}); err == nil {
+		t.Fatalf("expected fixup editing ROADMAP.md to fail")
+	}
+	if err := checkFixup(cfg, repo, p, &plan.Plan{Files: files, RoadmapItem: "R2", RoadmapOps: []roadmap.Op{{Op: "check", ID: "R9"}}}); err == nil {
+		t.Fatalf("expected fixup with invalid roadmap_ops to fail")
+	}
+}
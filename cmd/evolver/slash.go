@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/ghapi"
+	"github.com/mmrzaf/evolver/internal/ghevent"
+	"github.com/mmrzaf/evolver/internal/gitops"
+	"github.com/mmrzaf/evolver/internal/llm/gemini"
+)
+
+// stoppedLabel marks an evolver pull request that /evolver stop took out of
+// rotation: runs do not continue it and its reviews are not addressed.
+const stoppedLabel = "evolver:stopped"
+
+// slashCommand is an /evolver command from a comment.
+type slashCommand struct {
+	Name string // retry, rebase, explain, stop or goal
+	Arg  string
+}
+
+// parseSlashCommand finds the first line of body starting with /evolver.
+func parseSlashCommand(body string) (slashCommand, bool) {
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "/evolver" {
+			continue
+		}
+		if len(fields) == 1 {
+			return slashCommand{}, true
+		}
+		arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "/evolver"))
+		arg = strings.TrimSpace(arg[len(fields[1]):])
+		return slashCommand{Name: strings.ToLower(fields[1]), Arg: arg}, true
+	}
+	return slashCommand{}, false
+}
+
+// eventSlashCommand returns the /evolver command of a newly created,
+// non-bot comment. Editing or deleting an old command comment does not run
+// it again.
+func eventSlashCommand(ev *ghevent.Event) (slashCommand, bool) {
+	if ev.Action != "created" || ev.Comment == nil || ev.Number == 0 || strings.HasSuffix(ev.Comment.Author, "[bot]") {
+		return slashCommand{}, false
+	}
+	return parseSlashCommand(ev.Comment.Body)
+}
+
+var permissionRank = map[string]int{"read": 1, "triage": 2, "write": 3, "maintain": 4, "admin": 5}
+
+// permissionAllows reports whether repository role have is at least min.
+func permissionAllows(have, min string) bool {
+	return permissionRank[have] > 0 && permissionRank[have] >= permissionRank[min]
+}
+
+// slashCommandReply is posted back on the issue or pull request; the marker
+// keeps it from being read as review feedback.
+func slashCommandReply(author string, cmd slashCommand, text string) string {
+	line := strings.TrimSpace("/evolver " + cmd.Name + " " + cmd.Arg)
+	return fmt.Sprintf("> @%s: `%s`\n\n%s\n\n<!-- evolver:command -->", author, line, strings.TrimSpace(text))
+}
+
+// handleSlashCommand is the slash-command command: it runs the /evolver
+// command in the comment that triggered the workflow.
+func handleSlashCommand() (err error) {
+	startedAt := time.Now()
+	cfg, closeLogger, err := setup()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := closeLogger(); err == nil && closeErr != nil {
+			err = closeErr
+		}
+	}()
+
+	ev, err := ghevent.Load()
+	if err != nil {
+		return err
+	}
+	cmd, ok := eventSlashCommand(ev)
+	if !ok {
+		slog.Info("slash command skipped: no new /evolver comment", "event", ev.Name, "action", ev.Action, "number", ev.Number)
+		return nil
+	}
+	slog.Info("slash command received", "command", cmd.Name, "author", ev.Comment.Author, "number", ev.Number, "pull_request", ev.IsPullRequest)
+	defer func() {
+		fields := []any{"command", cmd.Name, "duration_ms", time.Since(startedAt).Milliseconds()}
+		if err != nil {
+			slog.Error("slash command failed", append(fields, "error", err)...)
+			return
+		}
+		slog.Info("slash command finished", fields...)
+	}()
+
+	reply := func(text string) error {
+		return ghapi.CreateIssueComment(ev.Number, slashCommandReply(ev.Comment.Author, cmd, text))
+	}
+
+	perm, err := ghapi.Permission(ev.Comment.Author)
+	if err != nil {
+		return fmt.Errorf("look up permission of %s: %w", ev.Comment.Author, err)
+	}
+	if !permissionAllows(perm, cfg.SlashCommands.MinPermission) {
+		slog.Warn("slash command denied", "author", ev.Comment.Author, "permission", perm, "min_permission", cfg.SlashCommands.MinPermission)
+		return reply(fmt.Sprintf("Only users with %s permission or higher can run evolver commands.", cfg.SlashCommands.MinPermission))
+	}
+
+	var pr *ghapi.PullRequest
+	if ev.IsPullRequest {
+		if pr, err = ghapi.GetPR(ev.Number); err != nil {
+			return err
+		}
+		if len(evolverPRs([]ghapi.PullRequest{*pr}, cfg.PR.BranchPrefix)) == 0 {
+			return reply("This pull request was not opened by evolver, so evolver commands do not apply to it.")
+		}
+	}
+
+	text, cmdErr := runSlashCommand(cfg, cmd, pr)
+	if cmdErr != nil {
+		text = fmt.Sprintf("`/evolver %s` failed:\n\n```\n%s\n```", cmd.Name, trimForPrompt(cmdErr.Error(), 2000))
+	}
+	if err := reply(text); err != nil {
+		slog.Warn("posting slash command reply failed", "error", err)
+	}
+	return cmdErr
+}
+
+// runSlashCommand runs cmd for pr (nil on an issue) and returns the reply.
+func runSlashCommand(cfg *config.Config, cmd slashCommand, pr *ghapi.PullRequest) (string, error) {
+	switch cmd.Name {
+	case "retry", "goal":
+		opts := runOptions{pr: pr, trigger: "/evolver " + cmd.Name, result: &runResult{}}
+		if cmd.Name == "goal" {
+			if cmd.Arg == "" {
+				return "Usage: `/evolver goal <text>`.", nil
+			}
+			opts.goal = cmd.Arg
+		}
+		if pr != nil {
+			cfg.Mode = "pr"
+			if err := ghapi.RemoveLabel(pr.Number, stoppedLabel); err != nil {
+				slog.Warn("removing stop label failed", "error", err)
+			}
+		}
+		if err := evolve(cfg, opts); err != nil {
+			return "", err
+		}
+		return runResultReply(*opts.result), nil
+
+	case "rebase":
+		if pr == nil {
+			return "`/evolver rebase` only works on evolver pull requests.", nil
+		}
+		unlock, err := enterWorkdir(cfg)
+		if err != nil {
+			return "", err
+		}
+		defer unlock()
+		base := ghapi.DefaultBranch()
+		if err := gitops.CheckoutRemote(pr.Head.Ref); err != nil {
+			return "", err
+		}
+		lease, err := gitops.Head()
+		if err != nil {
+			return "", err
+		}
+		if err := gitops.RebaseOnto(base); err != nil {
+			return "", fmt.Errorf("%w\nnothing was pushed", err)
+		}
+		if err := gitops.ForcePush(pr.Head.Ref, lease); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Rebased `%s` onto `%s`.", pr.Head.Ref, base), nil
+
+	case "explain":
+		if pr == nil {
+			return "`/evolver explain` only works on evolver pull requests.", nil
+		}
+		if p := strings.ToLower(strings.TrimSpace(cfg.Provider)); p != "" && p != "gemini" {
+			return "", fmt.Errorf("unsupported provider: %s", cfg.Provider)
+		}
+		diff, err := ghapi.GetPRDiff(pr.Number)
+		if err != nil {
+			return "", err
+		}
+		return gemini.NewClient(os.Getenv("GEMINI_API_KEY"), cfg.Model).ExplainChanges(pr.Title, pr.Body, diff)
+
+	case "stop":
+		if pr == nil {
+			return "`/evolver stop` only works on evolver pull requests.", nil
+		}
+		if err := ghapi.AddLabels(pr.Number, []string{stoppedLabel}); err != nil {
+			return "", err
+		}
+		return "Stopped. evolver will not push to this pull request or address its reviews until `/evolver retry`.", nil
+	}
+	return "Unknown command. Available: `retry`, `rebase`, `explain`, `stop`, `goal <text>`.", nil
+}
+
+func runResultReply(r runResult) string {
+	switch {
+	case r.changed && r.prURL != "":
+		return fmt.Sprintf("Done: %s\n\n%s", r.summary, r.prURL)
+	case r.changed:
+		return "Done: " + r.summary
+	case r.summary != "":
+		return "No changes: " + r.summary
+	}
+	return "No changes."
+}
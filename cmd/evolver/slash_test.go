@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/ghevent"
+)
+
+func TestParseSlashCommand(t *testing.T) {
+	cases := []struct {
+		body string
+		want slashCommand
+		ok   bool
+	}{
+		{"/evolver retry", slashCommand{Name: "retry"}, true},
+		{"Thanks!\n  /evolver Goal add   retries to the client \nmore", slashCommand{Name: "goal", Arg: "add   retries to the client"}, true},
+		{"/evolver", slashCommand{}, true},
+		{"/evolverx retry", slashCommand{}, false},
+		{"please /evolver retry", slashCommand{}, false},
+	}
+	for _, c := range cases {
+		got, ok := parseSlashCommand(c.body)
+		if ok != c.ok || got != c.want {
+			t.Fatalf("parseSlashCommand(%q) = %+v, %v; want %+v, %v", c.body, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestEventSlashCommandOnlyForCreatedComments(t *testing.T) {
+	comment := &ghevent.Comment{ID: 1, Body: "/evolver retry", Author: "alice"}
+	if cmd, ok := eventSlashCommand(&ghevent.Event{Action: "created", Number: 4, Comment: comment}); !ok || cmd.Name != "retry" {
+		t.Fatalf("expected created comment to run, got %+v %v", cmd, ok)
+	}
+	for _, action := range []string{"edited", "deleted", ""} {
+		if _, ok := eventSlashCommand(&ghevent.Event{Action: action, Number: 4, Comment: comment}); ok {
+			t.Fatalf("expected %q comment event to be ignored", action)
+		}
+	}
+	bot := &ghevent.Comment{ID: 2, Body: "/evolver retry", Author: "evolver[bot]"}
+	if _, ok := eventSlashCommand(&ghevent.Event{Action: "created", Number: 4, Comment: bot}); ok {
+		t.Fatalf("expected bot comment to be ignored")
+	}
+}
+
+func TestPermissionAllows(t *testing.T) {
+	if !permissionAllows("maintain", "write") || !permissionAllows("write", "write") {
+		t.Fatalf("expected maintain and write to pass a write requirement")
+	}
+	if permissionAllows("triage", "write") || permissionAllows("none", "read") || permissionAllows("", "read") {
+		t.Fatalf("expected lower roles to be denied")
+	}
+}
+
+func TestRunSlashCommandRepliesWithoutPullRequest(t *testing.T) {
+	cfg := &config.Config{}
+	for _, name := range []string{"rebase", "explain", "stop"} {
+		text, err := runSlashCommand(cfg, slashCommand{Name: name}, nil)
+		if err != nil || !strings.Contains(text, "only works on evolver pull requests") {
+			t.Fatalf("%s: unexpected reply %q (%v)", name, text, err)
+		}
+	}
+	if text, _ := runSlashCommand(cfg, slashCommand{Name: "goal"}, nil); !strings.Contains(text, "Usage") {
+		t.Fatalf("expected usage for empty goal, got %q", text)
+	}
+	if text, _ := runSlashCommand(cfg, slashCommand{Name: "dance"}, nil); !strings.Contains(text, "Unknown command") {
+		t.Fatalf("expected unknown command reply, got %q", text)
+	}
+}
+
+func TestSlashCommandReplyIsMarked(t *testing.T) {
+	got := slashCommandReply("alice", slashCommand{Name: "goal", Arg: "tidy"}, "Done: tidy\n")
+	if !strings.HasPrefix(got, "> @alice: `/evolver goal tidy`\n\nDone: tidy") || reviewFeedback(got) {
+		t.Fatalf("unexpected reply %q", got)
+	}
+	if got := runResultReply(runResult{changed: true, summary: "Tidy", prURL: "https://example/pr/1"}); got != "Done: Tidy\n\nhttps://example/pr/1" {
+		t.Fatalf("unexpected result reply %q", got)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/ghapi"
+	"github.com/mmrzaf/evolver/internal/repoctx"
+)
+
+// failedIssueLabel marks an issue a run could not resolve. Labeled issues
+// are skipped until someone removes the label.
+const failedIssueLabel = "evolver:failed"
+
+var closesIssue = regexp.MustCompile(`(?i)\bcloses #(\d+)\b`)
+
+// selectIssueTask picks the issue for this run under tasks.source issues,
+// or returns nil when there is none to work on.
+func selectIssueTask(cfg *config.Config) (*repoctx.Task, error) {
+	issues, err := ghapi.ListOpenIssues(cfg.Tasks.Label)
+	if err != nil {
+		return nil, err
+	}
+	prs, err := ghapi.ListOpenPRs()
+	if err != nil {
+		return nil, err
+	}
+	issue := pickIssue(issues, cfg.Tasks.PriorityLabels, closedByPRs(evolverPRs(prs, cfg.PR.BranchPrefix)))
+	if issue == nil {
+		return nil, nil
+	}
+	comments, err := ghapi.ListIssueComments(issue.Number)
+	if err != nil {
+		return nil, err
+	}
+	return issueTask(*issue, comments), nil
+}
+
+// closedByPRs returns the issues that open pull requests already close.
+func closedByPRs(prs []ghapi.PullRequest) map[int]bool {
+	out := make(map[int]bool)
+	for _, pr := range prs {
+		for _, m := range closesIssue.FindAllStringSubmatch(pr.Body, -1) {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				out[n] = true
+			}
+		}
+	}
+	return out
+}
+
+// pickIssue returns the highest-priority, then oldest, issue that is not in
+// progress and has not failed before.
+func pickIssue(issues []ghapi.Issue, priorityLabels []string, inProgress map[int]bool) *ghapi.Issue {
+	rank := func(i ghapi.Issue) int {
+		for r, l := range priorityLabels {
+			if i.HasLabel(l) {
+				return r
+			}
+		}
+		return len(priorityLabels)
+	}
+	var best *ghapi.Issue
+	for i := range issues {
+		is := &issues[i]
+		if inProgress[is.Number] || is.HasLabel(failedIssueLabel) {
+			continue
+		}
+		if best == nil || rank(*is) < rank(*best) || (rank(*is) == rank(*best) && is.CreatedAt.Before(best.CreatedAt)) {
+			best = is
+		}
+	}
+	return best
+}
+
+// issueTask builds the prompt task from an issue and its discussion,
+// leaving out bots and evolver's own comments.
+func issueTask(issue ghapi.Issue, comments []ghapi.Comment) *repoctx.Task {
+	t := &repoctx.Task{Issue: issue.Number, Title: issue.Title, Body: trimForPrompt(issue.Body, 8000)}
+	for _, c := range comments {
+		if strings.EqualFold(c.User.Type, "Bot") || strings.Contains(c.Body, "<!-- evolver:") || strings.TrimSpace(c.Body) == "" {
+			continue
+		}
+		if len(t.Comments) == 20 {
+			break
+		}
+		t.Comments = append(t.Comments, c.User.Login+": "+trimForPrompt(c.Body, 2000))
+	}
+	return t
+}
+
+// reportIssueOutcome comments on the task's issue with the pull request or
+// why the run did not resolve it; failures also get failedIssueLabel.
+func reportIssueOutcome(task *repoctx.Task, res runResult, runErr error) {
+	body, failed := issueOutcomeComment(res, runErr)
+	if body == "" {
+		return
+	}
+	if err := ghapi.CreateIssueComment(task.Issue, body); err != nil {
+		slog.Warn("commenting on issue failed", "issue", task.Issue, "error", err)
+	}
+	if failed {
+		if err := ghapi.AddLabels(task.Issue, []string{failedIssueLabel}); err != nil {
+			slog.Warn("labeling failed issue failed", "issue", task.Issue, "error", err)
+		}
+	}
+}
+
+// issueOutcomeComment returns the comment for a run's outcome, or "" for a
+// run that was skipped before working on the issue.
+func issueOutcomeComment(res runResult, runErr error) (string, bool) {
+	const marker = "\n\n<!-- evolver:task -->"
+	switch {
+	case runErr != nil:
+		return fmt.Sprintf("evolver could not resolve this issue:\n\n```\n%s\n```\n\nRemove the `%s` label to let it try again.%s", trimForPrompt(runErr.Error(), 2000), failedIssueLabel, marker), true
+	case res.changed && res.prURL != "":
+		return fmt.Sprintf("evolver opened %s to resolve this issue: %s%s", res.prURL, res.summary, marker), false
+	case res.changed:
+		return fmt.Sprintf("evolver pushed a change for this issue: %s%s", res.summary, marker), false
+	case strings.HasPrefix(res.summary, "Skipped"):
+		return "", false
+	}
+	return fmt.Sprintf("evolver made no changes for this issue (%s). Remove the `%s` label to let it try again.%s", res.summary, failedIssueLabel, marker), true
+}
+
+// closesLine is appended to commit messages and pull request bodies so the
+// issue closes when the change lands.
+func closesLine(task *repoctx.Task) string {
+	if task == nil {
+		return ""
+	}
+	return fmt.Sprintf("Closes #%d", task.Issue)
+}
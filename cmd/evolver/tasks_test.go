@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/ghapi"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/repoctx"
+)
+
+func TestPickIssueByPriorityThenAge(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	issues := []ghapi.Issue{
+		{Number: 1, CreatedAt: day(1)},
+		{Number: 2, CreatedAt: day(3), Labels: []ghapi.Label{{Name: "p1"}}},
+		{Number: 3, CreatedAt: day(2), Labels: []ghapi.Label{{Name: "p1"}}},
+		{Number: 4, CreatedAt: day(1), Labels: []ghapi.Label{{Name: "p0"}, {Name: failedIssueLabel}}},
+	}
+	if got := pickIssue(issues, []string{"p0", "p1"}, nil); got == nil || got.Number != 3 {
+		t.Fatalf("expected oldest p1 issue 3, got %+v", got)
+	}
+	if got := pickIssue(issues, nil, map[int]bool{1: true}); got == nil || got.Number != 3 {
+		t.Fatalf("expected oldest issue not in progress, got %+v", got)
+	}
+	if got := pickIssue(issues[3:], nil, nil); got != nil {
+		t.Fatalf("expected failed issue to be skipped, got %+v", got)
+	}
+}
+
+func TestClosedByPRs(t *testing.T) {
+	got := closedByPRs([]ghapi.PullRequest{{Body: "## Summary\nx\n\nCloses #12\n"}, {Body: "closes #7 and Closes #8"}, {Body: "see #9"}})
+	if len(got) != 3 || !got[12] || !got[7] || !got[8] {
+		t.Fatalf("unexpected closed issues: %v", got)
+	}
+}
+
+func TestIssueTaskSkipsBotsAndEvolverComments(t *testing.T) {
+	issue := ghapi.Issue{Number: 5, Title: "Add retries", Body: "The client should retry."}
+	comments := []ghapi.Comment{
+		{Body: "Also on 503s", User: ghapi.User{Login: "alice", Type: "User"}},
+		{Body: "stale", User: ghapi.User{Login: "stale[bot]", Type: "Bot"}},
+		{Body: "failed\n\n<!-- evolver:task -->", User: ghapi.User{Login: "bob", Type: "User"}},
+	}
+	task := issueTask(issue, comments)
+	if task.Issue != 5 || task.Title != "Add retries" || strings.Join(task.Comments, "|") != "alice: Also on 503s" {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+}
+
+func TestIssueOutcomeComment(t *testing.T) {
+	if body, failed := issueOutcomeComment(runResult{changed: true, summary: "Add retries", prURL: "https://example/pr/3"}, nil); failed || !strings.Contains(body, "opened https://example/pr/3") {
+		t.Fatalf("unexpected success comment %q", body)
+	}
+	if body, failed := issueOutcomeComment(runResult{}, errors.New("verification failed")); !failed || !strings.Contains(body, "verification failed") || !strings.Contains(body, "<!-- evolver:task -->") {
+		t.Fatalf("unexpected failure comment %q", body)
+	}
+	if body, failed := issueOutcomeComment(runResult{summary: "No changes proposed"}, nil); !failed || !strings.Contains(body, "no changes") {
+		t.Fatalf("unexpected no-change comment %q", body)
+	}
+	if body, _ := issueOutcomeComment(runResult{summary: "Skipped: baseline verification is failing"}, nil); body != "" {
+		t.Fatalf("expected no comment for skipped run, got %q", body)
+	}
+}
+
+func TestGeneratePRBodyClosesIssue(t *testing.T) {
+	body := generatePRBody(&plan.Plan{Summary: "Add retries"}, diffStats{}, nil, nil, nil, &repoctx.Task{Issue: 12})
+	if !strings.Contains(body, "## Summary\nAdd retries\n\nCloses #12\n\n## Stats") {
+		t.Fatalf("expected closing keyword, got %q", body)
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/apicompat"
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/coverage"
+	"github.com/mmrzaf/evolver/internal/deps"
+	"github.com/mmrzaf/evolver/internal/gitops"
+	"github.com/mmrzaf/evolver/internal/pathglob"
+	"github.com/mmrzaf/evolver/internal/policy"
+	"github.com/mmrzaf/evolver/internal/repair"
+	"github.com/mmrzaf/evolver/internal/sandbox"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+// verifier runs the verification commands followed by post-command gates, and
+// compares both against state captured from the untouched tree.
+type verifier struct {
+	cfg *config.Config
+
+	// baseline is the command report from the untouched tree (nil when disabled).
+	baseline *verify.Report
+	// coverageBefore is the coverage profile from the untouched tree.
+	coverageBefore *coverage.Profile
+	// coverageResult is the latest coverage comparison, reported in the PR body.
+	coverageResult *coverage.Comparison
+	// formatFailure is the failing formatter from the latest apply, reported
+	// by run before any command executes.
+	formatFailure *verify.CommandResult
+	// declaredBreaks are API breaks the applied plans declared as intentional.
+	declaredBreaks []string
+	// depChanges are the dependency changes found by the latest run, listed
+	// in the PR body.
+	depChanges []deps.Change
+	// depApproval holds policy violations deferred to a reviewer through
+	// dependencies.approval_label.
+	depApproval []string
+	// repairedKinds are the failure kinds repair attempts were started for.
+	repairedKinds []string
+	// transcript holds the repair attempts of the latest verifyWithRepair.
+	transcript *repair.Transcript
+	// rules are the policy rules from .evolver/policy.yml as they were
+	// before the plan was applied.
+	rules policy.Rules
+	// changelogPending is set when the plan's changelog entry is written
+	// after verification, which satisfies require_changelog.
+	changelogPending bool
+}
+
+// gate is a check that runs after all commands pass. A non-nil result is a
+// failure that flows through the repair loop like a failing command.
+type gate struct {
+	name string
+	run  func() (*verify.CommandResult, error)
+}
+
+func newVerifier(cfg *config.Config) *verifier {
+	return &verifier{cfg: cfg}
+}
+
+// forCandidate returns a verifier for evaluating one plan candidate. It
+// shares what was captured from the untouched tree and the policy rules, but
+// none of the state an evaluation sets, so a losing candidate's dependency
+// changes, coverage or declared breaks never reach the chosen plan's run.
+func (v *verifier) forCandidate() *verifier {
+	return &verifier{cfg: v.cfg, baseline: v.baseline, coverageBefore: v.coverageBefore, rules: v.rules}
+}
+
+// captureBaseline records command and coverage results for the untouched tree.
+func (v *verifier) captureBaseline() {
+	if v.cfg.Verify.Baseline {
+		opts := verifyOptions(v.cfg)
+		opts.ContinueOnFailure = true
+		// Failures are expected here and recorded rather than returned.
+		v.baseline, _ = verify.Run(v.cfg.Commands, opts)
+	}
+	if v.cfg.Coverage.Enabled {
+		profile, err := measureCoverage(v.cfg)
+		if err != nil {
+			slog.Warn("baseline coverage unavailable; only the new-file test rule applies", "error", err)
+			return
+		}
+		v.coverageBefore = profile
+		slog.Info("baseline coverage measured", "total_percent", fmt.Sprintf("%.2f", profile.Total.Percent()), "packages", len(profile.Packages))
+	}
+}
+
+// run verifies the current tree. The returned error is a *verify.CommandFailureError
+// for the first regression, whether from a command or a gate.
+func (v *verifier) run() (*verify.Report, error) {
+	if v.formatFailure != nil {
+		res := *v.formatFailure
+		res.Index, res.Total = 1, 1
+		return &verify.Report{Commands: []verify.CommandResult{res}}, &verify.CommandFailureError{Result: res}
+	}
+	report, err := v.runCommands()
+	if err != nil {
+		return report, err
+	}
+	for _, g := range v.gates() {
+		res, gerr := g.run()
+		if gerr != nil {
+			return report, fmt.Errorf("%s: %w", g.name, gerr)
+		}
+		if res == nil {
+			continue
+		}
+		res.Index = len(report.Commands) + 1
+		res.Total = res.Index
+		slog.Error("verification gate failed", "gate", g.name, "kind", res.Kind)
+		report.Commands = append(report.Commands, *res)
+		return report, &verify.CommandFailureError{Result: *res}
+	}
+	return report, nil
+}
+
+// format runs the configured formatters on the files an apply step wrote.
+// A failing formatter is kept as a format_failure for the next run instead of
+// being returned, so it goes through the repair loop.
+func (v *verifier) format(paths []string) error {
+	v.formatFailure = nil
+	for _, f := range v.cfg.Formatters {
+		matched := pathglob.Filter(f.Glob, paths)
+		if len(matched) == 0 {
+			continue
+		}
+		res, err := runFormatter(v.cfg, f, matched)
+		if err != nil {
+			return err
+		}
+		if res != nil {
+			slog.Error("formatter failed", "command", res.Command, "exit_code", res.ExitCode, "files", len(matched))
+			v.formatFailure = res
+			return nil
+		}
+		slog.Info("formatter applied", "glob", f.Glob, "argv", strings.Join(f.Argv, " "), "files", len(matched))
+	}
+	return nil
+}
+
+func runFormatter(cfg *config.Config, f config.Formatter, paths []string) (*verify.CommandResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(f.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	argv := append(append([]string{}, f.Argv...), paths...)
+	policy := sandboxPolicy(cfg)
+	cmd, err := sandbox.Command(ctx, policy, "", argv)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = sandbox.LimitOutput(policy, &stdout)
+	cmd.Stderr = sandbox.LimitOutput(policy, &stderr)
+
+	startedAt := time.Now()
+	runErr := cmd.Run()
+	if runErr == nil {
+		return nil, nil
+	}
+	res := &verify.CommandResult{
+		Command:    strings.Join(argv, " "),
+		ExitCode:   -1,
+		Stdout:     stdout.String(),
+		Stderr:     stderr.String(),
+		DurationMS: time.Since(startedAt).Milliseconds(),
+		Kind:       "format_failure",
+	}
+	var ee *exec.ExitError
+	if errors.As(runErr, &ee) {
+		res.ExitCode = ee.ExitCode()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		res.Stderr += fmt.Sprintf("\nformatter timed out after %ds", f.TimeoutSeconds)
+	} else if strings.TrimSpace(res.Stderr) == "" {
+		res.Stderr = runErr.Error()
+	}
+	return res, nil
+}
+
+// runCommands runs the configured commands. When the baseline has failures,
+// only failures that are new relative to it count.
+func (v *verifier) runCommands() (*verify.Report, error) {
+	opts := verifyOptions(v.cfg)
+	if len(v.baseline.Failures()) == 0 {
+		return verify.Run(v.cfg.Commands, opts)
+	}
+	opts.ContinueOnFailure = true
+	report, _ := verify.Run(v.cfg.Commands, opts)
+	regressions := report.NewFailures(v.baseline)
+	if len(regressions) == 0 {
+		if failures := report.Failures(); len(failures) > 0 {
+			slog.Warn("verification failures match the baseline; not treating them as regressions", "failed_commands", len(failures))
+		}
+		return report, nil
+	}
+	return report, &verify.CommandFailureError{Result: regressions[0]}
+}
+
+func (v *verifier) gates() []gate {
+	gates := []gate{{name: "dependencies", run: v.dependencyGate}}
+	if !v.rules.Empty() {
+		gates = append(gates, gate{name: "policy", run: v.policyGate})
+	}
+	if v.cfg.Coverage.Enabled {
+		gates = append(gates, gate{name: "coverage", run: v.coverageGate})
+	}
+	if v.cfg.APICompat.Enabled {
+		gates = append(gates, gate{name: "api_compat", run: v.apiCompatGate})
+	}
+	return gates
+}
+
+// apiCompatGate compares the exported API of every changed, importable Go
+// package at HEAD with the working tree.
+func (v *verifier) apiCompatGate() (*verify.CommandResult, error) {
+	changed, err := gitops.ChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for _, dir := range apiPackageDirs(changed) {
+		before, err := headPackageAPI(dir)
+		if err != nil {
+			slog.Warn("api compat: cannot read package at HEAD; skipping", "dir", dir, "error", err)
+			continue
+		}
+		if before.Package == "" || before.Package == "main" {
+			continue
+		}
+		after, err := worktreePackageAPI(dir)
+		if err != nil {
+			slog.Warn("api compat: cannot parse package; skipping", "dir", dir, "error", err)
+			continue
+		}
+		for _, c := range apicompat.Compare(before, after) {
+			sym := c.Symbol
+			if dir != "." {
+				sym = filepath.ToSlash(dir) + "." + c.Symbol
+			}
+			if v.cfg.APICompat.AllowDeclaredBreaks && containsString(v.declaredBreaks, sym) {
+				slog.Warn("declared breaking API change accepted", "symbol", sym, "change", c.Kind)
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s: %s", filepath.ToSlash(dir), c))
+		}
+	}
+	if len(problems) == 0 {
+		return nil, nil
+	}
+	return &verify.CommandResult{
+		Command:  "api compatibility gate",
+		ExitCode: 1,
+		Stdout:   strings.Join(problems, "\n"),
+		Kind:     "api_breaking_change",
+	}, nil
+}
+
+// dependencyGate diffs changed manifests and lockfiles against HEAD and checks
+// the changes against the dependencies policy. In pr mode with an approval
+// label, violations are deferred to the pull request instead of failing.
+func (v *verifier) dependencyGate() (*verify.CommandResult, error) {
+	v.depChanges, v.depApproval = nil, nil
+	changed, err := gitops.ChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range changed {
+		if !deps.Supported(f) {
+			continue
+		}
+		before, err := gitops.HeadFile(f)
+		if err != nil {
+			// Not at HEAD: the file is new in this change.
+			before = nil
+		}
+		after, err := os.ReadFile(f)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return nil, err
+			}
+			after = nil
+		}
+		changes, err := deps.Diff(filepath.ToSlash(f), before, after)
+		if err != nil {
+			return &verify.CommandResult{
+				Command:  "dependency gate",
+				ExitCode: 1,
+				Stderr:   err.Error(),
+				Kind:     "dependency_manifest_invalid",
+			}, nil
+		}
+		v.depChanges = append(v.depChanges, changes...)
+	}
+	for _, c := range v.depChanges {
+		slog.Info("dependency change", "file", c.File, "name", c.Name, "kind", c.Kind, "before", c.Before, "after", c.After)
+	}
+
+	dc := v.cfg.Dependencies
+	violations := deps.Violations(v.depChanges, deps.Policy{Allow: dc.Allow, Deny: dc.Deny, ForbidNew: dc.ForbidNew})
+	if len(violations) == 0 {
+		return nil, nil
+	}
+	if v.cfg.Mode == "pr" && dc.ApprovalLabel != "" {
+		slog.Warn("dependency policy violations need approval", "label", dc.ApprovalLabel, "violations", len(violations))
+		v.depApproval = violations
+		return nil, nil
+	}
+	return &verify.CommandResult{
+		Command:  "dependency gate",
+		ExitCode: 1,
+		Stdout:   strings.Join(violations, "\n"),
+		Kind:     "dependency_policy_violation",
+	}, nil
+}
+
+// policyGate checks the policy rules against every file changed from HEAD.
+func (v *verifier) policyGate() (*verify.CommandResult, error) {
+	changed, err := gitops.ChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]policy.FileChange, 0, len(changed))
+	for _, f := range changed {
+		c := policy.FileChange{Path: f}
+		if before, err := gitops.HeadFile(f); err == nil {
+			// Not at HEAD leaves Before nil: the file is new in this change.
+			c.Before = append([]byte{}, before...)
+		}
+		after, err := os.ReadFile(f)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		c.After = after
+		changes = append(changes, c)
+	}
+	violations := v.rules.Check(changes, v.changelogPending)
+	if len(violations) == 0 {
+		return nil, nil
+	}
+	lines := make([]string, 0, len(violations))
+	for _, vi := range violations {
+		lines = append(lines, vi.String())
+	}
+	return &verify.CommandResult{
+		Command:  "policy gate",
+		ExitCode: 1,
+		Stdout:   strings.Join(lines, "\n"),
+		Kind:     "policy_violation",
+	}, nil
+}
+
+// apiPackageDirs returns the sorted directories of changed non-test Go files
+// that hold importable packages.
+func apiPackageDirs(changed []string) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, f := range changed {
+		if !strings.HasSuffix(f, ".go") || strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		dir := filepath.Dir(f)
+		if seen[dir] || !importableDir(dir) {
+			continue
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+func importableDir(dir string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(dir), "/") {
+		if part == "internal" || part == "testdata" || part == "vendor" {
+			return false
+		}
+		if part != "." && (strings.HasPrefix(part, ".") || strings.HasPrefix(part, "_")) {
+			return false
+		}
+	}
+	return true
+}
+
+func headPackageAPI(dir string) (apicompat.API, error) {
+	names, err := gitops.HeadFiles(dir)
+	if err != nil {
+		return apicompat.API{}, err
+	}
+	files := map[string][]byte{}
+	for _, n := range names {
+		if !strings.HasSuffix(n, ".go") {
+			continue
+		}
+		b, err := gitops.HeadFile(filepath.Join(dir, n))
+		if err != nil {
+			return apicompat.API{}, err
+		}
+		files[n] = b
+	}
+	return apicompat.Extract(files)
+}
+
+func worktreePackageAPI(dir string) (apicompat.API, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return apicompat.API{}, err
+	}
+	files := map[string][]byte{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return apicompat.API{}, err
+		}
+		files[e.Name()] = b
+	}
+	return apicompat.Extract(files)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if strings.TrimSpace(v) == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *verifier) coverageGate() (*verify.CommandResult, error) {
+	var problems []string
+	if v.cfg.Coverage.RequireTests {
+		newFiles, err := gitops.NewFiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range coverage.MissingTests(newFiles) {
+			problems = append(problems, fmt.Sprintf("%s: new Go file added to a package without any _test.go file", f))
+		}
+	}
+	if v.coverageBefore != nil {
+		after, err := measureCoverage(v.cfg)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("coverage measurement failed: %v", err))
+		} else {
+			cmp := coverage.Compare(v.coverageBefore, after, coverage.Thresholds{
+				MaxTotalDrop:   v.cfg.Coverage.MaxTotalDrop,
+				MaxPackageDrop: v.cfg.Coverage.MaxPackageDrop,
+			})
+			v.coverageResult = &cmp
+			for _, w := range cmp.Warnings {
+				slog.Warn("coverage dropped within threshold", "detail", w)
+			}
+			problems = append(problems, cmp.Regressions...)
+		}
+	}
+	if len(problems) == 0 {
+		return nil, nil
+	}
+	return &verify.CommandResult{
+		Command:  "coverage gate",
+		ExitCode: 1,
+		Stdout:   strings.Join(problems, "\n"),
+		Kind:     "coverage_regression",
+	}, nil
+}
+
+// measureCoverage runs the coverage command (if any) and loads the resulting profile.
+func measureCoverage(c *config.Config) (*coverage.Profile, error) {
+	cfg := c.Coverage
+	command := strings.TrimSpace(cfg.Command)
+	format := cfg.Format
+	if command == "" {
+		if _, err := os.Stat("go.mod"); err == nil {
+			command = "go test -coverprofile=" + cfg.Profile + " ./..."
+			if format == "" {
+				format = "go"
+			}
+		}
+	}
+	if command == "" {
+		return coverage.Load(cfg.Profile, format)
+	}
+
+	if dir := filepath.Dir(cfg.Profile); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	_ = os.Remove(cfg.Profile)
+	// The profile is a by-product of verification; never let it reach a commit.
+	defer func() { _ = os.Remove(cfg.Profile) }()
+
+	opts := verifyOptions(c)
+	opts.Retries = 0
+	if _, err := verify.Run(config.Commands(command), opts); err != nil {
+		if _, statErr := os.Stat(cfg.Profile); statErr != nil {
+			return nil, err
+		}
+		slog.Warn("coverage command failed but wrote a profile; using it", "command", command, "error", err)
+	}
+	return coverage.Load(cfg.Profile, format)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+func TestFormatFailureIsReportedBeforeCommands(t *testing.T) {
+	cfg := &config.Config{
+		Commands: config.Commands("go version"),
+		Formatters: []config.Formatter{
+			{Glob: "*.md", Argv: []string{"false"}, TimeoutSeconds: 10},
+			{Glob: "*.go", Argv: []string{"sh", "-c", "echo bad syntax >&2; exit 2", "formatter"}, TimeoutSeconds: 10},
+		},
+	}
+	v := newVerifier(cfg)
+	if err := v.format([]string{"a.go", "b.txt"}); err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	report, err := v.run()
+	var cf *verify.CommandFailureError
+	if !errors.As(err, &cf) {
+		t.Fatalf("expected command failure, got %v", err)
+	}
+	if cf.Result.Kind != "format_failure" || cf.Result.ExitCode != 2 || cf.Result.Stderr != "bad syntax\n" {
+		t.Fatalf("unexpected format failure: %+v", cf.Result)
+	}
+	if len(report.Commands) != 1 || report.Commands[0].Command != "sh -c echo bad syntax >&2; exit 2 formatter a.go" {
+		t.Fatalf("unexpected report: %+v", report.Commands)
+	}
+
+	cfg.Formatters = []config.Formatter{{Glob: "*.go", Argv: []string{"true"}, TimeoutSeconds: 10}}
+	if err := v.format([]string{"a.go"}); err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	if v.formatFailure != nil {
+		t.Fatalf("expected successful format to clear the previous failure")
+	}
+}
+
+func TestAPICompatGateReportsBreaksUnlessDeclared(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v (%s)", args, err, out)
+		}
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	git("init", "-q")
+	git("config", "user.name", "tester")
+	git("config", "user.email", "tester@example.com")
+	write("pkg/client/client.go", "package client\n\nfunc Get(url string) error { return nil }\n")
+	write("internal/impl/impl.go", "package impl\n\nfunc Gone() {}\n")
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	write("pkg/client/client.go", "package client\n\nfunc Get(url string, retries int) error { return nil }\n")
+	write("internal/impl/impl.go", "package impl\n")
+
+	v := newVerifier(&config.Config{APICompat: config.APICompat{Enabled: true}})
+	res, err := v.apiCompatGate()
+	if err != nil {
+		t.Fatalf("gate: %v", err)
+	}
+	if res == nil || res.Kind != "api_breaking_change" || !strings.Contains(res.Stdout, "pkg/client: changed Get: func(string) error -> func(string, int) error") {
+		t.Fatalf("expected breaking change in pkg/client, got %+v", res)
+	}
+	if strings.Contains(res.Stdout, "internal/impl") {
+		t.Fatalf("internal packages must be ignored: %s", res.Stdout)
+	}
+
+	v.declaredBreaks = []string{"pkg/client.Get"}
+	if res, _ := v.apiCompatGate(); res == nil {
+		t.Fatalf("declared breaks must still fail unless allow_declared_breaks is set")
+	}
+	v.cfg.APICompat.AllowDeclaredBreaks = true
+	if res, err := v.apiCompatGate(); err != nil || res != nil {
+		t.Fatalf("expected declared break to be accepted, got %+v (%v)", res, err)
+	}
+}
+
+func TestPolicyGateChecksDiffFromHead(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	git := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v (%s)", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("config", "user.name", "tester")
+	git("config", "user.email", "tester@example.com")
+	if err := os.WriteFile("a.go", []byte("package a\n\n// TODO: old\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	if err := os.WriteFile("a.go", []byte("package a\n\n// TODO: old\n// TODO: new\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	v := newVerifier(&config.Config{})
+	v.rules.ForbidTODO = true
+	if len(v.gates()) != 2 {
+		t.Fatalf("expected the policy gate to be enabled by rules")
+	}
+	res, err := v.policyGate()
+	if err != nil {
+		t.Fatalf("gate: %v", err)
+	}
+	if res == nil || res.Kind != "policy_violation" || res.Stdout != "a.go:4: forbid_todo: TODO introduced" {
+		t.Fatalf("expected policy violation, got %+v", res)
+	}
+}
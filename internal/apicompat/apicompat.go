@@ -0,0 +1,252 @@
+// Package apicompat extracts the exported API of a Go package from source and
+// reports incompatible changes between two versions of it.
+//
+// Extraction is syntactic: it sees declared funcs, methods, types, struct
+// fields, interface method sets, vars and consts, but not methods promoted
+// through embedding.
+package apicompat
+
+import (
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// API is the exported surface of one package: symbol → signature. Methods and
+// struct fields use "Type.Name" keys.
+type API struct {
+	Package string
+	Symbols map[string]string
+}
+
+// Change is an incompatible difference between two API versions.
+type Change struct {
+	Symbol string
+	// Kind is "removed" or "changed".
+	Kind   string
+	Before string
+	After  string
+}
+
+func (c Change) String() string {
+	if c.Kind == "removed" {
+		return fmt.Sprintf("removed %s (%s)", c.Symbol, c.Before)
+	}
+	return fmt.Sprintf("changed %s: %s -> %s", c.Symbol, c.Before, c.After)
+}
+
+// Extract returns the exported API of the package formed by files (base name →
+// source). Test files and files excluded by build constraints for the current
+// platform are ignored. An empty file set yields an empty API.
+func Extract(files map[string][]byte) (API, error) {
+	api := API{Symbols: map[string]string{}}
+	ctx := build.Default
+	ctx.OpenFile = func(path string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(string(files[filepath.Base(path)]))), nil
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fset := token.NewFileSet()
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if ok, err := ctx.MatchFile(".", name); err != nil || !ok {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, files[name], parser.SkipObjectResolution)
+		if err != nil {
+			return api, err
+		}
+		if api.Package == "" {
+			api.Package = f.Name.Name
+		}
+		collect(f, api.Symbols)
+	}
+	return api, nil
+}
+
+// Compare lists symbols of before that are missing or have a different
+// signature in after. Additions are compatible and not reported.
+func Compare(before, after API) []Change {
+	var out []Change
+	for sym, sig := range before.Symbols {
+		now, ok := after.Symbols[sym]
+		switch {
+		case !ok:
+			out = append(out, Change{Symbol: sym, Kind: "removed", Before: sig})
+		case now != sig:
+			out = append(out, Change{Symbol: sym, Kind: "changed", Before: sig, After: now})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out
+}
+
+func collect(f *ast.File, syms map[string]string) {
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			collectFunc(d, syms)
+		case *ast.GenDecl:
+			collectGen(d, syms)
+		}
+	}
+}
+
+func collectFunc(d *ast.FuncDecl, syms map[string]string) {
+	if !d.Name.IsExported() {
+		return
+	}
+	if d.Recv == nil || len(d.Recv.List) == 0 {
+		syms[d.Name.Name] = "func" + typeParams(d.Type.TypeParams) + signature(d.Type)
+		return
+	}
+	recv := d.Recv.List[0].Type
+	ptr := ""
+	if star, ok := recv.(*ast.StarExpr); ok {
+		ptr = "*"
+		recv = star.X
+	}
+	base := receiverBase(types.ExprString(recv))
+	if base == "" || !ast.IsExported(base) {
+		return
+	}
+	syms[base+"."+d.Name.Name] = "method (" + ptr + base + ")" + signature(d.Type)
+}
+
+func collectGen(d *ast.GenDecl, syms map[string]string) {
+	// Constants without a type repeat the previous spec's type within a group.
+	var lastType ast.Expr
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			if s.Name.IsExported() {
+				collectType(s, syms)
+			}
+		case *ast.ValueSpec:
+			typ := s.Type
+			if d.Tok == token.CONST {
+				if typ == nil && len(s.Values) == 0 {
+					typ = lastType
+				}
+				lastType = typ
+			}
+			for _, n := range s.Names {
+				if !n.IsExported() {
+					continue
+				}
+				sig := d.Tok.String()
+				if typ != nil {
+					sig += " " + types.ExprString(typ)
+				}
+				syms[n.Name] = sig
+			}
+		}
+	}
+}
+
+func collectType(s *ast.TypeSpec, syms map[string]string) {
+	name := s.Name.Name
+	tparams := typeParams(s.TypeParams)
+	if s.Assign.IsValid() {
+		syms[name] = "type" + tparams + " = " + types.ExprString(s.Type)
+		return
+	}
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		syms[name] = "type" + tparams + " struct"
+		for _, field := range t.Fields.List {
+			ft := types.ExprString(field.Type)
+			if len(field.Names) == 0 {
+				if n := receiverBase(strings.TrimPrefix(ft, "*")); n != "" && ast.IsExported(n) {
+					syms[name+"."+n] = "field " + ft
+				}
+				continue
+			}
+			for _, n := range field.Names {
+				if n.IsExported() {
+					syms[name+"."+n.Name] = "field " + ft
+				}
+			}
+		}
+	case *ast.InterfaceType:
+		// Any change to an interface's method set breaks implementations or callers.
+		var elems []string
+		for _, m := range t.Methods.List {
+			if ft, ok := m.Type.(*ast.FuncType); ok && len(m.Names) > 0 {
+				for _, n := range m.Names {
+					elems = append(elems, n.Name+signature(ft))
+				}
+				continue
+			}
+			elems = append(elems, types.ExprString(m.Type))
+		}
+		sort.Strings(elems)
+		syms[name] = "type" + tparams + " interface{" + strings.Join(elems, "; ") + "}"
+	default:
+		syms[name] = "type" + tparams + " " + types.ExprString(s.Type)
+	}
+}
+
+// receiverBase returns the type name of a rendered receiver or embedded field
+// type such as T, T[K] or pkg.T.
+func receiverBase(s string) string {
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.LastIndexByte(s, '.'); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// signature renders a func type's parameter and result types without names.
+func signature(ft *ast.FuncType) string {
+	s := "(" + fieldTypes(ft.Params) + ")"
+	if ft.Results == nil || len(ft.Results.List) == 0 {
+		return s
+	}
+	res := fieldTypes(ft.Results)
+	if len(ft.Results.List) == 1 && len(ft.Results.List[0].Names) <= 1 {
+		return s + " " + res
+	}
+	return s + " (" + res + ")"
+}
+
+func typeParams(fl *ast.FieldList) string {
+	if fl == nil || len(fl.List) == 0 {
+		return ""
+	}
+	return "[" + fieldTypes(fl) + "]"
+}
+
+func fieldTypes(fl *ast.FieldList) string {
+	if fl == nil {
+		return ""
+	}
+	var parts []string
+	for _, f := range fl.List {
+		t := types.ExprString(f.Type)
+		n := len(f.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			parts = append(parts, t)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,84 @@
+package apicompat
+
+import (
+	"strings"
+	"testing"
+)
+
+const before = `package lib
+
+type Client struct {
+	Name    string
+	Timeout int
+	secret  string
+}
+
+func (c *Client) Do(path string, retries int) error { return nil }
+func (c Client) String() string { return c.Name }
+
+type Doer interface {
+	Do(path string, retries int) error
+}
+
+type Level int
+
+const (
+	Low Level = iota
+	High
+)
+
+var Default = &Client{}
+
+func New(name string) *Client { return &Client{Name: name} }
+func Keep[T any](v T) T { return v }
+func helper() {}
+`
+
+func TestCompareReportsRemovedAndChangedSymbols(t *testing.T) {
+	after := strings.NewReplacer(
+		"func New(name string) *Client", "func New(name string, timeout int) *Client",
+		"Timeout int", "",
+		"func (c Client) String()", "func (c *Client) String()",
+		"Do(path string, retries int) error\n}", "Do(path string, retries int) error\n\tClose() error\n}",
+		"\tHigh\n", "\tHigh\n\tHighest\n",
+		"func helper() {}", "func helper(x int) {}\nfunc Added() {}",
+	).Replace(before)
+
+	b, err := Extract(map[string][]byte{"lib.go": []byte(before)})
+	if err != nil {
+		t.Fatalf("extract before: %v", err)
+	}
+	a, err := Extract(map[string][]byte{"lib.go": []byte(after)})
+	if err != nil {
+		t.Fatalf("extract after: %v", err)
+	}
+	if b.Package != "lib" || b.Symbols["High"] != "const Level" {
+		t.Fatalf("unexpected API: %+v", b)
+	}
+
+	var got []string
+	for _, c := range Compare(b, a) {
+		got = append(got, c.Kind+" "+c.Symbol)
+	}
+	want := []string{"changed Client.String", "removed Client.Timeout", "changed Doer", "changed New"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected changes:\n got  %v\n want %v", got, want)
+	}
+}
+
+func TestExtractIgnoresTestsAndExcludedFiles(t *testing.T) {
+	api, err := Extract(map[string][]byte{
+		"a.go":      []byte("package lib\n\nfunc A() {}\n"),
+		"a_test.go": []byte("package lib\n\nfunc TestOnly() {}\n"),
+		"gen.go":    []byte("//go:build ignore\n\npackage main\n\nfunc Gen() {}\n"),
+	})
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if api.Package != "lib" || len(api.Symbols) != 1 || api.Symbols["A"] != "func()" {
+		t.Fatalf("unexpected API: %+v", api)
+	}
+	if changes := Compare(api, API{Symbols: map[string]string{}}); len(changes) != 1 || changes[0].Kind != "removed" {
+		t.Fatalf("expected removal when the package disappears, got %v", changes)
+	}
+}
@@ -10,32 +10,33 @@ import (
 	"github.com/mmrzaf/evolver/internal/plan"
 )
 
-// Execute applies write operations from a generated plan.
-func Execute(p *plan.Plan) error {
-	writes := 0
+// Execute applies write operations from a generated plan and returns the
+// cleaned paths it wrote, in plan order.
+func Execute(p *plan.Plan) ([]string, error) {
+	var written []string
 	for _, f := range p.Files {
 		if f.Mode != "write" {
 			continue
 		}
 		cleanPath, err := safeRelPath(f.Path)
 		if err != nil {
-			return fmt.Errorf("refusing to write unsafe path %q: %w", f.Path, err)
+			return written, fmt.Errorf("refusing to write unsafe path %q: %w", f.Path, err)
 		}
 
 		dir := filepath.Dir(cleanPath)
 		if dir != "." {
 			if err := os.MkdirAll(dir, 0755); err != nil {
-				return err
+				return written, err
 			}
 		}
 		if err := os.WriteFile(cleanPath, []byte(f.Content), 0644); err != nil {
-			return err
+			return written, err
 		}
-		writes++
+		written = append(written, cleanPath)
 		slog.Debug("applied file write", "path", cleanPath, "bytes", len(f.Content))
 	}
-	slog.Info("plan applied", "files_written", writes)
-	return nil
+	slog.Info("plan applied", "files_written", len(written))
+	return written, nil
 }
 
 func safeRelPath(p string) (string, error) {
@@ -25,10 +25,14 @@ func TestExecuteWritesFilesAndIsRepeatable(t *testing.T) {
 			{Path: "skip.txt", Mode: "delete", Content: "ignored"},
 		},
 	}
-	if err := Execute(p); err != nil {
+	written, err := Execute(p)
+	if err != nil {
 		t.Fatalf("execute first run: %v", err)
 	}
-	if err := Execute(p); err != nil {
+	if len(written) != 1 || written[0] != filepath.Join("a", "b", "c.txt") {
+		t.Fatalf("unexpected written paths: %v", written)
+	}
+	if _, err := Execute(p); err != nil {
 		t.Fatalf("execute second run should also succeed: %v", err)
 	}
 
@@ -58,7 +62,7 @@ func TestExecuteRejectsTraversalPath(t *testing.T) {
 	p := &plan.Plan{
 		Files: []plan.File{{Path: "../../pwned", Mode: "write", Content: "x"}},
 	}
-	if err := Execute(p); err == nil {
+	if _, err := Execute(p); err == nil {
 		t.Fatalf("expected unsafe path to be rejected")
 	}
 }
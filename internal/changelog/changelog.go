@@ -0,0 +1,212 @@
+// Package changelog keeps CHANGELOG.md in Keep a Changelog form: entries go
+// under "## [Unreleased]" in their category, one normalised line each.
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Categories are the Keep a Changelog change types, in the order their
+// sections appear.
+var Categories = []string{"Added", "Changed", "Deprecated", "Removed", "Fixed", "Security"}
+
+// DefaultCategory is used for entries that do not name one.
+const DefaultCategory = "Changed"
+
+// maxEntryLen bounds an entry's text in runes.
+const maxEntryLen = 300
+
+var (
+	releaseRe    = regexp.MustCompile(`^##\s+`)
+	unreleasedRe = regexp.MustCompile(`(?i)^##\s+\[?unreleased\]?\s*$`)
+	categoryRe   = regexp.MustCompile(`^###\s+(.+?)\s*$`)
+	datePrefixRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}:\s*`)
+	runSuffixRe  = regexp.MustCompile(`\s*\(mode:[^)]*\)$`)
+)
+
+// Entry is a validated changelog entry from a plan.
+type Entry struct {
+	Category string
+	Text     string
+}
+
+// ParseEntry validates a plan's changelog entry and category. The text may
+// carry the "- " bullet, a "YYYY-MM-DD:" date and a "(mode: ...)" suffix;
+// they are dropped and written again by Line. Empty text means no entry and
+// returns a zero Entry.
+func ParseEntry(category, text string) (Entry, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Entry{}, nil
+	}
+	if strings.ContainsAny(text, "\n\r") {
+		return Entry{}, fmt.Errorf("changelog entry must be a single line")
+	}
+	for _, bullet := range []string{"- ", "* "} {
+		text = strings.TrimSpace(strings.TrimPrefix(text, bullet))
+	}
+	text = datePrefixRe.ReplaceAllString(text, "")
+	text = strings.TrimSpace(runSuffixRe.ReplaceAllString(text, ""))
+	switch {
+	case text == "":
+		return Entry{}, fmt.Errorf("changelog entry has no text")
+	case strings.HasPrefix(text, "#"):
+		return Entry{}, fmt.Errorf("changelog entry must not be a heading: %q", text)
+	case utf8.RuneCountInString(text) > maxEntryLen:
+		return Entry{}, fmt.Errorf("changelog entry is longer than %d characters", maxEntryLen)
+	}
+
+	cat := DefaultCategory
+	if c := strings.TrimSpace(category); c != "" {
+		cat = ""
+		for _, known := range Categories {
+			if strings.EqualFold(c, known) {
+				cat = known
+			}
+		}
+		if cat == "" {
+			return Entry{}, fmt.Errorf("unknown changelog category %q (want one of %s)", category, strings.Join(Categories, ", "))
+		}
+	}
+	return Entry{Category: cat, Text: text}, nil
+}
+
+// Line renders the entry as "- YYYY-MM-DD: text (mode: m, commands: c)".
+func (e Entry) Line(date time.Time, mode, commands string) string {
+	return fmt.Sprintf("- %s: %s (mode: %s, commands: %s)", date.Format("2006-01-02"), e.Text, mode, commands)
+}
+
+// Changelog is a parsed CHANGELOG.md. Only the Unreleased section is
+// modelled; everything around it is kept as-is.
+type Changelog struct {
+	head, tail []string
+	// intro holds Unreleased lines before its first category.
+	intro  []string
+	groups []*group
+}
+
+type group struct {
+	name  string
+	lines []string
+}
+
+// Parse reads changelog content. Without an Unreleased section, one is
+// added before the first release.
+func Parse(content string) *Changelog {
+	c := &Changelog{}
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		lines = nil
+	}
+	start := -1
+	for i, l := range lines {
+		if unreleasedRe.MatchString(l) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		at := len(lines)
+		for i, l := range lines {
+			if releaseRe.MatchString(l) {
+				at = i
+				break
+			}
+		}
+		c.head, c.tail = lines[:at], lines[at:]
+		return c
+	}
+	c.head = lines[:start]
+	var cur *group
+	for i := start + 1; i < len(lines); i++ {
+		l := lines[i]
+		if releaseRe.MatchString(l) {
+			c.tail = lines[i:]
+			break
+		}
+		if m := categoryRe.FindStringSubmatch(l); m != nil {
+			cur = &group{name: m[1]}
+			c.groups = append(c.groups, cur)
+			continue
+		}
+		if cur == nil {
+			c.intro = append(c.intro, l)
+		} else {
+			cur.lines = append(cur.lines, l)
+		}
+	}
+	return c
+}
+
+// Add appends line to category under Unreleased, creating the category
+// section in Keep a Changelog order when it is missing.
+func (c *Changelog) Add(category, line string) {
+	for _, g := range c.groups {
+		if strings.EqualFold(g.name, category) {
+			g.lines = append(trimBlank(g.lines), line)
+			return
+		}
+	}
+	rank := func(name string) int {
+		for i, known := range Categories {
+			if strings.EqualFold(name, known) {
+				return i
+			}
+		}
+		return len(Categories)
+	}
+	at := len(c.groups)
+	for i, g := range c.groups {
+		if rank(g.name) > rank(category) {
+			at = i
+			break
+		}
+	}
+	c.groups = append(c.groups, nil)
+	copy(c.groups[at+1:], c.groups[at:])
+	c.groups[at] = &group{name: category, lines: []string{line}}
+}
+
+// Unreleased renders the Unreleased section.
+func (c *Changelog) Unreleased() string {
+	var b strings.Builder
+	b.WriteString("## [Unreleased]\n")
+	if intro := trimBlank(c.intro); len(intro) > 0 {
+		b.WriteString("\n" + strings.Join(intro, "\n") + "\n")
+	}
+	for _, g := range c.groups {
+		fmt.Fprintf(&b, "\n### %s\n", g.name)
+		if lines := trimBlank(g.lines); len(lines) > 0 {
+			b.WriteString(strings.Join(lines, "\n") + "\n")
+		}
+	}
+	return b.String()
+}
+
+// String renders the changelog with one blank line around each section.
+func (c *Changelog) String() string {
+	var b strings.Builder
+	if head := trimBlank(c.head); len(head) > 0 {
+		b.WriteString(strings.Join(head, "\n") + "\n\n")
+	}
+	b.WriteString(c.Unreleased())
+	if tail := trimBlank(c.tail); len(tail) > 0 {
+		b.WriteString("\n" + strings.Join(tail, "\n") + "\n")
+	}
+	return b.String()
+}
+
+// trimBlank drops leading and trailing blank lines.
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
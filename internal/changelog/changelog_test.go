@@ -0,0 +1,68 @@
+package changelog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseEntryNormalisesAndRejects(t *testing.T) {
+	e, err := ParseEntry("fixed", "- 2026-01-02: Retry on 503 (mode: pr, commands: pass)")
+	if err != nil {
+		t.Fatalf("parse entry: %v", err)
+	}
+	if e != (Entry{Category: "Fixed", Text: "Retry on 503"}) {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	date := time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)
+	if got := e.Line(date, "push", "none"); got != "- 2026-03-04: Retry on 503 (mode: push, commands: none)" {
+		t.Fatalf("unexpected line: %q", got)
+	}
+	if e, err := ParseEntry("", "Tidy logging"); err != nil || e.Category != DefaultCategory {
+		t.Fatalf("expected default category, got %+v (%v)", e, err)
+	}
+	if e, err := ParseEntry("Added", "  "); err != nil || e != (Entry{}) {
+		t.Fatalf("expected empty entry to mean none, got %+v (%v)", e, err)
+	}
+
+	for _, tc := range []struct{ category, text string }{
+		{"", "one\ntwo"},
+		{"", "## [2.0.0]"},
+		{"", "- 2026-01-02:"},
+		{"", strings.Repeat("x", maxEntryLen+1)},
+		{"Improved", "Faster startup"},
+	} {
+		if _, err := ParseEntry(tc.category, tc.text); err == nil {
+			t.Fatalf("expected %q/%q to be rejected", tc.category, tc.text)
+		}
+	}
+}
+
+func TestAddKeepsCategoryOrderAndReleases(t *testing.T) {
+	in := "# Changelog\n\nAll notable changes.\n\n## [Unreleased]\n\n### Added\n- a\n\n### Fixed\n- f\n\n## [1.0.0] - 2026-02-19\n\n### Added\n- initial\n"
+	c := Parse(in)
+	if got := c.String(); got != in {
+		t.Fatalf("expected round trip, got %q", got)
+	}
+	c.Add("Changed", "- c")
+	c.Add("Added", "- a2")
+	c.Add("Security", "- s")
+	want := "# Changelog\n\nAll notable changes.\n\n## [Unreleased]\n\n### Added\n- a\n- a2\n\n### Changed\n- c\n\n### Fixed\n- f\n\n### Security\n- s\n\n## [1.0.0] - 2026-02-19\n\n### Added\n- initial\n"
+	if got := c.String(); got != want {
+		t.Fatalf("unexpected changelog:\n%s", got)
+	}
+}
+
+func TestParseAddsMissingUnreleased(t *testing.T) {
+	c := Parse("# CHANGELOG\n\n- 2026-01-01: old free-form entry\n\n## [1.0.0]\n- initial\n")
+	c.Add("Fixed", "- f")
+	want := "# CHANGELOG\n\n- 2026-01-01: old free-form entry\n\n## [Unreleased]\n\n### Fixed\n- f\n\n## [1.0.0]\n- initial\n"
+	if got := c.String(); got != want {
+		t.Fatalf("unexpected changelog: %q", got)
+	}
+	c = Parse("")
+	c.Add("Added", "- a")
+	if got := c.String(); got != "## [Unreleased]\n\n### Added\n- a\n" {
+		t.Fatalf("unexpected changelog from empty file: %q", got)
+	}
+}
@@ -0,0 +1,95 @@
+// Package codeowners reads GitHub CODEOWNERS files and resolves the owners of
+// repository paths.
+package codeowners
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mmrzaf/evolver/internal/pathglob"
+)
+
+// Locations are where GitHub looks for a CODEOWNERS file, in order.
+var Locations = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Rule assigns owners to paths matching Pattern.
+type Rule struct {
+	Pattern string
+	Owners  []string
+}
+
+// File is a parsed CODEOWNERS file.
+type File struct {
+	Rules []Rule
+}
+
+// Load reads the first CODEOWNERS file found under root. It returns nil and no
+// error when there is none.
+func Load(root string) (*File, error) {
+	for _, loc := range Locations {
+		b, err := os.ReadFile(filepath.Join(root, loc))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return Parse(b), nil
+	}
+	return nil, nil
+}
+
+// Parse parses CODEOWNERS content. Comments, blank lines and section headers
+// are skipped.
+func Parse(b []byte) *File {
+	f := &File{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") {
+			continue
+		}
+		rule := Rule{Pattern: fields[0]}
+		if len(fields) > 1 {
+			rule.Owners = fields[1:]
+		}
+		f.Rules = append(f.Rules, rule)
+	}
+	return f
+}
+
+// Owners returns the owners of path (relative to the repository root). As on
+// GitHub, the last matching rule wins; a rule without owners clears them.
+func (f *File) Owners(path string) []string {
+	if f == nil {
+		return nil
+	}
+	for i := len(f.Rules) - 1; i >= 0; i-- {
+		if pathglob.Match(f.Rules[i].Pattern, path) {
+			return f.Rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// Reviewers splits owners into GitHub users and team slugs that can be asked
+// for review. Email owners are dropped since the API cannot request them.
+func Reviewers(owners []string) (users, teams []string) {
+	seen := map[string]bool{}
+	for _, o := range owners {
+		if !strings.HasPrefix(o, "@") || seen[o] {
+			continue
+		}
+		seen[o] = true
+		name := strings.TrimPrefix(o, "@")
+		if _, team, ok := strings.Cut(name, "/"); ok {
+			teams = append(teams, team)
+			continue
+		}
+		users = append(users, name)
+	}
+	return users, teams
+}
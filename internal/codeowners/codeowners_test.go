@@ -0,0 +1,51 @@
+package codeowners
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOwnersLastMatchWins(t *testing.T) {
+	f := Parse([]byte(`# Default owners
+*       @acme/core
+/docs/  @writer docs@example.com # docs team
+*.go    @gopher
+/internal/generated/
+`))
+	for path, want := range map[string][]string{
+		"README.md":               {"@acme/core"},
+		"docs/guide.md":           {"@writer", "docs@example.com"},
+		"internal/core/x.go":      {"@gopher"},
+		"internal/generated/x.go": nil,
+	} {
+		if got := f.Owners(path); !reflect.DeepEqual(got, want) {
+			t.Errorf("Owners(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestReviewersSplitsUsersAndTeams(t *testing.T) {
+	users, teams := Reviewers([]string{"@gopher", "@acme/core", "docs@example.com", "@gopher"})
+	if !reflect.DeepEqual(users, []string{"gopher"}) || !reflect.DeepEqual(teams, []string{"core"}) {
+		t.Fatalf("unexpected reviewers: users=%v teams=%v", users, teams)
+	}
+}
+
+func TestLoadFindsGithubDirectory(t *testing.T) {
+	root := t.TempDir()
+	if f, err := Load(root); err != nil || f != nil {
+		t.Fatalf("expected no CODEOWNERS, got %v, %v", f, err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, ".github"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".github", "CODEOWNERS"), []byte("* @owner\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	f, err := Load(root)
+	if err != nil || f == nil || len(f.Rules) != 1 {
+		t.Fatalf("expected one rule, got %+v, %v", f, err)
+	}
+}
@@ -10,26 +10,244 @@ import (
 
 // Config controls runtime behavior for the evolver.
 type Config struct {
-	Provider    string      `yaml:"provider"`
-	Mode        string      `yaml:"mode"`
-	Model       string      `yaml:"model"`
-	RepoGoal    string      `yaml:"repo_goal,omitempty"`
-	Workdir     string      `yaml:"workdir"`
-	Budgets     Budgets     `yaml:"budgets"`
-	Commands    []string    `yaml:"commands"`
-	AllowPaths  []string    `yaml:"allow_paths"`
-	DenyPaths   []string    `yaml:"deny_paths"`
-	Security    Security    `yaml:"security"`
-	Reliability Reliability `yaml:"reliability"`
-	Logging     Logging     `yaml:"logging"`
-	Repair      Repair      `yaml:"repair"`
-}
-
-// Budgets limits the size of generated changes.
+	Provider     string       `yaml:"provider"`
+	Mode         string       `yaml:"mode"`
+	Model        string       `yaml:"model"`
+	RepoGoal     string       `yaml:"repo_goal,omitempty"`
+	Workdir      string       `yaml:"workdir"`
+	Budgets      Budgets      `yaml:"budgets"`
+	Planning     Planning     `yaml:"planning"`
+	Tasks        Tasks        `yaml:"tasks"`
+	Commands     []Command    `yaml:"commands"`
+	Verify       Verify       `yaml:"verify"`
+	Coverage     Coverage     `yaml:"coverage"`
+	Formatters   []Formatter  `yaml:"formatters"`
+	Precheck     Precheck     `yaml:"precheck"`
+	APICompat    APICompat    `yaml:"api_compat"`
+	Dependencies Dependencies `yaml:"dependencies"`
+	AllowPaths   []string     `yaml:"allow_paths"`
+	DenyPaths    []string     `yaml:"deny_paths"`
+	// ProtectedPaths are gitignore-style globs of files plans may not edit.
+	// Files with an evolver:protected or evolver:do-not-edit marker comment
+	// near the top are protected as well.
+	ProtectedPaths []string      `yaml:"protected_paths"`
+	Protection     Protection    `yaml:"protection"`
+	PR             PR            `yaml:"pr"`
+	SlashCommands  SlashCommands `yaml:"slash_commands"`
+	Security       Security      `yaml:"security"`
+	Sandbox        Sandbox       `yaml:"sandbox"`
+	Reliability    Reliability   `yaml:"reliability"`
+	Logging        Logging       `yaml:"logging"`
+	Repair         Repair        `yaml:"repair"`
+}
+
+// Budgets limits the size of generated changes. The max_* limits count every
+// changed file except those under an unlimited path budget.
 type Budgets struct {
 	MaxFilesChanged int `yaml:"max_files_changed"`
 	MaxLinesChanged int `yaml:"max_lines_changed"`
 	MaxNewFiles     int `yaml:"max_new_files"`
+	// MaxAddedLines and MaxDeletedLines are optional; 0 means no limit.
+	MaxAddedLines   int `yaml:"max_added_lines"`
+	MaxDeletedLines int `yaml:"max_deleted_lines"`
+	// Tests and Code limit test files and all other files separately.
+	Tests BudgetLimits `yaml:"tests"`
+	Code  BudgetLimits `yaml:"code"`
+	// Paths are budgets for files matching a gitignore-style glob, relative to
+	// the repository root. Each file counts toward the first rule it matches.
+	Paths []PathBudget `yaml:"paths"`
+}
+
+// BudgetLimits is a set of optional limits; 0 means no limit.
+type BudgetLimits struct {
+	MaxFiles        int `yaml:"max_files"`
+	MaxLines        int `yaml:"max_lines"`
+	MaxAddedLines   int `yaml:"max_added_lines"`
+	MaxDeletedLines int `yaml:"max_deleted_lines"`
+	MaxNewFiles     int `yaml:"max_new_files"`
+}
+
+// PathBudget limits changes to the files matching Glob. Unlimited files are
+// exempt from every other budget.
+type PathBudget struct {
+	Glob         string `yaml:"glob"`
+	Unlimited    bool   `yaml:"unlimited"`
+	BudgetLimits `yaml:",inline"`
+}
+
+// Planning configures how the change plan is generated.
+type Planning struct {
+	// Candidates is how many independent plans to request. With more than one,
+	// each is applied and verified in its own git worktree and the best is kept.
+	Candidates int `yaml:"candidates"`
+}
+
+// Tasks configures where a run's objective comes from.
+type Tasks struct {
+	// Source is roadmap (ROADMAP.md and repo_goal) or issues (an open issue
+	// labeled Label, resolved by the run's pull request).
+	Source string `yaml:"source"`
+	Label  string `yaml:"label"`
+	// PriorityLabels orders candidate issues, highest priority first; issues
+	// without any of them come last, and ties go to the oldest issue.
+	PriorityLabels []string `yaml:"priority_labels"`
+}
+
+// Command is a verification command. In YAML it is either a plain string or a
+// mapping; commands that share a stage, or consecutive commands marked parallel,
+// run concurrently.
+type Command struct {
+	Run      string `yaml:"run"`
+	Stage    string `yaml:"stage,omitempty"`
+	Parallel bool   `yaml:"parallel,omitempty"`
+	// AllowNetwork keeps network access for this command when the sandbox is enabled.
+	AllowNetwork bool `yaml:"allow_network,omitempty"`
+}
+
+// UnmarshalYAML accepts both `- go test ./...` and `- run: go test ./...`.
+func (c *Command) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = Command{Run: node.Value}
+		return nil
+	}
+	type plain Command
+	var p plain
+	if err := node.Decode(&p); err != nil {
+		return err
+	}
+	*c = Command(p)
+	return nil
+}
+
+// MarshalYAML writes commands without grouping options as plain strings.
+func (c Command) MarshalYAML() (any, error) {
+	if c.Stage == "" && !c.Parallel && !c.AllowNetwork {
+		return c.Run, nil
+	}
+	type plain Command
+	return plain(c), nil
+}
+
+// Commands wraps plain command strings.
+func Commands(runs ...string) []Command {
+	out := make([]Command, 0, len(runs))
+	for _, r := range runs {
+		out = append(out, Command{Run: r})
+	}
+	return out
+}
+
+// Verify configures how verification relates to the untouched tree.
+type Verify struct {
+	// Baseline runs the verification commands once before the plan is applied,
+	// so failures that already exist on the base branch are not treated as regressions.
+	Baseline bool `yaml:"baseline"`
+	// OnBaselineFailure is "continue" (only new failures count) or "skip" (end the run).
+	OnBaselineFailure string `yaml:"on_baseline_failure"`
+	// Retries reruns failing tests of a test_failure up to N times; tests that
+	// pass on rerun are recorded as flaky instead of triggering repair.
+	Retries int `yaml:"retries"`
+	// MaxParallel bounds how many commands of a parallel group run at once.
+	MaxParallel int `yaml:"max_parallel"`
+}
+
+// Coverage configures the coverage gate that runs after verification commands pass.
+type Coverage struct {
+	Enabled bool `yaml:"enabled"`
+	// Command writes the coverage report. Empty means `go test -coverprofile=<profile> ./...`
+	// for Go modules; with no command and no Go module, Profile is read as-is.
+	Command string `yaml:"command"`
+	Profile string `yaml:"profile"`
+	// Format is go, lcov or cobertura; inferred from the profile name when empty.
+	Format string `yaml:"format"`
+	// MaxTotalDrop and MaxPackageDrop are in percentage points.
+	MaxTotalDrop   float64 `yaml:"max_total_drop"`
+	MaxPackageDrop float64 `yaml:"max_package_drop"`
+	// RequireTests fails when a new non-test Go file lands in a directory without tests.
+	RequireTests bool `yaml:"require_tests"`
+}
+
+// Formatter rewrites the files a plan wrote that match Glob (gitignore-style,
+// e.g. "*.go" or "web/**/*.ts"). The matched paths are appended to Argv, which
+// runs without a shell.
+type Formatter struct {
+	Glob           string   `yaml:"glob"`
+	Argv           []string `yaml:"argv"`
+	TimeoutSeconds int      `yaml:"timeout_seconds"`
+}
+
+// Precheck configures checks on planned files before they are written.
+type Precheck struct {
+	// Go parses planned .go files and type-checks the packages they belong to.
+	Go bool `yaml:"go"`
+	// MaxFixups bounds the fixup prompts sent for pre-check diagnostics. They
+	// do not count against repair.max_attempts.
+	MaxFixups int `yaml:"max_fixups"`
+}
+
+// APICompat guards the exported API of changed, importable Go packages
+// (those outside internal/, testdata/ and vendor/, and not package main).
+type APICompat struct {
+	Enabled bool `yaml:"enabled"`
+	// AllowDeclaredBreaks accepts changes the plan lists in breaking_changes.
+	AllowDeclaredBreaks bool `yaml:"allow_declared_breaks"`
+}
+
+// Dependencies is the policy for dependency changes in manifests (go.mod,
+// package.json, requirements.txt). Patterns match whole module or package
+// names, with * matching any run of characters.
+type Dependencies struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+	// ForbidNew rejects any dependency that was not already declared.
+	ForbidNew bool `yaml:"forbid_new"`
+	// ApprovalLabel, in pr mode, turns violations into a label on the pull
+	// request instead of a verification failure.
+	ApprovalLabel string `yaml:"approval_label"`
+}
+
+// Protection configures how pr mode handles protected files.
+type Protection struct {
+	// AllowInPR lets pr-mode runs edit protected files. The pull request then
+	// gets Label and a review request to the files' CODEOWNERS.
+	AllowInPR bool   `yaml:"allow_in_pr"`
+	Label     string `yaml:"label"`
+}
+
+// SlashCommands configures /evolver commands in issue and pull request comments.
+type SlashCommands struct {
+	// MinPermission is the lowest repository role allowed to run commands:
+	// read, triage, write, maintain or admin.
+	MinPermission string `yaml:"min_permission"`
+}
+
+// PR configures the pull requests opened in pr mode.
+type PR struct {
+	// RequestCodeowners requests review from the CODEOWNERS of changed files.
+	RequestCodeowners bool `yaml:"request_codeowners"`
+	// Reviewers ("user" or "@org/team") replaces the CODEOWNERS lookup when set.
+	Reviewers []string `yaml:"reviewers"`
+	// MaxReviewers caps users and teams requested together; 0 means no cap.
+	MaxReviewers int      `yaml:"max_reviewers"`
+	Labels       []string `yaml:"labels"`
+	// AutoLabels adds evolver, evolver:repair-used and evolver:<failure kind>
+	// for each failure kind that needed repair.
+	AutoLabels bool     `yaml:"auto_labels"`
+	Assignees  []string `yaml:"assignees"`
+	// Milestone is a milestone number or the title of an open milestone.
+	Milestone string `yaml:"milestone"`
+	// Draft is never, always, or auto: a draft when verification needed
+	// repairs or coverage raised a warning.
+	Draft string `yaml:"draft"`
+	// Strategy is new (a branch and pull request per run) or update (continue
+	// the newest open evolver pull request when there is one).
+	Strategy string `yaml:"strategy"`
+	// BranchPrefix names run branches; open pull requests from branches with
+	// this prefix are evolver pull requests.
+	BranchPrefix string `yaml:"branch_prefix"`
+	// MaxOpen skips the run when this many evolver pull requests are open and
+	// none is being updated; 0 means no cap.
+	MaxOpen int `yaml:"max_open"`
 }
 
 // Security configures guardrails for sensitive edits/content.
@@ -38,10 +256,29 @@ type Security struct {
 	SecretScan         bool `yaml:"secret_scan"`
 }
 
+// Sandbox isolates verification commands and repair capabilities on Linux:
+// read-only root except the worktree, no network unless allowed per command,
+// and CPU, memory and output limits.
+type Sandbox struct {
+	Enabled bool `yaml:"enabled"`
+	// Backend is auto (bubblewrap, failing when it is not installed), bwrap
+	// or namespaces, which leaves the root writable.
+	Backend string `yaml:"backend"`
+	// WritablePaths stay writable besides the worktree, GOCACHE and GOMODCACHE.
+	WritablePaths []string `yaml:"writable_paths"`
+	CPUSeconds    int      `yaml:"cpu_seconds"`
+	// MemoryMB caps each process's data segment (RLIMIT_DATA), not its
+	// address space, which the Go runtime over-reserves.
+	MemoryMB       int `yaml:"memory_mb"`
+	MaxOutputBytes int `yaml:"max_output_bytes"`
+}
+
 // Reliability configures lock and run-state persistence.
 type Reliability struct {
-	StateFile        string `yaml:"state_file"`
-	RunLogFile       string `yaml:"run_log_file"`
+	StateFile  string `yaml:"state_file"`
+	RunLogFile string `yaml:"run_log_file"`
+	// HistoryFile is the JSONL ledger with one record per run.
+	HistoryFile      string `yaml:"history_file"`
 	LockFile         string `yaml:"lock_file"`
 	LockStaleMinutes int    `yaml:"lock_stale_minutes"`
 }
@@ -70,23 +307,57 @@ type RepairCapability struct {
 	MaxRunsPerAttempt   int      `yaml:"max_runs_per_attempt"`
 	AllowedFailureKinds []string `yaml:"allowed_failure_kinds"`
 	Cwd                 string   `yaml:"cwd,omitempty"`
+	// AllowNetwork keeps network access for this capability when the sandbox is enabled.
+	AllowNetwork bool `yaml:"allow_network,omitempty"`
+	// Params are arguments a repair action may pass; argv elements reference
+	// them as {name}.
+	Params []CapabilityParam `yaml:"params,omitempty"`
+}
+
+// CapabilityParam declares one argument of a repair capability. Values must
+// fully match Pattern and may not start with "-".
+type CapabilityParam struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Pattern     string `yaml:"pattern"`
+	Required    bool   `yaml:"required"`
 }
 
 // Load builds config from defaults, file values, and environment overrides.
 func Load() *Config {
 	c := &Config{
-		Provider:   "gemini",
-		Mode:       "pr",
-		Model:      "gemini-2.5-flash-lite",
-		Workdir:    ".",
-		Budgets:    Budgets{MaxFilesChanged: 10, MaxLinesChanged: 500, MaxNewFiles: 10},
-		Commands:   []string{},
-		AllowPaths: []string{"."},
-		DenyPaths:  []string{".git/", ".github/workflows/", "node_modules/"},
-		Security:   Security{AllowWorkflowEdits: false, SecretScan: true},
+		Provider: "gemini",
+		Mode:     "pr",
+		Model:    "gemini-2.5-flash-lite",
+		Workdir:  ".",
+		Budgets:  Budgets{MaxFilesChanged: 10, MaxLinesChanged: 500, MaxNewFiles: 10},
+		Planning: Planning{Candidates: 1},
+		Tasks:    Tasks{Source: "roadmap", Label: "evolver-ok"},
+		Commands: []Command{},
+		Verify:   Verify{Baseline: true, OnBaselineFailure: "continue", MaxParallel: 4},
+		Coverage: Coverage{
+			Profile:        ".evolver/coverage.out",
+			MaxTotalDrop:   0.5,
+			MaxPackageDrop: 2,
+			RequireTests:   true,
+		},
+		Precheck:      Precheck{Go: true, MaxFixups: 2},
+		Protection:    Protection{Label: "evolver:protected"},
+		PR:            PR{RequestCodeowners: true, MaxReviewers: 3, AutoLabels: true, Draft: "never", Strategy: "new", BranchPrefix: "evolve/"},
+		SlashCommands: SlashCommands{MinPermission: "write"},
+		AllowPaths:    []string{"."},
+		DenyPaths:     []string{".git/", ".github/workflows/", "node_modules/"},
+		Security:      Security{AllowWorkflowEdits: false, SecretScan: true},
+		Sandbox: Sandbox{
+			Backend:        "auto",
+			CPUSeconds:     1800,
+			MemoryMB:       8192,
+			MaxOutputBytes: 16 << 20,
+		},
 		Reliability: Reliability{
 			StateFile:        ".evolver/state.json",
 			RunLogFile:       ".evolver/runs.log",
+			HistoryFile:      ".evolver/history.jsonl",
 			LockFile:         ".evolver/run.lock",
 			LockStaleMinutes: 180,
 		},
@@ -129,6 +400,10 @@ func Load() *Config {
 			}
 			cap.Argv = n
 		}
+		for j := range cap.Params {
+			cap.Params[j].Name = strings.TrimSpace(cap.Params[j].Name)
+			cap.Params[j].Description = strings.TrimSpace(cap.Params[j].Description)
+		}
 		if len(cap.AllowedFailureKinds) > 0 {
 			n := cap.AllowedFailureKinds[:0]
 			for _, k := range cap.AllowedFailureKinds {
@@ -140,6 +415,78 @@ func Load() *Config {
 			cap.AllowedFailureKinds = n
 		}
 	}
+	c.Verify.OnBaselineFailure = strings.ToLower(strings.TrimSpace(c.Verify.OnBaselineFailure))
+	if c.Verify.OnBaselineFailure != "skip" {
+		c.Verify.OnBaselineFailure = "continue"
+	}
+	if len(c.Formatters) > 0 {
+		n := c.Formatters[:0]
+		for _, f := range c.Formatters {
+			f.Glob = strings.TrimSpace(f.Glob)
+			if f.Glob == "" || len(f.Argv) == 0 {
+				continue
+			}
+			if f.TimeoutSeconds <= 0 {
+				f.TimeoutSeconds = 120
+			}
+			n = append(n, f)
+		}
+		c.Formatters = n
+	}
+	if c.Precheck.MaxFixups < 0 {
+		c.Precheck.MaxFixups = 0
+	}
+	if c.Planning.Candidates <= 0 {
+		c.Planning.Candidates = 1
+	}
+	if c.Tasks.Source != "issues" {
+		c.Tasks.Source = "roadmap"
+	}
+	if strings.TrimSpace(c.Tasks.Label) == "" {
+		c.Tasks.Label = "evolver-ok"
+	}
+	if c.PR.MaxReviewers < 0 {
+		c.PR.MaxReviewers = 0
+	}
+	if c.PR.Strategy != "update" {
+		c.PR.Strategy = "new"
+	}
+	if strings.TrimSpace(c.PR.BranchPrefix) == "" {
+		c.PR.BranchPrefix = "evolve/"
+	}
+	if c.PR.MaxOpen < 0 {
+		c.PR.MaxOpen = 0
+	}
+	switch c.PR.Draft {
+	case "never", "always", "auto":
+	default:
+		c.PR.Draft = "never"
+	}
+	switch c.SlashCommands.MinPermission {
+	case "read", "triage", "write", "maintain", "admin":
+	default:
+		c.SlashCommands.MinPermission = "write"
+	}
+	if strings.TrimSpace(c.Protection.Label) == "" {
+		c.Protection.Label = "evolver:protected"
+	}
+	if c.Verify.Retries < 0 {
+		c.Verify.Retries = 0
+	}
+	if c.Verify.MaxParallel <= 0 {
+		c.Verify.MaxParallel = 1
+	}
+	if len(c.Commands) > 0 {
+		n := c.Commands[:0]
+		for _, cmd := range c.Commands {
+			cmd.Run = strings.TrimSpace(cmd.Run)
+			cmd.Stage = strings.TrimSpace(cmd.Stage)
+			if cmd.Run != "" {
+				n = append(n, cmd)
+			}
+		}
+		c.Commands = n
+	}
 	if c.Repair.MaxAttempts <= 0 {
 		c.Repair.MaxAttempts = 2
 	}
@@ -154,6 +501,12 @@ func Load() *Config {
 	if v := os.Getenv("EVOLVER_MODE"); v != "" {
 		c.Mode = v
 	}
+	if v := os.Getenv("EVOLVER_PR_STRATEGY"); v == "new" || v == "update" {
+		c.PR.Strategy = v
+	}
+	if v := os.Getenv("EVOLVER_TASKS_SOURCE"); v == "roadmap" || v == "issues" {
+		c.Tasks.Source = v
+	}
 	if v := os.Getenv("EVOLVER_MODEL"); v != "" {
 		c.Model = v
 	}
@@ -181,9 +534,42 @@ func Load() *Config {
 			if p == "" {
 				continue
 			}
-			c.Commands = append(c.Commands, p)
+			c.Commands = append(c.Commands, Command{Run: p})
+		}
+	}
+	if v := os.Getenv("EVOLVER_PLANNING_CANDIDATES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.Planning.Candidates = n
+		}
+	}
+	if v := os.Getenv("EVOLVER_VERIFY_BASELINE"); v != "" {
+		c.Verify.Baseline = v == "true"
+	}
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("EVOLVER_VERIFY_ON_BASELINE_FAILURE"))); v == "skip" || v == "continue" {
+		c.Verify.OnBaselineFailure = v
+	}
+	if v := os.Getenv("EVOLVER_VERIFY_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			c.Verify.Retries = n
+		}
+	}
+	if v := os.Getenv("EVOLVER_VERIFY_MAX_PARALLEL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			c.Verify.MaxParallel = n
 		}
 	}
+	if v := os.Getenv("EVOLVER_COVERAGE"); v != "" {
+		c.Coverage.Enabled = v == "true"
+	}
+	if v := os.Getenv("EVOLVER_PRECHECK_GO"); v != "" {
+		c.Precheck.Go = v == "true"
+	}
+	if v := os.Getenv("EVOLVER_API_COMPAT"); v != "" {
+		c.APICompat.Enabled = v == "true"
+	}
+	if v := os.Getenv("EVOLVER_SANDBOX"); v != "" {
+		c.Sandbox.Enabled = v == "true"
+	}
 	if v := os.Getenv("EVOLVER_ALLOW_WORKFLOWS"); v == "true" {
 		c.Security.AllowWorkflowEdits = true
 	}
@@ -193,6 +579,9 @@ func Load() *Config {
 	if v := os.Getenv("EVOLVER_RUN_LOG_FILE"); v != "" {
 		c.Reliability.RunLogFile = v
 	}
+	if v := os.Getenv("EVOLVER_HISTORY_FILE"); v != "" {
+		c.Reliability.HistoryFile = v
+	}
 	if v := os.Getenv("EVOLVER_LOCK_FILE"); v != "" {
 		c.Reliability.LockFile = v
 	}
@@ -33,9 +33,24 @@ func TestLoadDefaults(t *testing.T) {
 	if !c.Security.SecretScan {
 		t.Fatalf("expected secret scan enabled by default")
 	}
-	if c.Reliability.LockStaleMinutes != 180 {
+	if !c.Verify.Baseline || c.Verify.OnBaselineFailure != "continue" || c.Verify.MaxParallel != 4 {
+		t.Fatalf("unexpected verify defaults: %+v", c.Verify)
+	}
+	if !c.PR.RequestCodeowners || c.PR.MaxReviewers != 3 || c.Protection.Label != "evolver:protected" || c.SlashCommands.MinPermission != "write" || c.Tasks.Source != "roadmap" || c.Tasks.Label != "evolver-ok" {
+		t.Fatalf("unexpected pr defaults: %+v %+v", c.PR, c.Protection)
+	}
+	if !c.Precheck.Go || c.Precheck.MaxFixups != 2 {
+		t.Fatalf("unexpected precheck defaults: %+v", c.Precheck)
+	}
+	if c.Planning.Candidates != 1 {
+		t.Fatalf("unexpected planning defaults: %+v", c.Planning)
+	}
+	if c.Reliability.LockStaleMinutes != 180 || c.Reliability.HistoryFile != ".evolver/history.jsonl" {
 		t.Fatalf("unexpected reliability defaults: %+v", c.Reliability)
 	}
+	if c.Sandbox.Enabled || c.Sandbox.Backend != "auto" || c.Sandbox.MaxOutputBytes != 16<<20 {
+		t.Fatalf("unexpected sandbox defaults: %+v", c.Sandbox)
+	}
 	if c.Logging.Level != "info" || c.Logging.Format != "text" || c.Logging.File != ".evolver/evolver.log" {
 		t.Fatalf("unexpected logging defaults: %+v", c.Logging)
 	}
@@ -70,6 +85,10 @@ func TestLoadFromFileAndEnvOverrides(t *testing.T) {
 	t.Setenv("EVOLVER_MAX_NEW_FILES", "5")
 	t.Setenv("EVOLVER_COMMANDS", "go test ./...\ngo vet ./...")
 	t.Setenv("EVOLVER_ALLOW_WORKFLOWS", "true")
+	t.Setenv("EVOLVER_VERIFY_BASELINE", "false")
+	t.Setenv("EVOLVER_VERIFY_ON_BASELINE_FAILURE", "skip")
+	t.Setenv("EVOLVER_VERIFY_RETRIES", "3")
+	t.Setenv("EVOLVER_PLANNING_CANDIDATES", "3")
 	t.Setenv("EVOLVER_STATE_FILE", ".evolver/custom_state.json")
 	t.Setenv("EVOLVER_RUN_LOG_FILE", ".evolver/custom_runs.log")
 	t.Setenv("EVOLVER_LOCK_FILE", ".evolver/custom.lock")
@@ -97,12 +116,18 @@ func TestLoadFromFileAndEnvOverrides(t *testing.T) {
 	if c.Budgets.MaxFilesChanged != 7 || c.Budgets.MaxLinesChanged != 99 || c.Budgets.MaxNewFiles != 5 {
 		t.Fatalf("expected budget overrides, got %+v", c.Budgets)
 	}
-	if len(c.Commands) != 2 || c.Commands[0] != "go test ./..." || c.Commands[1] != "go vet ./..." {
+	if len(c.Commands) != 2 || c.Commands[0].Run != "go test ./..." || c.Commands[1].Run != "go vet ./..." {
 		t.Fatalf("unexpected commands: %#v", c.Commands)
 	}
 	if !c.Security.AllowWorkflowEdits {
 		t.Fatalf("expected workflow edits enabled by env")
 	}
+	if c.Verify.Baseline || c.Verify.OnBaselineFailure != "skip" || c.Verify.Retries != 3 {
+		t.Fatalf("unexpected verify overrides: %+v", c.Verify)
+	}
+	if c.Planning.Candidates != 3 {
+		t.Fatalf("expected planning candidates override, got %+v", c.Planning)
+	}
 	if c.Reliability.StateFile != ".evolver/custom_state.json" || c.Reliability.RunLogFile != ".evolver/custom_runs.log" || c.Reliability.LockFile != ".evolver/custom.lock" {
 		t.Fatalf("unexpected reliability path overrides: %+v", c.Reliability)
 	}
@@ -113,3 +138,68 @@ func TestLoadFromFileAndEnvOverrides(t *testing.T) {
 		t.Fatalf("unexpected logging overrides: %+v", c.Logging)
 	}
 }
+
+func TestLoadCommandsAcceptStringsAndMappings(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	if err := os.MkdirAll(".evolver", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	cfgYAML := []byte("commands:\n  - go build ./...\n  - run: go vet ./...\n    stage: static\n  - run: golangci-lint run\n    stage: static\n  - run: go test ./...\n    parallel: true\n")
+	if err := os.WriteFile(filepath.Join(".evolver", "config.yml"), cfgYAML, 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	c := Load()
+	want := []Command{
+		{Run: "go build ./..."},
+		{Run: "go vet ./...", Stage: "static"},
+		{Run: "golangci-lint run", Stage: "static"},
+		{Run: "go test ./...", Parallel: true},
+	}
+	if len(c.Commands) != len(want) {
+		t.Fatalf("unexpected commands: %#v", c.Commands)
+	}
+	for i := range want {
+		if c.Commands[i] != want[i] {
+			t.Fatalf("command %d: expected %#v, got %#v", i, want[i], c.Commands[i])
+		}
+	}
+}
+
+func TestLoadPathBudgets(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	if err := os.MkdirAll(".evolver", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	cfgYAML := []byte("budgets:\n  max_deleted_lines: 200\n  tests: { max_lines: 400 }\n  paths:\n    - glob: \"docs/**\"\n      unlimited: true\n    - glob: \"internal/core/**\"\n      max_lines: 50\n")
+	if err := os.WriteFile(filepath.Join(".evolver", "config.yml"), cfgYAML, 0644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	b := Load().Budgets
+	if b.MaxDeletedLines != 200 || b.Tests.MaxLines != 400 || b.MaxLinesChanged != 500 {
+		t.Fatalf("unexpected budgets: %+v", b)
+	}
+	want := []PathBudget{{Glob: "docs/**", Unlimited: true}, {Glob: "internal/core/**", BudgetLimits: BudgetLimits{MaxLines: 50}}}
+	if len(b.Paths) != 2 || b.Paths[0] != want[0] || b.Paths[1] != want[1] {
+		t.Fatalf("unexpected path budgets: %+v", b.Paths)
+	}
+}
@@ -0,0 +1,259 @@
+package coverage
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Counts holds covered and total units (statements for Go, lines otherwise).
+type Counts struct {
+	Covered int64 `json:"covered"`
+	Total   int64 `json:"total"`
+}
+
+// Percent returns the covered percentage, or 100 when there is nothing to cover.
+func (c Counts) Percent() float64 {
+	if c.Total == 0 {
+		return 100
+	}
+	return float64(c.Covered) * 100 / float64(c.Total)
+}
+
+// Profile is a coverage summary aggregated per package (directory).
+type Profile struct {
+	Total    Counts            `json:"total"`
+	Packages map[string]Counts `json:"packages"`
+}
+
+// Load reads a coverage file. format is go, lcov or cobertura; when empty it is
+// inferred from the file name.
+func Load(file, format string) (*Profile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if strings.TrimSpace(format) == "" {
+		format = inferFormat(file)
+	}
+	return Parse(f, format)
+}
+
+// Parse reads a coverage report in the given format.
+func Parse(r io.Reader, format string) (*Profile, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "go":
+		return parseGo(r)
+	case "lcov":
+		return parseLCOV(r)
+	case "cobertura":
+		return parseCobertura(r)
+	default:
+		return nil, fmt.Errorf("unsupported coverage format: %q", format)
+	}
+}
+
+func inferFormat(file string) string {
+	name := strings.ToLower(filepath.Base(file))
+	switch {
+	case strings.HasSuffix(name, ".info") || strings.Contains(name, "lcov"):
+		return "lcov"
+	case strings.HasSuffix(name, ".xml"):
+		return "cobertura"
+	default:
+		return "go"
+	}
+}
+
+func parseGo(r io.Reader) (*Profile, error) {
+	type block struct {
+		stmts   int64
+		covered bool
+	}
+	// The same block can appear several times (e.g. with -coverpkg); it counts
+	// as covered if any occurrence was hit.
+	blocks := make(map[string]block)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		// file.go:12.34,56.2 3 1
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed go coverage line: %q", line)
+		}
+		stmts, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed go coverage line: %q", line)
+		}
+		count, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed go coverage line: %q", line)
+		}
+		b := blocks[fields[0]]
+		b.stmts = stmts
+		b.covered = b.covered || count > 0
+		blocks[fields[0]] = b
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	p := &Profile{Packages: make(map[string]Counts)}
+	for key, b := range blocks {
+		file, _, _ := strings.Cut(key, ":")
+		var covered int64
+		if b.covered {
+			covered = b.stmts
+		}
+		p.add(path.Dir(file), covered, b.stmts)
+	}
+	return p, nil
+}
+
+func parseLCOV(r io.Reader) (*Profile, error) {
+	p := &Profile{Packages: make(map[string]Counts)}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	var file string
+	var found, hit int64
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "SF:"):
+			file = strings.TrimPrefix(line, "SF:")
+			found, hit = 0, 0
+		case strings.HasPrefix(line, "LF:"):
+			found, _ = strconv.ParseInt(strings.TrimPrefix(line, "LF:"), 10, 64)
+		case strings.HasPrefix(line, "LH:"):
+			hit, _ = strconv.ParseInt(strings.TrimPrefix(line, "LH:"), 10, 64)
+		case line == "end_of_record":
+			if file == "" {
+				return nil, fmt.Errorf("lcov record without SF")
+			}
+			p.add(path.Dir(filepath.ToSlash(file)), hit, found)
+			file = ""
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func parseCobertura(r io.Reader) (*Profile, error) {
+	var doc struct {
+		Packages []struct {
+			Name    string `xml:"name,attr"`
+			Classes []struct {
+				Lines []struct {
+					Hits int64 `xml:"hits,attr"`
+				} `xml:"lines>line"`
+			} `xml:"classes>class"`
+		} `xml:"packages>package"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse cobertura: %w", err)
+	}
+	p := &Profile{Packages: make(map[string]Counts)}
+	for _, pkg := range doc.Packages {
+		for _, cls := range pkg.Classes {
+			for _, l := range cls.Lines {
+				var covered int64
+				if l.Hits > 0 {
+					covered = 1
+				}
+				p.add(pkg.Name, covered, 1)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *Profile) add(pkg string, covered, total int64) {
+	c := p.Packages[pkg]
+	c.Covered += covered
+	c.Total += total
+	p.Packages[pkg] = c
+	p.Total.Covered += covered
+	p.Total.Total += total
+}
+
+// Thresholds bounds how far coverage may drop, in percentage points.
+type Thresholds struct {
+	MaxTotalDrop   float64
+	MaxPackageDrop float64
+}
+
+// Comparison describes coverage before and after a change.
+type Comparison struct {
+	TotalBefore float64  `json:"total_before"`
+	TotalAfter  float64  `json:"total_after"`
+	Regressions []string `json:"regressions,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// Compare reports drops beyond the thresholds as regressions and smaller drops as warnings.
+// Packages that only exist on one side are ignored.
+func Compare(before, after *Profile, t Thresholds) Comparison {
+	c := Comparison{TotalBefore: before.Total.Percent(), TotalAfter: after.Total.Percent()}
+	if drop := c.TotalBefore - c.TotalAfter; drop > t.MaxTotalDrop {
+		c.Regressions = append(c.Regressions, fmt.Sprintf("total coverage dropped %.2f points (%.2f%% -> %.2f%%), limit %.2f", drop, c.TotalBefore, c.TotalAfter, t.MaxTotalDrop))
+	} else if drop > 0.005 {
+		c.Warnings = append(c.Warnings, fmt.Sprintf("total coverage dropped %.2f points (%.2f%% -> %.2f%%)", drop, c.TotalBefore, c.TotalAfter))
+	}
+
+	pkgs := make([]string, 0, len(after.Packages))
+	for pkg := range after.Packages {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		b, ok := before.Packages[pkg]
+		if !ok {
+			continue
+		}
+		a := after.Packages[pkg]
+		drop := b.Percent() - a.Percent()
+		if drop > t.MaxPackageDrop {
+			c.Regressions = append(c.Regressions, fmt.Sprintf("%s coverage dropped %.2f points (%.2f%% -> %.2f%%), limit %.2f", pkg, drop, b.Percent(), a.Percent(), t.MaxPackageDrop))
+		} else if drop > 0.005 {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("%s coverage dropped %.2f points (%.2f%% -> %.2f%%)", pkg, drop, b.Percent(), a.Percent()))
+		}
+	}
+	return c
+}
+
+// MissingTests returns new non-test Go files whose directory has no _test.go file.
+func MissingTests(newFiles []string) []string {
+	var out []string
+	checked := make(map[string]bool)
+	for _, f := range newFiles {
+		if !strings.HasSuffix(f, ".go") || strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+		dir := filepath.Dir(f)
+		has, ok := checked[dir]
+		if !ok {
+			matches, _ := filepath.Glob(filepath.Join(dir, "*_test.go"))
+			has = len(matches) > 0
+			checked[dir] = has
+		}
+		if !has {
+			out = append(out, f)
+		}
+	}
+	return out
+}
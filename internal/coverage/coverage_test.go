@@ -0,0 +1,88 @@
+package coverage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGoProfileAggregatesPackages(t *testing.T) {
+	in := "mode: set\n" +
+		"example.com/m/a/a.go:1.1,3.2 2 1\n" +
+		"example.com/m/a/a.go:4.1,6.2 2 0\n" +
+		"example.com/m/a/a.go:4.1,6.2 2 1\n" +
+		"example.com/m/b/b.go:1.1,3.2 4 0\n"
+	p, err := Parse(strings.NewReader(in), "go")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := p.Packages["example.com/m/a"]; got.Covered != 4 || got.Total != 4 {
+		t.Fatalf("unexpected package a counts: %+v", got)
+	}
+	if p.Total.Covered != 4 || p.Total.Total != 8 {
+		t.Fatalf("unexpected totals: %+v", p.Total)
+	}
+}
+
+func TestParseLCOVAndCobertura(t *testing.T) {
+	lcov := "TN:\nSF:src/a.ts\nDA:1,1\nLF:4\nLH:3\nend_of_record\nSF:src/b/b.ts\nLF:2\nLH:0\nend_of_record\n"
+	p, err := Parse(strings.NewReader(lcov), "lcov")
+	if err != nil {
+		t.Fatalf("parse lcov: %v", err)
+	}
+	if p.Total.Covered != 3 || p.Total.Total != 6 || p.Packages["src"].Total != 4 {
+		t.Fatalf("unexpected lcov profile: %+v", p)
+	}
+
+	xmlDoc := `<coverage><packages><package name="app"><classes><class filename="app/x.py"><lines><line number="1" hits="2"/><line number="2" hits="0"/></lines></class></classes></package></packages></coverage>`
+	p, err = Parse(strings.NewReader(xmlDoc), "cobertura")
+	if err != nil {
+		t.Fatalf("parse cobertura: %v", err)
+	}
+	if got := p.Packages["app"]; got.Covered != 1 || got.Total != 2 {
+		t.Fatalf("unexpected cobertura counts: %+v", got)
+	}
+}
+
+func TestCompareFlagsRegressionsAndWarnings(t *testing.T) {
+	before := &Profile{
+		Total:    Counts{Covered: 80, Total: 100},
+		Packages: map[string]Counts{"a": {Covered: 40, Total: 50}, "b": {Covered: 40, Total: 50}},
+	}
+	after := &Profile{
+		Total:    Counts{Covered: 79, Total: 100},
+		Packages: map[string]Counts{"a": {Covered: 35, Total: 50}, "b": {Covered: 44, Total: 50}, "c": {Total: 10}},
+	}
+	c := Compare(before, after, Thresholds{MaxTotalDrop: 2, MaxPackageDrop: 5})
+	if len(c.Regressions) != 1 || !strings.HasPrefix(c.Regressions[0], "a coverage dropped 10.00 points") {
+		t.Fatalf("unexpected regressions: %#v", c.Regressions)
+	}
+	if len(c.Warnings) != 1 || !strings.HasPrefix(c.Warnings[0], "total coverage dropped 1.00 points") {
+		t.Fatalf("unexpected warnings: %#v", c.Warnings)
+	}
+}
+
+func TestMissingTests(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	for _, f := range []string{"tested/a.go", "tested/a_test.go", "untested/b.go"} {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(f, []byte("package x\n"), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	got := MissingTests([]string{"tested/a.go", "untested/b.go", "README.md", "tested/a_test.go"})
+	if len(got) != 1 || got[0] != "untested/b.go" {
+		t.Fatalf("unexpected missing tests: %#v", got)
+	}
+}
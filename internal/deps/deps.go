@@ -0,0 +1,306 @@
+// Package deps diffs dependency manifests and lockfiles and checks the
+// resulting changes against a dependency policy.
+package deps
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change is one dependency that was added, removed or changed version.
+type Change struct {
+	File string
+	Name string
+	// Kind is added, removed, upgraded, downgraded or changed.
+	Kind   string
+	Before string
+	After  string
+	// Lockfile is set for changes found only in a lockfile (go.sum,
+	// package-lock.json); these are usually transitive.
+	Lockfile bool
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case "added":
+		return fmt.Sprintf("%s: added %s %s", c.File, c.Name, c.After)
+	case "removed":
+		return fmt.Sprintf("%s: removed %s %s", c.File, c.Name, c.Before)
+	default:
+		return fmt.Sprintf("%s: %s %s %s -> %s", c.File, c.Kind, c.Name, c.Before, c.After)
+	}
+}
+
+// Policy restricts dependency changes from manifests. Patterns match whole
+// dependency names, with * matching any run of characters.
+type Policy struct {
+	Allow     []string
+	Deny      []string
+	ForbidNew bool
+}
+
+var parsers = map[string]func([]byte) (map[string]string, error){
+	"go.mod":            parseGoMod,
+	"go.sum":            parseGoSum,
+	"package.json":      parsePackageJSON,
+	"package-lock.json": parsePackageLock,
+	"requirements.txt":  parseRequirements,
+}
+
+var lockfiles = map[string]bool{"go.sum": true, "package-lock.json": true}
+
+// Supported reports whether path is a manifest or lockfile that Diff understands.
+func Supported(path string) bool {
+	_, ok := parsers[filepath.Base(path)]
+	return ok
+}
+
+// Diff compares two versions of the manifest or lockfile at path. A nil
+// before or after means the file did not exist.
+func Diff(path string, before, after []byte) ([]Change, error) {
+	parse, ok := parsers[filepath.Base(path)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported dependency file: %s", path)
+	}
+	old, err := parseOptional(parse, before)
+	if err != nil {
+		return nil, fmt.Errorf("%s (before): %w", path, err)
+	}
+	cur, err := parseOptional(parse, after)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	lock := lockfiles[filepath.Base(path)]
+	var out []Change
+	for name, v := range cur {
+		prev, existed := old[name]
+		switch {
+		case !existed:
+			out = append(out, Change{File: path, Name: name, Kind: "added", After: v, Lockfile: lock})
+		case prev != v:
+			out = append(out, Change{File: path, Name: name, Kind: versionChange(prev, v), Before: prev, After: v, Lockfile: lock})
+		}
+	}
+	for name, v := range old {
+		if _, ok := cur[name]; !ok {
+			out = append(out, Change{File: path, Name: name, Kind: "removed", Before: v, Lockfile: lock})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Violations returns policy violations among manifest changes. Lockfile
+// changes are reported but not checked, since they are mostly transitive.
+func Violations(changes []Change, p Policy) []string {
+	var out []string
+	for _, c := range changes {
+		if c.Lockfile || c.Kind == "removed" {
+			continue
+		}
+		if matchAny(p.Deny, c.Name) {
+			out = append(out, fmt.Sprintf("%s (denied by dependencies.deny)", c))
+			continue
+		}
+		if p.ForbidNew && c.Kind == "added" {
+			out = append(out, fmt.Sprintf("%s (new dependencies are forbidden)", c))
+			continue
+		}
+		if len(p.Allow) > 0 && !matchAny(p.Allow, c.Name) {
+			out = append(out, fmt.Sprintf("%s (not in dependencies.allow)", c))
+		}
+	}
+	return out
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		parts := strings.Split(p, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		if regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseOptional(parse func([]byte) (map[string]string, error), b []byte) (map[string]string, error) {
+	if b == nil {
+		return map[string]string{}, nil
+	}
+	return parse(b)
+}
+
+// versionChange classifies a version change by comparing numeric components.
+func versionChange(before, after string) string {
+	a, b := versionNumbers(before), versionNumbers(after)
+	if len(a) == 0 || len(b) == 0 {
+		return "changed"
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			if b[i] > a[i] {
+				return "upgraded"
+			}
+			return "downgraded"
+		}
+	}
+	if len(b) > len(a) {
+		return "upgraded"
+	}
+	return "changed"
+}
+
+var numberRE = regexp.MustCompile(`\d+`)
+
+func versionNumbers(v string) []int {
+	// Ignore pre-release and build suffixes such as -0.2024... or +incompatible.
+	if i := strings.IndexAny(v, "-+"); i > 0 {
+		v = v[:i]
+	}
+	var out []int
+	for _, m := range numberRE.FindAllString(v, 3) {
+		n, _ := strconv.Atoi(m)
+		out = append(out, n)
+	}
+	return out
+}
+
+func parseGoMod(b []byte) (map[string]string, error) {
+	out := map[string]string{}
+	inBlock := false
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		switch {
+		case line == "":
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case line == "require (":
+			inBlock = true
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require"))
+		case !inBlock:
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("malformed require line: %q", line)
+		}
+		out[fields[0]] = fields[1]
+	}
+	return out, sc.Err()
+}
+
+func parseGoSum(b []byte) (map[string]string, error) {
+	// A module can appear at several versions; keep the sorted set.
+	versions := map[string]map[string]bool{}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		v := strings.TrimSuffix(fields[1], "/go.mod")
+		if versions[fields[0]] == nil {
+			versions[fields[0]] = map[string]bool{}
+		}
+		versions[fields[0]][v] = true
+	}
+	out := map[string]string{}
+	for mod, set := range versions {
+		list := make([]string, 0, len(set))
+		for v := range set {
+			list = append(list, v)
+		}
+		sort.Strings(list)
+		out[mod] = strings.Join(list, ",")
+	}
+	return out, nil
+}
+
+func parsePackageJSON(b []byte) (map[string]string, error) {
+	var pkg map[string]json.RawMessage
+	if err := json.Unmarshal(b, &pkg); err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	for _, section := range []string{"dependencies", "devDependencies", "peerDependencies", "optionalDependencies"} {
+		raw, ok := pkg[section]
+		if !ok {
+			continue
+		}
+		var deps map[string]string
+		if err := json.Unmarshal(raw, &deps); err != nil {
+			return nil, fmt.Errorf("%s: %w", section, err)
+		}
+		for name, v := range deps {
+			out[name] = v
+		}
+	}
+	return out, nil
+}
+
+func parsePackageLock(b []byte) (map[string]string, error) {
+	var lock struct {
+		Packages map[string]struct {
+			Version string `json:"version"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, err
+	}
+	out := map[string]string{}
+	for key, p := range lock.Packages {
+		i := strings.LastIndex(key, "node_modules/")
+		if i < 0 {
+			continue
+		}
+		out[key[i+len("node_modules/"):]] = p.Version
+	}
+	return out, nil
+}
+
+var requirementRE = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)(\[[^\]]*\])?\s*(.*)$`)
+
+func parseRequirements(b []byte) (map[string]string, error) {
+	out := map[string]string{}
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+		m := requirementRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		spec := strings.TrimSpace(m[3])
+		if i := strings.Index(spec, ";"); i >= 0 {
+			spec = strings.TrimSpace(spec[:i])
+		}
+		out[strings.ToLower(m[1])] = strings.TrimPrefix(spec, "==")
+	}
+	return out, nil
+}
@@ -0,0 +1,68 @@
+package deps
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffGoMod(t *testing.T) {
+	before := []byte("module example.com/m\n\ngo 1.22\n\nrequire (\n\tgithub.com/a/lib v1.2.0\n\tgithub.com/b/old v0.3.0 // indirect\n)\n\nrequire golang.org/x/mod v0.20.0\n")
+	after := []byte("module example.com/m\n\ngo 1.22\n\nrequire (\n\tgithub.com/a/lib v1.10.0\n\tgithub.com/c/new v0.1.0\n)\n\nrequire golang.org/x/mod v0.19.0\n")
+	changes, err := Diff("go.mod", before, after)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Kind+" "+c.Name)
+	}
+	want := "upgraded github.com/a/lib,removed github.com/b/old,added github.com/c/new,downgraded golang.org/x/mod"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("unexpected changes:\n got  %v\n want %s", got, want)
+	}
+}
+
+func TestDiffPackageJSONAndLockfile(t *testing.T) {
+	changes, err := Diff("web/package.json", []byte(`{"dependencies":{"react":"^18.2.0"}}`), []byte(`{"dependencies":{"react":"^18.3.1"},"devDependencies":{"left-pad":"1.3.0"}}`))
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Name != "left-pad" || changes[0].Kind != "added" || changes[1].Kind != "upgraded" {
+		t.Fatalf("unexpected package.json changes: %+v", changes)
+	}
+
+	lock, err := Diff("package-lock.json", nil, []byte(`{"packages":{"":{},"node_modules/left-pad":{"version":"1.3.0"},"node_modules/a/node_modules/b":{"version":"2.0.0"}}}`))
+	if err != nil {
+		t.Fatalf("diff lock: %v", err)
+	}
+	if len(lock) != 2 || lock[0].Name != "b" || !lock[0].Lockfile {
+		t.Fatalf("unexpected lockfile changes: %+v", lock)
+	}
+}
+
+func TestDiffRequirements(t *testing.T) {
+	changes, err := Diff("requirements.txt", []byte("requests==2.31.0\n# comment\n-r base.txt\n"), []byte("requests==2.32.0\nuvicorn[standard]>=0.30 ; python_version > '3.8'\n"))
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if len(changes) != 2 || changes[0].Kind != "upgraded" || changes[1].Name != "uvicorn" || changes[1].After != ">=0.30" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestViolations(t *testing.T) {
+	changes := []Change{
+		{File: "go.mod", Name: "github.com/acme/util", Kind: "added", After: "v1.0.0"},
+		{File: "go.mod", Name: "github.com/evil/pkg", Kind: "upgraded", Before: "v1.0.0", After: "v1.1.0"},
+		{File: "go.mod", Name: "github.com/other/x", Kind: "upgraded", Before: "v1.0.0", After: "v1.1.0"},
+		{File: "go.mod", Name: "github.com/gone/x", Kind: "removed", Before: "v1.0.0"},
+		{File: "go.sum", Name: "github.com/transitive/x", Kind: "added", After: "v1.0.0", Lockfile: true},
+	}
+	got := Violations(changes, Policy{Allow: []string{"github.com/acme/*", "github.com/evil/*"}, Deny: []string{"github.com/evil/*"}})
+	if len(got) != 2 || !strings.Contains(got[0], "evil") || !strings.Contains(got[1], "other") {
+		t.Fatalf("unexpected violations: %v", got)
+	}
+	if got := Violations(changes[:1], Policy{ForbidNew: true}); len(got) != 1 {
+		t.Fatalf("expected new dependency to be forbidden, got %v", got)
+	}
+}
@@ -7,93 +7,413 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
 
 var httpClient = &http.Client{Timeout: 30 * time.Second}
 
-// CreatePR creates a pull request on the current GitHub repository.
-func CreatePR(head, title, body string) (string, error) {
-	repo := strings.TrimSpace(os.Getenv("GITHUB_REPOSITORY"))
-	token := strings.TrimSpace(os.Getenv("GITHUB_TOKEN"))
-	if repo == "" {
-		return "", fmt.Errorf("missing GITHUB_REPOSITORY")
+// PullRequest identifies a pull request on the current repository.
+type PullRequest struct {
+	Number int    `json:"number"`
+	URL    string `json:"html_url"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Head   struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Labels []Label `json:"labels"`
+	// User is the author: the account behind the token that opened it.
+	User User `json:"user"`
+}
+
+// Issue is an open issue of the current repository.
+type Issue struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Labels    []Label   `json:"labels"`
+	CreatedAt time.Time `json:"created_at"`
+	// PullRequest is set when the issue is a pull request.
+	PullRequest *json.RawMessage `json:"pull_request"`
+}
+
+// HasLabel reports whether the issue carries the named label.
+func (i Issue) HasLabel(name string) bool {
+	return PullRequest{Labels: i.Labels}.HasLabel(name)
+}
+
+// Label is an issue or pull request label.
+type Label struct {
+	Name string `json:"name"`
+}
+
+// Comment is an issue comment, a pull request review comment (with Path and
+// Line), or a review summary.
+type Comment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	User User   `json:"user"`
+	// Path, Line and DiffHunk locate review comments. Line is 0 when the
+	// comment is outdated; OriginalLine still refers to the reviewed diff.
+	Path         string `json:"path"`
+	Line         int    `json:"line"`
+	OriginalLine int    `json:"original_line"`
+	DiffHunk     string `json:"diff_hunk"`
+	// InReplyToID is the first comment of the thread for review comment replies.
+	InReplyToID int64 `json:"in_reply_to_id"`
+}
+
+// User is a GitHub account; Type is "User" or "Bot".
+type User struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+// HasLabel reports whether the pull request carries the named label.
+func (pr PullRequest) HasLabel(name string) bool {
+	for _, l := range pr.Labels {
+		if l.Name == name {
+			return true
+		}
 	}
-	if token == "" {
-		return "", fmt.Errorf("missing GITHUB_TOKEN")
+	return false
+}
+
+// CreatePR creates a pull request on the current GitHub repository, as a
+// draft when draft is set.
+func CreatePR(head, title, body string, draft bool) (*PullRequest, error) {
+	repo, token, err := credentials()
+	if err != nil {
+		return nil, err
 	}
 
 	base := getDefaultBranch(repo, token)
-	slog.Info("creating pull request", "repo", repo, "head", head, "base", base)
+	slog.Info("creating pull request", "repo", repo, "head", head, "base", base, "draft", draft)
 
-	reqBody := map[string]string{
+	reqBody := map[string]any{
 		"title": title,
 		"body":  body,
 		"head":  head,
 		"base":  base,
+		"draft": draft,
+	}
+	var pr PullRequest
+	if err := doJSON("POST", fmt.Sprintf("/repos/%s/pulls", repo), token, reqBody, &pr); err != nil {
+		slog.Error("create pull request failed", "repo", repo, "error", err)
+		return nil, err
+	}
+	if pr.URL == "" {
+		return nil, fmt.Errorf("github api: missing html_url in response")
 	}
+	slog.Info("pull request created", "repo", repo, "number", pr.Number, "url", pr.URL)
+	return &pr, nil
+}
 
-	b, err := json.Marshal(reqBody)
+// GetPR returns a pull request of the current repository.
+func GetPR(number int) (*PullRequest, error) {
+	repo, token, err := credentials()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	req, err := http.NewRequest("POST", fmt.Sprintf("https://api.github.com/repos/%s/pulls", repo), bytes.NewReader(b))
+	var pr PullRequest
+	if err := doJSON("GET", fmt.Sprintf("/repos/%s/pulls/%d", repo, number), token, nil, &pr); err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+// GetPRDiff returns the unified diff of a pull request against its base.
+func GetPRDiff(number int) (string, error) {
+	repo, token, err := credentials()
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := httpClient.Do(req)
+	b, err := do("GET", fmt.Sprintf("/repos/%s/pulls/%d", repo, number), token, "application/vnd.github.diff", nil)
 	if err != nil {
 		return "", err
 	}
-	defer func() { _ = resp.Body.Close() }()
+	return string(b), nil
+}
 
-	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		slog.Error("create pull request failed", "repo", repo, "status_code", resp.StatusCode)
-		return "", fmt.Errorf("github api http %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+// DefaultBranch returns the default branch of the current repository,
+// falling back to main when it cannot be looked up.
+func DefaultBranch() string {
+	repo, token, err := credentials()
+	if err != nil {
+		return "main"
 	}
+	return getDefaultBranch(repo, token)
+}
 
+// Permission returns a user's role on the current repository: admin,
+// maintain, write, triage, read or none.
+func Permission(user string) (string, error) {
+	repo, token, err := credentials()
+	if err != nil {
+		return "", err
+	}
 	var res struct {
-		HTMLURL string `json:"html_url"`
+		Permission string `json:"permission"`
+		RoleName   string `json:"role_name"`
 	}
-	if err := json.Unmarshal(respBody, &res); err != nil {
+	if err := doJSON("GET", fmt.Sprintf("/repos/%s/collaborators/%s/permission", repo, url.PathEscape(user)), token, nil, &res); err != nil {
 		return "", err
 	}
-	if res.HTMLURL == "" {
-		return "", fmt.Errorf("github api: missing html_url in response")
+	// role_name distinguishes maintain and triage, which permission folds
+	// into write and read.
+	if res.RoleName != "" {
+		return res.RoleName, nil
 	}
-	slog.Info("pull request created", "repo", repo, "url", res.HTMLURL)
-	return res.HTMLURL, nil
+	return res.Permission, nil
 }
 
-func getDefaultBranch(repo, token string) string {
-	req, err := http.NewRequest("GET", fmt.Sprintf("https://api.github.com/repos/%s", repo), nil)
+// ListReviewComments returns up to 100 line comments of a pull request review.
+func ListReviewComments(number int) ([]Comment, error) {
+	return listComments(fmt.Sprintf("pulls/%d/comments", number))
+}
+
+// ListIssueComments returns up to 100 conversation comments of an issue or
+// pull request.
+func ListIssueComments(number int) ([]Comment, error) {
+	return listComments(fmt.Sprintf("issues/%d/comments", number))
+}
+
+// ListReviews returns up to 100 reviews of a pull request; Body is the review summary.
+func ListReviews(number int) ([]Comment, error) {
+	return listComments(fmt.Sprintf("pulls/%d/reviews", number))
+}
+
+func listComments(path string) ([]Comment, error) {
+	repo, token, err := credentials()
 	if err != nil {
-		return "main"
+		return nil, err
+	}
+	var out []Comment
+	if err := doJSON("GET", fmt.Sprintf("/repos/%s/%s?per_page=100", repo, path), token, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReplyToReviewComment replies in the thread of a pull request review comment.
+func ReplyToReviewComment(number int, commentID int64, body string) error {
+	repo, token, err := credentials()
+	if err != nil {
+		return err
+	}
+	return doJSON("POST", fmt.Sprintf("/repos/%s/pulls/%d/comments/%d/replies", repo, number, commentID), token, map[string]string{"body": body}, nil)
+}
+
+// CreateIssueComment posts a conversation comment on an issue or pull request.
+func CreateIssueComment(number int, body string) error {
+	repo, token, err := credentials()
+	if err != nil {
+		return err
+	}
+	return doJSON("POST", fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), token, map[string]string{"body": body}, nil)
+}
+
+// ListOpenIssues returns up to 100 open issues with the label, oldest
+// first. Pull requests are left out.
+func ListOpenIssues(label string) ([]Issue, error) {
+	repo, token, err := credentials()
+	if err != nil {
+		return nil, err
+	}
+	var all []Issue
+	path := fmt.Sprintf("/repos/%s/issues?state=open&labels=%s&sort=created&direction=asc&per_page=100", repo, url.QueryEscape(label))
+	if err := doJSON("GET", path, token, nil, &all); err != nil {
+		return nil, err
+	}
+	issues := all[:0]
+	for _, i := range all {
+		if i.PullRequest == nil {
+			issues = append(issues, i)
+		}
+	}
+	return issues, nil
+}
+
+// ListOpenPRs returns up to 100 open pull requests, newest first.
+func ListOpenPRs() ([]PullRequest, error) {
+	repo, token, err := credentials()
+	if err != nil {
+		return nil, err
+	}
+	var prs []PullRequest
+	if err := doJSON("GET", fmt.Sprintf("/repos/%s/pulls?state=open&sort=created&direction=desc&per_page=100", repo), token, nil, &prs); err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+// UpdatePRBody replaces the description of a pull request.
+func UpdatePRBody(number int, body string) error {
+	repo, token, err := credentials()
+	if err != nil {
+		return err
+	}
+	slog.Info("updating pull request body", "repo", repo, "number", number)
+	return doJSON("PATCH", fmt.Sprintf("/repos/%s/pulls/%d", repo, number), token, map[string]string{"body": body}, nil)
+}
+
+// AddLabels adds labels to an issue or pull request, creating missing labels
+// with default colors.
+func AddLabels(number int, labels []string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	repo, token, err := credentials()
+	if err != nil {
+		return err
+	}
+	slog.Info("adding labels", "repo", repo, "number", number, "labels", strings.Join(labels, ","))
+	return doJSON("POST", fmt.Sprintf("/repos/%s/issues/%d/labels", repo, number), token, map[string][]string{"labels": labels}, nil)
+}
+
+// RemoveLabel removes a label from an issue or pull request. A label that
+// is not present is not an error.
+func RemoveLabel(number int, label string) error {
+	repo, token, err := credentials()
+	if err != nil {
+		return err
+	}
+	err = doJSON("DELETE", fmt.Sprintf("/repos/%s/issues/%d/labels/%s", repo, number, url.PathEscape(label)), token, nil, nil)
+	if err != nil && strings.Contains(err.Error(), "http 404") {
+		return nil
+	}
+	return err
+}
+
+// AddAssignees assigns users to an issue or pull request.
+func AddAssignees(number int, assignees []string) error {
+	if len(assignees) == 0 {
+		return nil
+	}
+	repo, token, err := credentials()
+	if err != nil {
+		return err
+	}
+	slog.Info("adding assignees", "repo", repo, "number", number, "assignees", strings.Join(assignees, ","))
+	return doJSON("POST", fmt.Sprintf("/repos/%s/issues/%d/assignees", repo, number), token, map[string][]string{"assignees": assignees}, nil)
+}
+
+// SetMilestone sets the milestone of an issue or pull request. milestone is
+// either a milestone number or the title of an open milestone.
+func SetMilestone(number int, milestone string) error {
+	milestone = strings.TrimSpace(milestone)
+	if milestone == "" {
+		return nil
+	}
+	repo, token, err := credentials()
+	if err != nil {
+		return err
+	}
+	id, err := strconv.Atoi(milestone)
+	if err != nil {
+		var open []struct {
+			Number int    `json:"number"`
+			Title  string `json:"title"`
+		}
+		if err := doJSON("GET", fmt.Sprintf("/repos/%s/milestones?state=open&per_page=100", repo), token, nil, &open); err != nil {
+			return err
+		}
+		for _, m := range open {
+			if m.Title == milestone {
+				id = m.Number
+				break
+			}
+		}
+		if id == 0 {
+			return fmt.Errorf("no open milestone titled %q", milestone)
+		}
+	}
+	slog.Info("setting milestone", "repo", repo, "number", number, "milestone", id)
+	return doJSON("PATCH", fmt.Sprintf("/repos/%s/issues/%d", repo, number), token, map[string]int{"milestone": id}, nil)
+}
+
+// RequestReviewers asks users and teams (by slug) to review a pull request.
+func RequestReviewers(number int, users, teams []string) error {
+	if len(users) == 0 && len(teams) == 0 {
+		return nil
+	}
+	repo, token, err := credentials()
+	if err != nil {
+		return err
+	}
+	slog.Info("requesting pull request reviewers", "repo", repo, "number", number, "users", strings.Join(users, ","), "teams", strings.Join(teams, ","))
+	req := map[string][]string{"reviewers": users, "team_reviewers": teams}
+	return doJSON("POST", fmt.Sprintf("/repos/%s/pulls/%d/requested_reviewers", repo, number), token, req, nil)
+}
+
+func credentials() (repo, token string, err error) {
+	repo = strings.TrimSpace(os.Getenv("GITHUB_REPOSITORY"))
+	token = strings.TrimSpace(os.Getenv("GITHUB_TOKEN"))
+	if repo == "" {
+		return "", "", fmt.Errorf("missing GITHUB_REPOSITORY")
+	}
+	if token == "" {
+		return "", "", fmt.Errorf("missing GITHUB_TOKEN")
+	}
+	return repo, token, nil
+}
+
+// doJSON sends in (when non-nil) as JSON to the GitHub API path and decodes
+// the response into out (when non-nil).
+func doJSON(method, path, token string, in, out any) error {
+	respBody, err := do(method, path, token, "application/vnd.github+json", in)
+	if err != nil {
+		return err
+	}
+	if out == nil || len(bytes.TrimSpace(respBody)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// do sends in (when non-nil) as JSON and returns the response body, up to
+// 1 MiB, in the media type accept.
+func do(method, path, token, accept string, in any) ([]byte, error) {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, "https://api.github.com"+path, body)
+	if err != nil {
+		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Accept", accept)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "main"
+		return nil, err
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return "main"
+		return nil, fmt.Errorf("github api http %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
 	}
+	return respBody, nil
+}
 
+func getDefaultBranch(repo, token string) string {
 	var res struct {
 		DefaultBranch string `json:"default_branch"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+	if err := doJSON("GET", fmt.Sprintf("/repos/%s", repo), token, nil, &res); err != nil {
 		return "main"
 	}
 	if res.DefaultBranch == "" {
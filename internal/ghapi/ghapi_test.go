@@ -52,7 +52,7 @@ func TestGetDefaultBranchReturnsValueFromAPI(t *testing.T) {
 }
 
 func TestCreatePRBuildsRequestAndReturnsURL(t *testing.T) {
-	var gotBody map[string]string
+	var gotBody map[string]any
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
 		case "/repos/acme/repo":
@@ -64,7 +64,7 @@ func TestCreatePRBuildsRequestAndReturnsURL(t *testing.T) {
 			if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
 				t.Fatalf("decode request: %v", err)
 			}
-			_ = json.NewEncoder(w).Encode(map[string]string{"html_url": "https://example/pr/1"})
+			_ = json.NewEncoder(w).Encode(map[string]any{"number": 1, "html_url": "https://example/pr/1", "user": map[string]string{"login": "github-actions[bot]", "type": "Bot"}})
 		default:
 			t.Fatalf("unexpected path: %s", r.URL.Path)
 		}
@@ -74,21 +74,234 @@ func TestCreatePRBuildsRequestAndReturnsURL(t *testing.T) {
 
 	t.Setenv("GITHUB_REPOSITORY", "acme/repo")
 	t.Setenv("GITHUB_TOKEN", "abc")
-	url, err := CreatePR("evolve/branch", "Improve safety", "Body")
+	pr, err := CreatePR("evolve/branch", "Improve safety", "Body", true)
 	if err != nil {
 		t.Fatalf("create PR: %v", err)
 	}
-	if url != "https://example/pr/1" {
-		t.Fatalf("unexpected html url: %s", url)
+	if pr.URL != "https://example/pr/1" || pr.Number != 1 || pr.User.Login != "github-actions[bot]" {
+		t.Fatalf("unexpected pull request: %+v", pr)
 	}
-	if gotBody["base"] != "main" || gotBody["head"] != "evolve/branch" {
+	if gotBody["base"] != "main" || gotBody["head"] != "evolve/branch" || gotBody["draft"] != true {
 		t.Fatalf("unexpected PR payload: %#v", gotBody)
 	}
-	if !strings.Contains(gotBody["title"], "Improve safety") {
+	if title, _ := gotBody["title"].(string); !strings.Contains(title, "Improve safety") {
 		t.Fatalf("expected title in payload")
 	}
 }
 
+func TestAddLabelsPostsToIssueLabels(t *testing.T) {
+	var got map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/acme/repo/issues/7/labels" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+	withRedirectedGitHubAPI(t, srv)
+
+	t.Setenv("GITHUB_REPOSITORY", "acme/repo")
+	t.Setenv("GITHUB_TOKEN", "abc")
+	if err := AddLabels(7, []string{"evolver", "deps-approval"}); err != nil {
+		t.Fatalf("add labels: %v", err)
+	}
+	if strings.Join(got["labels"], ",") != "evolver,deps-approval" {
+		t.Fatalf("unexpected labels payload: %v", got)
+	}
+}
+
+func TestRequestReviewersPostsUsersAndTeams(t *testing.T) {
+	var got map[string][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/acme/repo/pulls/7/requested_reviewers" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	withRedirectedGitHubAPI(t, srv)
+
+	t.Setenv("GITHUB_REPOSITORY", "acme/repo")
+	t.Setenv("GITHUB_TOKEN", "abc")
+	if err := RequestReviewers(7, []string{"gopher"}, []string{"core"}); err != nil {
+		t.Fatalf("request reviewers: %v", err)
+	}
+	if strings.Join(got["reviewers"], ",") != "gopher" || strings.Join(got["team_reviewers"], ",") != "core" {
+		t.Fatalf("unexpected reviewers payload: %v", got)
+	}
+}
+
+func TestSetMilestoneResolvesTitle(t *testing.T) {
+	var patched map[string]int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/repo/milestones":
+			_ = json.NewEncoder(w).Encode([]map[string]any{{"number": 3, "title": "v1.1"}, {"number": 4, "title": "v1.2"}})
+		case r.Method == http.MethodPatch && r.URL.Path == "/repos/acme/repo/issues/7":
+			if err := json.NewDecoder(r.Body).Decode(&patched); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			_, _ = w.Write([]byte("{}"))
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	withRedirectedGitHubAPI(t, srv)
+
+	t.Setenv("GITHUB_REPOSITORY", "acme/repo")
+	t.Setenv("GITHUB_TOKEN", "abc")
+	if err := SetMilestone(7, "v1.2"); err != nil {
+		t.Fatalf("set milestone: %v", err)
+	}
+	if patched["milestone"] != 4 {
+		t.Fatalf("expected milestone 4, got %v", patched)
+	}
+	if err := SetMilestone(7, "v9"); err == nil {
+		t.Fatalf("expected unknown milestone title to fail")
+	}
+}
+
+func TestListOpenPRsAndUpdateBody(t *testing.T) {
+	var patched map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/repo/pulls":
+			if r.URL.Query().Get("state") != "open" {
+				t.Fatalf("expected open pull requests, got %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write([]byte(`[{"number": 9, "html_url": "https://example/pr/9", "head": {"ref": "evolve/2026-01-01-000000"}, "labels": [{"name": "evolver"}]}]`))
+		case r.Method == http.MethodPatch && r.URL.Path == "/repos/acme/repo/pulls/9":
+			if err := json.NewDecoder(r.Body).Decode(&patched); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			_, _ = w.Write([]byte("{}"))
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	withRedirectedGitHubAPI(t, srv)
+
+	t.Setenv("GITHUB_REPOSITORY", "acme/repo")
+	t.Setenv("GITHUB_TOKEN", "abc")
+	prs, err := ListOpenPRs()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(prs) != 1 || prs[0].Head.Ref != "evolve/2026-01-01-000000" || !prs[0].HasLabel("evolver") {
+		t.Fatalf("unexpected pull requests: %+v", prs)
+	}
+	if err := UpdatePRBody(9, "new body"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if patched["body"] != "new body" {
+		t.Fatalf("unexpected patch payload: %v", patched)
+	}
+}
+
+func TestReviewCommentsAndReplies(t *testing.T) {
+	var replies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/repo/pulls/7/comments":
+			_, _ = w.Write([]byte(`[{"id": 11, "body": "rename this", "path": "a.go", "line": 4, "diff_hunk": "@@ -1 +1 @@", "user": {"login": "alice", "type": "User"}}]`))
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/repo/pulls/7/comments/11/replies",
+			r.Method == http.MethodPost && r.URL.Path == "/repos/acme/repo/issues/7/comments":
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatalf("decode request: %v", err)
+			}
+			replies = append(replies, r.URL.Path+": "+body["body"])
+			_, _ = w.Write([]byte("{}"))
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	withRedirectedGitHubAPI(t, srv)
+
+	t.Setenv("GITHUB_REPOSITORY", "acme/repo")
+	t.Setenv("GITHUB_TOKEN", "abc")
+	comments, err := ListReviewComments(7)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(comments) != 1 || comments[0].Path != "a.go" || comments[0].Line != 4 || comments[0].User.Login != "alice" {
+		t.Fatalf("unexpected comments: %+v", comments)
+	}
+	if err := ReplyToReviewComment(7, 11, "done"); err != nil {
+		t.Fatalf("reply: %v", err)
+	}
+	if err := CreateIssueComment(7, "thanks"); err != nil {
+		t.Fatalf("comment: %v", err)
+	}
+	want := "/repos/acme/repo/pulls/7/comments/11/replies: done,/repos/acme/repo/issues/7/comments: thanks"
+	if strings.Join(replies, ",") != want {
+		t.Fatalf("unexpected replies: %v", replies)
+	}
+}
+
+func TestPermissionDiffAndRemoveLabel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/repo/collaborators/alice/permission":
+			_, _ = w.Write([]byte(`{"permission": "write", "role_name": "maintain"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/repo/pulls/7":
+			if r.Header.Get("Accept") != "application/vnd.github.diff" {
+				t.Fatalf("expected diff media type, got %s", r.Header.Get("Accept"))
+			}
+			_, _ = w.Write([]byte("diff --git a/a.go b/a.go\n"))
+		case r.Method == http.MethodDelete && r.URL.Path == "/repos/acme/repo/issues/7/labels/evolver:stopped":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "Label does not exist"}`))
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+	withRedirectedGitHubAPI(t, srv)
+
+	t.Setenv("GITHUB_REPOSITORY", "acme/repo")
+	t.Setenv("GITHUB_TOKEN", "abc")
+	if got, err := Permission("alice"); err != nil || got != "maintain" {
+		t.Fatalf("expected maintain, got %q (%v)", got, err)
+	}
+	if diff, err := GetPRDiff(7); err != nil || !strings.HasPrefix(diff, "diff --git") {
+		t.Fatalf("unexpected diff %q (%v)", diff, err)
+	}
+	if err := RemoveLabel(7, "evolver:stopped"); err != nil {
+		t.Fatalf("expected missing label to be ignored: %v", err)
+	}
+}
+
+func TestListOpenIssuesSkipsPullRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/repo/issues" || r.URL.Query().Get("labels") != "evolver-ok" || r.URL.Query().Get("state") != "open" {
+			t.Fatalf("unexpected request: %s %s", r.URL.Path, r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`[{"number": 3, "title": "Add retries", "created_at": "2026-01-02T03:04:05Z", "labels": [{"name": "evolver-ok"}]}, {"number": 4, "title": "PR", "pull_request": {"url": "x"}}]`))
+	}))
+	defer srv.Close()
+	withRedirectedGitHubAPI(t, srv)
+
+	t.Setenv("GITHUB_REPOSITORY", "acme/repo")
+	t.Setenv("GITHUB_TOKEN", "abc")
+	issues, err := ListOpenIssues("evolver-ok")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Number != 3 || !issues[0].HasLabel("evolver-ok") || issues[0].CreatedAt.Year() != 2026 {
+		t.Fatalf("unexpected issues: %+v", issues)
+	}
+}
+
 func TestGetDefaultBranchFallbackOnTransportError(t *testing.T) {
 	orig := httpClient.Transport
 	httpClient.Transport = roundTripperFunc(func(*http.Request) (*http.Response, error) {
@@ -0,0 +1,90 @@
+// Package ghevent reads the GitHub Actions event payload that triggered a run.
+package ghevent
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Event is the subset of an Actions event payload evolver acts on.
+type Event struct {
+	// Name is GITHUB_EVENT_NAME, such as pull_request_review or issue_comment.
+	Name string
+	// Action is the payload's action, such as created, edited or submitted.
+	Action string
+	// Number is the pull request or issue number, or 0.
+	Number int
+	// IsPullRequest is set for pull request events and for comments on pull
+	// requests (which GitHub delivers as issue_comment).
+	IsPullRequest bool
+	// Comment is the comment of an issue_comment or pull_request_review_comment
+	// event, or the review of a pull_request_review event.
+	Comment *Comment
+}
+
+// Comment is a comment or review body and its author.
+type Comment struct {
+	ID     int64
+	Body   string
+	Author string
+}
+
+type payload struct {
+	Action      string `json:"action"`
+	PullRequest *struct {
+		Number int `json:"number"`
+	} `json:"pull_request"`
+	Issue *struct {
+		Number      int              `json:"number"`
+		PullRequest *json.RawMessage `json:"pull_request"`
+	} `json:"issue"`
+	Comment *rawComment `json:"comment"`
+	Review  *rawComment `json:"review"`
+}
+
+type rawComment struct {
+	ID   int64  `json:"id"`
+	Body string `json:"body"`
+	User struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// Load reads the event named by GITHUB_EVENT_NAME from GITHUB_EVENT_PATH.
+func Load() (*Event, error) {
+	path := os.Getenv("GITHUB_EVENT_PATH")
+	if path == "" {
+		return nil, fmt.Errorf("missing GITHUB_EVENT_PATH")
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(os.Getenv("GITHUB_EVENT_NAME"), b)
+}
+
+// Parse decodes an event payload.
+func Parse(name string, b []byte) (*Event, error) {
+	var p payload
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("parse %s event: %w", name, err)
+	}
+	ev := &Event{Name: name, Action: p.Action}
+	switch {
+	case p.PullRequest != nil:
+		ev.Number = p.PullRequest.Number
+		ev.IsPullRequest = true
+	case p.Issue != nil:
+		ev.Number = p.Issue.Number
+		ev.IsPullRequest = p.Issue.PullRequest != nil
+	}
+	c := p.Comment
+	if c == nil {
+		c = p.Review
+	}
+	if c != nil {
+		ev.Comment = &Comment{ID: c.ID, Body: c.Body, Author: c.User.Login}
+	}
+	return ev, nil
+}
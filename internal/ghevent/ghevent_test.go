@@ -0,0 +1,38 @@
+package ghevent
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIssueCommentOnPullRequest(t *testing.T) {
+	ev, err := Parse("issue_comment", []byte(`{"action":"created","issue":{"number":12,"pull_request":{"url":"x"}},"comment":{"id":5,"body":"please rename","user":{"login":"alice"}}}`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ev.Action != "created" || ev.Number != 12 || !ev.IsPullRequest || ev.Comment == nil || ev.Comment.Author != "alice" || ev.Comment.ID != 5 {
+		t.Fatalf("unexpected event: %+v %+v", ev, ev.Comment)
+	}
+
+	ev, err = Parse("issue_comment", []byte(`{"issue":{"number":3},"comment":{"id":1,"body":"hi","user":{"login":"bob"}}}`))
+	if err != nil || ev.IsPullRequest {
+		t.Fatalf("expected plain issue comment, got %+v (%v)", ev, err)
+	}
+}
+
+func TestLoadPullRequestReview(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(path, []byte(`{"action":"submitted","pull_request":{"number":7},"review":{"id":9,"body":"looks off","user":{"login":"carol"}}}`), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("GITHUB_EVENT_PATH", path)
+	t.Setenv("GITHUB_EVENT_NAME", "pull_request_review")
+	ev, err := Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if ev.Name != "pull_request_review" || ev.Number != 7 || !ev.IsPullRequest || ev.Comment.Body != "looks off" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+}
@@ -1,9 +1,11 @@
 package gitops
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -22,6 +24,33 @@ func CheckoutNew(branch string) error {
 	return cmd.Run()
 }
 
+// CheckoutRemote fetches branch from origin and checks it out as a local
+// branch of the same name, replacing any local branch with that name.
+func CheckoutRemote(branch string) error {
+	slog.Info("checking out remote git branch", "branch", branch)
+	if out, err := exec.Command("git", "fetch", "origin", branch).CombinedOutput(); err != nil {
+		return fmt.Errorf("git fetch origin %s: %w: %s", branch, err, strings.TrimSpace(string(out)))
+	}
+	if out, err := exec.Command("git", "checkout", "-B", branch, "FETCH_HEAD").CombinedOutput(); err != nil {
+		return fmt.Errorf("git checkout %s: %w: %s", branch, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// RebaseOnto fetches base from origin and rebases the current branch onto
+// it. A conflicting rebase is aborted, leaving the branch unchanged.
+func RebaseOnto(base string) error {
+	slog.Info("rebasing git branch", "onto", base)
+	if out, err := exec.Command("git", "fetch", "origin", base).CombinedOutput(); err != nil {
+		return fmt.Errorf("git fetch origin %s: %w: %s", base, err, strings.TrimSpace(string(out)))
+	}
+	if out, err := exec.Command("git", "rebase", "FETCH_HEAD").CombinedOutput(); err != nil {
+		_ = exec.Command("git", "rebase", "--abort").Run()
+		return fmt.Errorf("git rebase onto %s: %w: %s", base, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 // ResetHard resets tracked and untracked files in the repository.
 func ResetHard() {
 	slog.Warn("resetting git working tree with hard reset and clean")
@@ -29,6 +58,98 @@ func ResetHard() {
 	_ = exec.Command("git", "clean", "-fd").Run()
 }
 
+// ExcludeLocal lists path, relative to the current directory, in the
+// repository's info/exclude, so staging and ResetHard leave it alone without
+// touching .gitignore. Paths outside the repository are ignored.
+func ExcludeLocal(path string) error {
+	root, err := TopLevel()
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	// git reports the root with symlinks resolved.
+	if resolved, err := filepath.EvalSymlinks(filepath.Dir(abs)); err == nil {
+		abs = filepath.Join(resolved, filepath.Base(abs))
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	out, err := exec.Command("git", "rev-parse", "--git-path", "info/exclude").Output()
+	if err != nil {
+		return err
+	}
+	excludeFile := strings.TrimSpace(string(out))
+	pattern := "/" + filepath.ToSlash(rel)
+	existing, err := os.ReadFile(excludeFile)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(existing), "\n") {
+		if strings.TrimSpace(line) == pattern {
+			return nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(excludeFile), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(excludeFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	line := pattern + "\n"
+	if len(existing) > 0 && existing[len(existing)-1] != '\n' {
+		line = "\n" + line
+	}
+	if _, err := f.WriteString(line); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// TopLevel returns the absolute path of the repository root.
+func TopLevel() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Prefix returns the current directory relative to the repository root,
+// with a trailing slash, or "" at the root.
+func Prefix() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "--show-prefix").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// AddWorktree creates a detached worktree of HEAD at dir, which must not exist.
+func AddWorktree(dir string) error {
+	slog.Debug("adding git worktree", "dir", dir)
+	out, err := exec.Command("git", "worktree", "add", "--detach", dir, "HEAD").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git worktree add: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// RemoveWorktree deletes a worktree created by AddWorktree, including local changes.
+func RemoveWorktree(dir string) error {
+	slog.Debug("removing git worktree", "dir", dir)
+	out, err := exec.Command("git", "worktree", "remove", "--force", dir).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git worktree remove: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
 // HasChanges reports whether the working tree has any changes (staged or unstaged).
 func HasChanges() (bool, error) {
 	out, err := exec.Command("git", "status", "--porcelain").Output()
@@ -47,47 +168,167 @@ func StageAll() error {
 	return cmd.Run()
 }
 
-// DiffStats returns staged file and line-change counts.
-func DiffStats() (files, lines int, err error) {
+// Checkpoint stages the working tree and returns the id of a tree object
+// capturing it, for use with Restore. It creates no commit or stash entry.
+func Checkpoint() (string, error) {
 	if err := StageAll(); err != nil {
-		return 0, 0, err
+		return "", err
 	}
-	out, err := exec.Command("git", "diff", "--cached", "--numstat").Output()
+	out, err := exec.Command("git", "write-tree").Output()
 	if err != nil {
-		return 0, 0, err
+		return "", err
 	}
+	tree := strings.TrimSpace(string(out))
+	slog.Debug("git checkpoint created", "tree", tree)
+	return tree, nil
+}
 
-	for _, line := range strings.Split(string(out), "\n") {
-		parts := strings.Fields(line)
-		if len(parts) == 3 {
-			files++
-			add, _ := strconv.Atoi(parts[0])
-			del, _ := strconv.Atoi(parts[1])
-			lines += add + del
-		}
+// Restore resets the index and working tree to a tree returned by Checkpoint,
+// removing files that were added after it was taken.
+func Restore(tree string) error {
+	slog.Info("restoring git checkpoint", "tree", tree)
+	if err := StageAll(); err != nil {
+		return err
 	}
-	slog.Info("git diff stats", "files", files, "lines", lines)
-	return files, lines, nil
+	cmd := exec.Command("git", "read-tree", "--reset", "-u", tree)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
 }
 
-// NewFilesCount returns how many files are staged as newly added.
-func NewFilesCount() (int, error) {
+// FileStat is the staged line changes of one file. Path is relative to the
+// repository root; binary files count no lines.
+type FileStat struct {
+	Path    string
+	Added   int
+	Deleted int
+	New     bool
+}
+
+// FileStats returns per-file staged changes relative to HEAD.
+func FileStats() ([]FileStat, error) {
 	if err := StageAll(); err != nil {
-		return 0, err
+		return nil, err
 	}
-	out, err := exec.Command("git", "diff", "--cached", "--name-status", "--diff-filter=A").Output()
+	// -z keeps paths with spaces or non-ASCII characters unquoted, so they
+	// match budget globs.
+	out, err := exec.Command("git", "diff", "--cached", "--numstat", "-z", "--no-renames").Output()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	count := 0
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		if strings.TrimSpace(line) == "" {
+	added, err := exec.Command("git", "diff", "--cached", "--name-only", "-z", "--no-renames", "--diff-filter=A").Output()
+	if err != nil {
+		return nil, err
+	}
+	isNew := map[string]bool{}
+	for _, p := range nulFields(added) {
+		isNew[p] = true
+	}
+
+	var stats []FileStat
+	for _, line := range nulFields(out) {
+		// Records are "<added>\t<deleted>\t<path>", with "-" counts for binary files.
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 {
 			continue
 		}
-		count++
+		add, _ := strconv.Atoi(parts[0])
+		del, _ := strconv.Atoi(parts[1])
+		stats = append(stats, FileStat{Path: parts[2], Added: add, Deleted: del, New: isNew[parts[2]]})
+	}
+	return stats, nil
+}
+
+// NewFiles returns the paths of files staged as newly added, relative to the current directory.
+func NewFiles() ([]string, error) {
+	if err := StageAll(); err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("git", "diff", "--cached", "--name-only", "-z", "--relative", "--diff-filter=A").Output()
+	if err != nil {
+		return nil, err
+	}
+	return nulFields(out), nil
+}
+
+// ChangedFiles returns the paths of files added, modified or deleted relative
+// to HEAD, relative to the current directory.
+func ChangedFiles() ([]string, error) {
+	if err := StageAll(); err != nil {
+		return nil, err
+	}
+	out, err := exec.Command("git", "diff", "--cached", "--name-only", "-z", "--relative", "--no-renames", "HEAD").Output()
+	if err != nil {
+		return nil, err
+	}
+	return nulFields(out), nil
+}
+
+// HeadFiles returns the names of the files directly inside dir (relative to the
+// current directory) at HEAD. A dir that does not exist at HEAD has no files.
+func HeadFiles(dir string) ([]string, error) {
+	spec := headSpec(dir)
+	if err := exec.Command("git", "cat-file", "-e", spec).Run(); err != nil {
+		return nil, nil
+	}
+	out, err := exec.Command("git", "ls-tree", "--full-tree", spec).Output()
+	if err != nil {
+		return nil, err
+	}
+	// Lines are "<mode> <type> <object>\t<name>"; keep files only.
+	var names []string
+	for _, line := range nonEmptyLines(out) {
+		meta, name, ok := strings.Cut(line, "\t")
+		if ok && strings.Contains(meta, " blob ") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// HeadFile returns the content of path (relative to the current directory) at HEAD.
+func HeadFile(path string) ([]byte, error) {
+	return exec.Command("git", "show", headSpec(path)).Output()
+}
+
+// headSpec names path, relative to the current directory, in the HEAD tree.
+func headSpec(path string) string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if path == "." {
+		return "HEAD:./"
+	}
+	return "HEAD:./" + path
+}
+
+// nulFields splits -z command output into its non-empty records.
+func nulFields(out []byte) []string {
+	var fields []string
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// nonEmptyLines splits command output into trimmed, non-empty lines.
+func nonEmptyLines(out []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
 	}
-	slog.Info("git new files count", "new_files", count)
-	return count, nil
+	return lines
+}
+
+// Head returns the commit SHA of HEAD.
+func Head() (string, error) {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
 }
 
 // Commit creates a commit from the current working tree.
@@ -102,6 +343,18 @@ func Commit(msg string) error {
 	return cmd.Run()
 }
 
+// ForcePush pushes branch to origin after a history rewrite, but only while
+// origin's branch is still at expect, the commit fetched before the rewrite.
+// An explicit lease does not depend on remote-tracking refs, which fetching
+// an explicit refspec may leave stale.
+func ForcePush(branch, expect string) error {
+	slog.Info("force pushing git branch", "branch", branch, "expect", expect)
+	cmd := exec.Command("git", "push", "--force-with-lease="+branch+":"+expect, "origin", branch)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
 // Push pushes the given target ref to origin.
 func Push(target string) error {
 	slog.Info("pushing git ref", "target", target)
@@ -8,7 +8,7 @@ import (
 	"testing"
 )
 
-func TestCheckoutNewAndCommitAndDiffStats(t *testing.T) {
+func TestCheckoutNewAndCommitAndFileStats(t *testing.T) {
 	tmp := t.TempDir()
 	wd, err := os.Getwd()
 	if err != nil {
@@ -31,12 +31,22 @@ func TestCheckoutNewAndCommitAndDiffStats(t *testing.T) {
 	if err := os.WriteFile(filepath.Join(tmp, "a.txt"), []byte("hello\nworld\n"), 0644); err != nil {
 		t.Fatalf("write file: %v", err)
 	}
-	files, lines, err := DiffStats()
+	if err := os.MkdirAll(filepath.Join(tmp, "docs dir"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "docs dir", "résumé.md"), []byte("x\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+
+	stats, err := FileStats()
 	if err != nil {
-		t.Fatalf("diff stats: %v", err)
+		t.Fatalf("file stats: %v", err)
+	}
+	if len(stats) != 2 || stats[0].Path != "a.txt" || stats[0].Added != 2 || stats[0].Deleted != 0 || !stats[0].New {
+		t.Fatalf("unexpected file stats: %+v", stats)
 	}
-	if files < 1 || lines < 1 {
-		t.Fatalf("expected non-zero diff stats, got files=%d lines=%d", files, lines)
+	if stats[1].Path != "docs dir/résumé.md" || stats[1].Added != 1 || !stats[1].New {
+		t.Fatalf("expected an unquoted path for a name with a space and non-ASCII characters, got %+v", stats[1])
 	}
 
 	if err := Commit("test commit"); err != nil {
@@ -81,6 +91,306 @@ func TestResetHardCleansWorkingTree(t *testing.T) {
 	}
 }
 
+func TestExcludeLocalKeepsFileOutOfStagingAndReset(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	initRepo(t, tmp)
+	if err := os.MkdirAll("sub/.evolver", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.Chdir("sub"); err != nil {
+		t.Fatalf("chdir sub: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := ExcludeLocal(".evolver/history.jsonl"); err != nil {
+			t.Fatalf("exclude: %v", err)
+		}
+	}
+	if err := os.WriteFile(".evolver/history.jsonl", []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("write ledger: %v", err)
+	}
+	exclude, _ := os.ReadFile(filepath.Join(tmp, ".git", "info", "exclude"))
+	if n := strings.Count(string(exclude), "/sub/.evolver/history.jsonl\n"); n != 1 {
+		t.Fatalf("expected the pattern once in info/exclude, got:\n%s", exclude)
+	}
+	if files, err := ChangedFiles(); err != nil || len(files) != 0 {
+		t.Fatalf("expected the ledger not to be staged, got %v (%v)", files, err)
+	}
+	ResetHard()
+	if _, err := os.Stat(".evolver/history.jsonl"); err != nil {
+		t.Fatalf("expected the ledger to survive a hard reset: %v", err)
+	}
+}
+
+func TestCheckpointAndRestore(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	initRepo(t, tmp)
+	if err := os.WriteFile("tracked.txt", []byte("attempt1\n"), 0644); err != nil {
+		t.Fatalf("write tracked file: %v", err)
+	}
+	if err := os.WriteFile("kept.txt", []byte("kept\n"), 0644); err != nil {
+		t.Fatalf("write new file: %v", err)
+	}
+	tree, err := Checkpoint()
+	if err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	if err := os.WriteFile("tracked.txt", []byte("attempt2\n"), 0644); err != nil {
+		t.Fatalf("write tracked file: %v", err)
+	}
+	if err := os.Remove("kept.txt"); err != nil {
+		t.Fatalf("remove file: %v", err)
+	}
+	if err := os.WriteFile("extra.txt", []byte("extra\n"), 0644); err != nil {
+		t.Fatalf("write extra file: %v", err)
+	}
+
+	if err := Restore(tree); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	for path, want := range map[string]string{"tracked.txt": "attempt1\n", "kept.txt": "kept\n"} {
+		body, err := os.ReadFile(path)
+		if err != nil || string(body) != want {
+			t.Fatalf("expected %s to be %q, got %q (%v)", path, want, string(body), err)
+		}
+	}
+	if _, err := os.Stat("extra.txt"); !os.IsNotExist(err) {
+		t.Fatalf("expected file added after checkpoint to be removed")
+	}
+}
+
+func TestWorktreeCarriesCheckpoint(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	initRepo(t, tmp)
+	if err := os.WriteFile("pending.txt", []byte("uncommitted\n"), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	tree, err := Checkpoint()
+	if err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	wt := filepath.Join(t.TempDir(), "wt")
+	if err := AddWorktree(wt); err != nil {
+		t.Fatalf("add worktree: %v", err)
+	}
+	if err := os.Chdir(wt); err != nil {
+		t.Fatalf("chdir worktree: %v", err)
+	}
+	if err := Restore(tree); err != nil {
+		t.Fatalf("restore in worktree: %v", err)
+	}
+	if body, err := os.ReadFile("pending.txt"); err != nil || string(body) != "uncommitted\n" {
+		t.Fatalf("expected checkpoint contents in worktree, got %q (%v)", string(body), err)
+	}
+	if prefix, err := Prefix(); err != nil || prefix != "" {
+		t.Fatalf("expected empty prefix at worktree root, got %q (%v)", prefix, err)
+	}
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir back: %v", err)
+	}
+	if err := RemoveWorktree(wt); err != nil {
+		t.Fatalf("remove worktree: %v", err)
+	}
+	if _, err := os.Stat(wt); !os.IsNotExist(err) {
+		t.Fatalf("expected worktree directory to be removed")
+	}
+}
+
+func TestChangedFilesAndHeadContent(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	initRepo(t, tmp)
+	if err := os.MkdirAll("pkg", 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join("pkg", "a.go"), []byte("package pkg\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	runGit(t, "add", ".")
+	runGit(t, "commit", "-m", "add pkg")
+
+	if err := os.WriteFile(filepath.Join("pkg", "a.go"), []byte("package pkg // changed\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Remove("tracked.txt"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	changed, err := ChangedFiles()
+	if err != nil {
+		t.Fatalf("changed files: %v", err)
+	}
+	if strings.Join(changed, ",") != "pkg/a.go,tracked.txt" {
+		t.Fatalf("unexpected changed files: %v", changed)
+	}
+
+	if err := os.Chdir("pkg"); err != nil {
+		t.Fatalf("chdir pkg: %v", err)
+	}
+	names, err := HeadFiles(".")
+	if err != nil || strings.Join(names, ",") != "a.go" {
+		t.Fatalf("unexpected head files: %v (%v)", names, err)
+	}
+	body, err := HeadFile("a.go")
+	if err != nil || string(body) != "package pkg\n" {
+		t.Fatalf("unexpected head content: %q (%v)", string(body), err)
+	}
+	if names, err := HeadFiles("missing"); err != nil || len(names) != 0 {
+		t.Fatalf("expected no files for a directory missing at HEAD, got %v (%v)", names, err)
+	}
+}
+
+func TestCheckoutRemoteContinuesExistingBranch(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	remote := filepath.Join(tmp, "remote.git")
+	work := filepath.Join(tmp, "work")
+	if err := os.MkdirAll(work, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	runGit(t, "init", "--bare", "-q", remote)
+	if err := os.Chdir(work); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	initRepo(t, work)
+	runGit(t, "remote", "add", "origin", remote)
+	runGit(t, "push", "-q", "origin", "HEAD:refs/heads/evolve/old")
+	runGit(t, "checkout", "-q", "-b", "evolve/old")
+	if err := os.WriteFile(filepath.Join(work, "pr.txt"), []byte("from pr\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	runGit(t, "add", ".")
+	runGit(t, "commit", "-q", "-m", "pr work")
+	runGit(t, "push", "-q", "origin", "evolve/old")
+	runGit(t, "checkout", "-q", "-")
+	runGit(t, "branch", "-q", "-D", "evolve/old")
+
+	if err := CheckoutRemote("evolve/old"); err != nil {
+		t.Fatalf("checkout remote: %v", err)
+	}
+	if got := strings.TrimSpace(runGit(t, "branch", "--show-current")); got != "evolve/old" {
+		t.Fatalf("expected evolve/old, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(work, "pr.txt")); err != nil {
+		t.Fatalf("expected pr branch content: %v", err)
+	}
+}
+
+func TestRebaseOntoAndForcePush(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	remote := filepath.Join(tmp, "remote.git")
+	work := filepath.Join(tmp, "work")
+	if err := os.MkdirAll(work, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	runGit(t, "init", "--bare", "-q", remote)
+	if err := os.Chdir(work); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	initRepo(t, work)
+	runGit(t, "remote", "add", "origin", remote)
+	runGit(t, "push", "-q", "origin", "HEAD:refs/heads/main")
+	runGit(t, "checkout", "-q", "-b", "evolve/pr")
+	if err := os.WriteFile(filepath.Join(work, "pr.txt"), []byte("from pr\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	runGit(t, "add", ".")
+	runGit(t, "commit", "-q", "-m", "pr work")
+	runGit(t, "push", "-q", "origin", "evolve/pr")
+
+	runGit(t, "checkout", "-q", "-b", "advance", "origin/main")
+	if err := os.WriteFile(filepath.Join(work, "main.txt"), []byte("on main\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	runGit(t, "add", ".")
+	runGit(t, "commit", "-q", "-m", "main work")
+	runGit(t, "push", "-q", "origin", "HEAD:main")
+	runGit(t, "checkout", "-q", "evolve/pr")
+	lease := strings.TrimSpace(runGit(t, "rev-parse", "HEAD"))
+
+	if err := RebaseOnto("main"); err != nil {
+		t.Fatalf("rebase: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(work, "main.txt")); err != nil {
+		t.Fatalf("expected main content after rebase: %v", err)
+	}
+
+	// A push that lands after the lease was taken is not overwritten, even
+	// though it also moved the remote-tracking ref.
+	runGit(t, "checkout", "-q", "-b", "concurrent", lease)
+	if err := os.WriteFile(filepath.Join(work, "other.txt"), []byte("concurrent\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	runGit(t, "add", ".")
+	runGit(t, "commit", "-q", "-m", "concurrent work")
+	runGit(t, "push", "-q", "origin", "HEAD:evolve/pr")
+	runGit(t, "checkout", "-q", "evolve/pr")
+	if err := ForcePush("evolve/pr", lease); err == nil {
+		t.Fatalf("expected force push to refuse overwriting a concurrent push")
+	}
+
+	runGit(t, "push", "-q", "--force", "origin", lease+":refs/heads/evolve/pr")
+	if err := ForcePush("evolve/pr", lease); err != nil {
+		t.Fatalf("force push: %v", err)
+	}
+	if log := runGit(t, "log", "--format=%s", "origin/evolve/pr"); !strings.HasPrefix(log, "pr work\nmain work\n") {
+		t.Fatalf("expected rebased history on origin, got %q", log)
+	}
+}
+
 func initRepo(t *testing.T, dir string) {
 	t.Helper()
 	runGit(t, "init")
@@ -0,0 +1,271 @@
+// Package gocheck parses and type-checks planned Go files before they are
+// written, so cheap mistakes can be fixed without running verification.
+package gocheck
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Diagnostic is a syntax or type error located in a planned file.
+type Diagnostic struct {
+	Path    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s", d.Path, d.Line, d.Column, d.Message)
+}
+
+// Check parses the planned Go files (path relative to the working directory →
+// content) and type-checks the packages they belong to, with planned content
+// overlaid on the files on disk. Only diagnostics located in planned files are
+// returned. Type checking is skipped when any planned file has syntax errors.
+//
+// Imports are resolved from source on disk, so a planned change to an imported
+// package is not visible to its importers here; verification still catches that.
+func Check(files map[string]string) ([]Diagnostic, error) {
+	ov, err := newOverlay(files)
+	if err != nil {
+		return nil, err
+	}
+	if len(ov.files) == 0 {
+		return nil, nil
+	}
+
+	fset := token.NewFileSet()
+	var diags []Diagnostic
+	for _, path := range ov.sorted() {
+		if _, err := parser.ParseFile(fset, path, ov.files[path], parser.AllErrors); err != nil {
+			diags = append(diags, syntaxDiagnostics(ov, err)...)
+		}
+	}
+	if len(diags) > 0 {
+		return diags, nil
+	}
+
+	imp := importer.ForCompiler(fset, "source", nil)
+	seen := map[string]bool{}
+	for _, dir := range ov.dirs() {
+		for _, d := range ov.checkDir(fset, imp, dir) {
+			if s := d.String(); !seen[s] {
+				seen[s] = true
+				diags = append(diags, d)
+			}
+		}
+	}
+	return diags, nil
+}
+
+// overlay maps absolute paths of planned files to their content and keeps the
+// relative paths used in diagnostics.
+type overlay struct {
+	files map[string]string
+	rel   map[string]string
+}
+
+func newOverlay(files map[string]string) (*overlay, error) {
+	ov := &overlay{files: map[string]string{}, rel: map[string]string{}}
+	for path, content := range files {
+		if !strings.HasSuffix(path, ".go") {
+			continue
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		ov.files[abs] = content
+		ov.rel[abs] = filepath.Clean(path)
+	}
+	return ov, nil
+}
+
+func (ov *overlay) sorted() []string {
+	out := make([]string, 0, len(ov.files))
+	for p := range ov.files {
+		out = append(out, p)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (ov *overlay) dirs() []string {
+	set := map[string]bool{}
+	for p := range ov.files {
+		set[filepath.Dir(p)] = true
+	}
+	out := make([]string, 0, len(set))
+	for d := range set {
+		out = append(out, d)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// context returns a build context that sees planned files in place of (or in
+// addition to) the files on disk.
+func (ov *overlay) context() build.Context {
+	ctx := build.Default
+	ctx.OpenFile = func(path string) (io.ReadCloser, error) {
+		if content, ok := ov.files[path]; ok {
+			return io.NopCloser(strings.NewReader(content)), nil
+		}
+		return os.Open(path)
+	}
+	ctx.ReadDir = func(dir string) ([]fs.FileInfo, error) {
+		entries, err := os.ReadDir(dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		byName := map[string]fs.FileInfo{}
+		for _, e := range entries {
+			if info, ierr := e.Info(); ierr == nil {
+				byName[e.Name()] = info
+			}
+		}
+		for p, content := range ov.files {
+			if filepath.Dir(p) == dir {
+				byName[filepath.Base(p)] = fileInfo{name: filepath.Base(p), size: int64(len(content))}
+			}
+		}
+		if len(byName) == 0 && err != nil {
+			return nil, err
+		}
+		out := make([]fs.FileInfo, 0, len(byName))
+		for _, info := range byName {
+			out = append(out, info)
+		}
+		sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+		return out, nil
+	}
+	return ctx
+}
+
+// checkDir type-checks the package in dir, and again with its in-package test
+// files when the plan touches any of them. External test packages are only
+// syntax-checked.
+func (ov *overlay) checkDir(fset *token.FileSet, imp types.Importer, dir string) []Diagnostic {
+	ctx := ov.context()
+	pkg, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		var mp *build.MultiplePackageError
+		if errors.As(err, &mp) {
+			var diags []Diagnostic
+			for _, p := range ov.sorted() {
+				if filepath.Dir(p) == dir {
+					diags = append(diags, Diagnostic{Path: ov.rel[p], Line: 1, Column: 1, Message: mp.Error()})
+				}
+			}
+			return diags
+		}
+		// No buildable files for this platform, or similar: nothing to type-check.
+		return nil
+	}
+
+	sets := [][]string{pkg.GoFiles}
+	if ov.touches(dir, pkg.TestGoFiles) {
+		sets = append(sets, append(append([]string{}, pkg.GoFiles...), pkg.TestGoFiles...))
+	}
+	var diags []Diagnostic
+	for _, names := range sets {
+		if !ov.touches(dir, names) {
+			continue
+		}
+		diags = append(diags, ov.typeCheck(fset, imp, dir, pkg.ImportPath, names)...)
+	}
+	return diags
+}
+
+func (ov *overlay) touches(dir string, names []string) bool {
+	for _, n := range names {
+		if _, ok := ov.files[filepath.Join(dir, n)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (ov *overlay) typeCheck(fset *token.FileSet, imp types.Importer, dir, importPath string, names []string) []Diagnostic {
+	var files []*ast.File
+	for _, n := range names {
+		path := filepath.Join(dir, n)
+		var src any
+		if content, ok := ov.files[path]; ok {
+			src = content
+		}
+		f, err := parser.ParseFile(fset, path, src, 0)
+		if err != nil {
+			// A broken file that is not part of the plan is not the plan's fault.
+			return nil
+		}
+		files = append(files, f)
+	}
+
+	var diags []Diagnostic
+	conf := types.Config{
+		Importer:    imp,
+		FakeImportC: true,
+		Error: func(err error) {
+			var terr types.Error
+			if !errors.As(err, &terr) {
+				return
+			}
+			// Unresolvable imports are an environment problem, not a plan error.
+			if strings.Contains(terr.Msg, "could not import") {
+				return
+			}
+			pos := fset.Position(terr.Pos)
+			rel, ok := ov.rel[pos.Filename]
+			if !ok {
+				return
+			}
+			diags = append(diags, Diagnostic{Path: rel, Line: pos.Line, Column: pos.Column, Message: terr.Msg})
+		},
+	}
+	_, _ = conf.Check(importPath, fset, files, nil)
+	return diags
+}
+
+func syntaxDiagnostics(ov *overlay, err error) []Diagnostic {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) {
+		return []Diagnostic{{Message: err.Error()}}
+	}
+	out := make([]Diagnostic, 0, len(list))
+	for _, e := range list {
+		path := e.Pos.Filename
+		if rel, ok := ov.rel[path]; ok {
+			path = rel
+		}
+		out = append(out, Diagnostic{Path: path, Line: e.Pos.Line, Column: e.Pos.Column, Message: e.Msg})
+	}
+	return out
+}
+
+type fileInfo struct {
+	name string
+	size int64
+}
+
+func (f fileInfo) Name() string       { return f.name }
+func (f fileInfo) Size() int64        { return f.size }
+func (f fileInfo) Mode() fs.FileMode  { return 0644 }
+func (f fileInfo) ModTime() time.Time { return time.Time{} }
+func (f fileInfo) IsDir() bool        { return false }
+func (f fileInfo) Sys() any           { return nil }
@@ -0,0 +1,82 @@
+package gocheck
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupModule(t *testing.T) {
+	t.Helper()
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	write := func(path, content string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	write("go.mod", "module example.com/m\n\ngo 1.21\n")
+	write("lib/lib.go", "package lib\n\nfunc Double(n int) int { return n * 2 }\n")
+	write("lib/broken.go", "package lib\n\nvar broken int = \"not an int\"\n")
+}
+
+func TestCheckReportsSyntaxErrors(t *testing.T) {
+	setupModule(t)
+	diags, err := Check(map[string]string{"lib/new.go": "package lib\n\nfunc Oops( {\n"})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if len(diags) == 0 || diags[0].Path != filepath.Join("lib", "new.go") || diags[0].Line != 3 {
+		t.Fatalf("expected syntax diagnostic in lib/new.go line 3, got %v", diags)
+	}
+}
+
+func TestCheckReportsTypeErrorsOnlyInPlannedFiles(t *testing.T) {
+	setupModule(t)
+	diags, err := Check(map[string]string{
+		"lib/lib.go":      "package lib\n\nimport \"strings\"\n\nfunc Double(n int) int { return n * 2 }\n\nfunc Upper(s string) string { return strings.ToUpper(s) + missing }\n",
+		"lib/lib_test.go": "package lib\n\nimport \"testing\"\n\nfunc TestDouble(t *testing.T) { _ = Double(\"x\") }\n",
+		"README.md":       "ignored",
+	})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	joined := strings.Join(got, "\n")
+	if !strings.Contains(joined, "lib/lib.go:7:") || !strings.Contains(joined, "undefined: missing") {
+		t.Fatalf("expected undefined identifier in lib/lib.go, got:\n%s", joined)
+	}
+	if !strings.Contains(joined, "lib/lib_test.go:5:") {
+		t.Fatalf("expected type error in planned test file, got:\n%s", joined)
+	}
+	if strings.Contains(joined, "broken.go") {
+		t.Fatalf("errors in unplanned files must not be reported:\n%s", joined)
+	}
+}
+
+func TestCheckAcceptsValidNewPackage(t *testing.T) {
+	setupModule(t)
+	diags, err := Check(map[string]string{
+		"tools/fresh/fresh.go": "package fresh\n\nimport \"fmt\"\n\nfunc Hello() string { return fmt.Sprint(\"hi\") }\n",
+	})
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if len(diags) != 0 {
+		t.Fatalf("expected no diagnostics, got %v", diags)
+	}
+}
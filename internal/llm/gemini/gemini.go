@@ -8,10 +8,13 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/mmrzaf/evolver/internal/changelog"
 	"github.com/mmrzaf/evolver/internal/config"
 	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/repair"
 	"github.com/mmrzaf/evolver/internal/repoctx"
 )
 
@@ -22,6 +25,42 @@ type Client struct {
 	HTTP           *http.Client
 	MaxAttempts    int
 	RetryBaseDelay time.Duration
+	// Temperature and Seed override the model's sampling defaults when set.
+	Temperature *float64
+	Seed        *int
+	// Usage accumulates token counts; copies made by WithSampling share it.
+	Usage *Usage
+}
+
+// Usage is the token usage reported by the API across calls.
+type Usage struct {
+	mu           sync.Mutex
+	Calls        int
+	PromptTokens int
+	OutputTokens int
+	TotalTokens  int
+}
+
+// Snapshot returns the counts so far; a nil Usage has none.
+func (u *Usage) Snapshot() (calls, prompt, output, total int) {
+	if u == nil {
+		return 0, 0, 0, 0
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.Calls, u.PromptTokens, u.OutputTokens, u.TotalTokens
+}
+
+func (u *Usage) add(prompt, output, total int) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.Calls++
+	u.PromptTokens += prompt
+	u.OutputTokens += output
+	u.TotalTokens += total
 }
 
 // NewClient creates a Gemini client.
@@ -32,9 +71,19 @@ func NewClient(apiKey, model string) *Client {
 		HTTP:           &http.Client{Timeout: 60 * time.Second},
 		MaxAttempts:    2,
 		RetryBaseDelay: 300 * time.Millisecond,
+		Usage:          &Usage{},
 	}
 }
 
+// WithSampling returns a copy of c that samples with the given temperature and
+// seed, so repeated calls for the same prompt yield independent candidates.
+func (c *Client) WithSampling(temperature float64, seed int) *Client {
+	cp := *c
+	cp.Temperature = &temperature
+	cp.Seed = &seed
+	return &cp
+}
+
 // GeneratePlan asks Gemini for a structured change plan for the repository.
 func (c *Client) GeneratePlan(ctx *repoctx.Context, cfg *config.Config) (*plan.Plan, error) {
 	if strings.TrimSpace(c.APIKey) == "" {
@@ -79,13 +128,14 @@ func (c *Client) GeneratePlan(ctx *repoctx.Context, cfg *config.Config) (*plan.P
 }
 
 // GenerateRepairPlan asks Gemini for a minimal repair plan based on a concrete verification failure.
-func (c *Client) GenerateRepairPlan(ctx *repoctx.Context, cfg *config.Config, originalSummary string, failureContext string, capabilities []config.RepairCapability) (*plan.Plan, error) {
+// history lists earlier attempts of this run so the model does not repeat a fix that did not work.
+func (c *Client) GenerateRepairPlan(ctx *repoctx.Context, cfg *config.Config, originalSummary string, failureContext string, capabilities []config.RepairCapability, history []repair.Attempt) (*plan.Plan, error) {
 	if strings.TrimSpace(c.APIKey) == "" {
 		return nil, fmt.Errorf("missing GEMINI_API_KEY")
 	}
 	slog.Info("gemini repair generation started", "model", c.Model, "max_attempts", c.MaxAttempts)
 
-	prompt := buildRepairPrompt(ctx, cfg, originalSummary, failureContext, capabilities)
+	prompt := buildRepairPrompt(ctx, cfg, originalSummary, failureContext, capabilities, history)
 	var lastErr error
 
 	for attempt := 1; attempt <= c.MaxAttempts; attempt++ {
@@ -124,6 +174,105 @@ func (c *Client) GenerateRepairPlan(ctx *repoctx.Context, cfg *config.Config, or
 	return nil, fmt.Errorf("failed to generate repair plan")
 }
 
+// FixPlan asks Gemini to correct a plan whose Go files failed the pre-write
+// syntax/type check. The returned plan replaces p as a whole.
+func (c *Client) FixPlan(cfg *config.Config, p *plan.Plan, diagnostics []string) (*plan.Plan, error) {
+	if strings.TrimSpace(c.APIKey) == "" {
+		return nil, fmt.Errorf("missing GEMINI_API_KEY")
+	}
+	slog.Info("gemini plan fixup started", "model", c.Model, "diagnostics", len(diagnostics))
+
+	prompt := buildDiagnosticsFixupPrompt(cfg, p, diagnostics)
+	var lastErr error
+	for attempt := 1; attempt <= c.MaxAttempts; attempt++ {
+		attemptStartedAt := time.Now()
+		text, err := c.generateContent(prompt)
+		if err == nil {
+			var fixed *plan.Plan
+			if fixed, err = parsePlan(text); err == nil {
+				slog.Info("gemini plan fixup succeeded", "attempt", attempt, "duration_ms", time.Since(attemptStartedAt).Milliseconds())
+				return fixed, nil
+			}
+		}
+		slog.Warn("gemini plan fixup attempt failed", "attempt", attempt, "max_attempts", c.MaxAttempts, "duration_ms", time.Since(attemptStartedAt).Milliseconds(), "error", err)
+		lastErr = err
+		if attempt < c.MaxAttempts {
+			c.waitBeforeRetry(attempt)
+		}
+	}
+	return nil, lastErr
+}
+
+// ReviewComment is one piece of review feedback to address. ID is what the
+// plan's replies refer to.
+type ReviewComment struct {
+	ID     string `json:"id"`
+	Author string `json:"author"`
+	Body   string `json:"body"`
+	// Path and Line locate line comments; Excerpt shows the lines around Line
+	// in the current file and DiffHunk what the reviewer saw.
+	Path     string `json:"path,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Excerpt  string `json:"excerpt,omitempty"`
+	DiffHunk string `json:"diff_hunk,omitempty"`
+}
+
+// GenerateReviewPlan asks Gemini for a plan that addresses review comments on
+// a pull request, with a reply for each comment.
+func (c *Client) GenerateReviewPlan(ctx *repoctx.Context, cfg *config.Config, prTitle string, comments []ReviewComment) (*plan.Plan, error) {
+	if strings.TrimSpace(c.APIKey) == "" {
+		return nil, fmt.Errorf("missing GEMINI_API_KEY")
+	}
+	slog.Info("gemini review plan generation started", "model", c.Model, "comments", len(comments))
+
+	prompt := buildReviewPrompt(ctx, cfg, prTitle, comments)
+	var lastErr error
+	for attempt := 1; attempt <= c.MaxAttempts; attempt++ {
+		attemptStartedAt := time.Now()
+		text, err := c.generateContent(prompt)
+		if err == nil {
+			var p *plan.Plan
+			if p, err = parsePlan(text); err == nil {
+				slog.Info("gemini review plan generation succeeded", "attempt", attempt, "duration_ms", time.Since(attemptStartedAt).Milliseconds())
+				return p, nil
+			}
+		}
+		slog.Warn("gemini review plan attempt failed", "attempt", attempt, "max_attempts", c.MaxAttempts, "duration_ms", time.Since(attemptStartedAt).Milliseconds(), "error", err)
+		lastErr = err
+		if attempt < c.MaxAttempts {
+			c.waitBeforeRetry(attempt)
+		}
+	}
+	return nil, lastErr
+}
+
+// ExplainChanges asks Gemini to explain a pull request's diff to reviewers
+// and returns the explanation as markdown.
+func (c *Client) ExplainChanges(prTitle, prBody, diff string) (string, error) {
+	if strings.TrimSpace(c.APIKey) == "" {
+		return "", fmt.Errorf("missing GEMINI_API_KEY")
+	}
+	prompt := buildExplainPrompt(prTitle, prBody, diff)
+	var lastErr error
+	for attempt := 1; attempt <= c.MaxAttempts; attempt++ {
+		text, err := c.generateContent(prompt)
+		if err == nil {
+			var p *plan.Plan
+			if p, err = parsePlan(text); err == nil && strings.TrimSpace(p.Summary) != "" {
+				return strings.TrimSpace(p.Summary), nil
+			} else if err == nil {
+				err = fmt.Errorf("empty explanation")
+			}
+		}
+		slog.Warn("gemini explanation attempt failed", "attempt", attempt, "max_attempts", c.MaxAttempts, "error", err)
+		lastErr = err
+		if attempt < c.MaxAttempts {
+			c.waitBeforeRetry(attempt)
+		}
+	}
+	return "", lastErr
+}
+
 func (c *Client) waitBeforeRetry(attempt int) {
 	if c.RetryBaseDelay <= 0 {
 		return
@@ -132,11 +281,18 @@ func (c *Client) waitBeforeRetry(attempt int) {
 }
 
 func (c *Client) generateContent(prompt string) (string, error) {
+	genCfg := map[string]any{
+		"responseMimeType": "application/json",
+	}
+	if c.Temperature != nil {
+		genCfg["temperature"] = *c.Temperature
+	}
+	if c.Seed != nil {
+		genCfg["seed"] = *c.Seed
+	}
 	reqBody := map[string]any{
-		"contents": []map[string]any{{"parts": []map[string]any{{"text": prompt}}}},
-		"generationConfig": map[string]any{
-			"responseMimeType": "application/json",
-		},
+		"contents":         []map[string]any{{"parts": []map[string]any{{"text": prompt}}}},
+		"generationConfig": genCfg,
 	}
 
 	b, err := json.Marshal(reqBody)
@@ -170,11 +326,18 @@ func (c *Client) generateContent(prompt string) (string, error) {
 				} `json:"parts"`
 			} `json:"content"`
 		} `json:"candidates"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+			TotalTokenCount      int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
 	}
 
 	if err := json.Unmarshal(body, &res); err != nil {
 		return "", fmt.Errorf("gemini decode failed: %v", err)
 	}
+	u := res.UsageMetadata
+	c.Usage.add(u.PromptTokenCount, u.CandidatesTokenCount, u.TotalTokenCount)
 	if len(res.Candidates) == 0 || len(res.Candidates[0].Content.Parts) == 0 {
 		return "", fmt.Errorf("empty response from gemini")
 	}
@@ -221,12 +384,12 @@ func buildPrompt(ctx *repoctx.Context, cfg *config.Config) string {
 Hard rules:
 - Make small, incremental, reviewable changes.
 - Stay under %d files changed, %d lines changed, %d new files.
-- Workflow edits: %t.
+- Workflow edits: %t.%s
 - Output ONLY valid JSON matching this exact schema (no markdown, no commentary):
-{"summary": "...", "files": [{"path": "...", "mode": "write", "content": "..."}], "changelog_entry": "- ...", "roadmap_update": "..."}
+{"summary": "...", "files": [{"path": "...", "mode": "write", "content": "..."}], "changelog_entry": "...", "changelog_category": "Added|Changed|Deprecated|Removed|Fixed|Security", "roadmap_item": "R1", "roadmap_ops": [{"op": "check", "id": "R1"}]}
 
 Repository context (JSON):
-%s`, cfg.Budgets.MaxFilesChanged, cfg.Budgets.MaxLinesChanged, cfg.Budgets.MaxNewFiles, cfg.Security.AllowWorkflowEdits, string(d))
+%s`, cfg.Budgets.MaxFilesChanged, cfg.Budgets.MaxLinesChanged, cfg.Budgets.MaxNewFiles, cfg.Security.AllowWorkflowEdits, budgetRules(cfg)+protectionRules(ctx, cfg)+apiCompatRules(cfg)+dependencyRules(cfg)+policyRules(ctx)+objectiveRules(ctx)+changelogRules(), string(d))
 }
 
 func buildFixupPrompt(ctx *repoctx.Context, cfg *config.Config, lastText string, parseErr error) string {
@@ -236,16 +399,22 @@ Error:
 %s
 
 Return ONLY valid JSON matching this exact schema (no fences, no commentary):
-{"summary": "...", "files": [{"path": "...", "mode": "write", "content": "..."}], "changelog_entry": "- ...", "roadmap_update": "..."}
+{"summary": "...", "files": [{"path": "...", "mode": "write", "content": "..."}], "changelog_entry": "...", "changelog_category": "Added|Changed|Deprecated|Removed|Fixed|Security", "roadmap_item": "R1", "roadmap_ops": [{"op": "check", "id": "R1"}]}
 
 Here is your previous response for correction:
 %s`, parseErr.Error(), strings.TrimSpace(lastText))
 }
 
-func buildRepairPrompt(ctx *repoctx.Context, cfg *config.Config, originalSummary string, failureContext string, capabilities []config.RepairCapability) string {
+func buildRepairPrompt(ctx *repoctx.Context, cfg *config.Config, originalSummary string, failureContext string, capabilities []config.RepairCapability, history []repair.Attempt) string {
 	d, _ := json.Marshal(ctx)
 	capsJSON, _ := json.Marshal(summarizeCapabilities(capabilities))
 
+	historyText := "None; this is the first repair attempt."
+	if len(history) > 0 {
+		h, _ := json.Marshal(history)
+		historyText = string(h) + "\nThese attempts did not fix verification. Do NOT repeat a fix whose output_diff shows no progress; try a different approach."
+	}
+
 	return fmt.Sprintf(`You are repairing a repository change that failed verification.
 
 Goal:
@@ -256,7 +425,7 @@ Goal:
 - Do NOT change verification commands.
 - You may optionally request project-allowed repair actions by ID from the provided list.
 - Only use repair_actions when they directly address the failure.
-- Keep changelog_entry and roadmap_update empty unless absolutely necessary.
+- Keep changelog_entry empty and omit roadmap_ops unless absolutely necessary.
 
 Original change summary:
 %s
@@ -264,19 +433,23 @@ Original change summary:
 Verification failure context:
 %s
 
+Previous repair attempts in this run (JSON):
+%s
+
 Available repair capabilities (JSON):
 %s
 
 Hard rules:
 - Stay under %d files changed, %d lines changed, %d new files (cumulative budget still applies).
-- Workflow edits: %t.
+- Workflow edits: %t.%s
 - Output ONLY valid JSON matching this exact schema (no markdown, no commentary):
-{"summary": "...", "files": [{"path": "...", "mode": "write", "content": "..."}], "changelog_entry": "", "roadmap_update": "", "repair_actions": ["capability_id"]}
+{"summary": "...", "files": [{"path": "...", "mode": "write", "content": "..."}], "changelog_entry": "", "repair_actions": [{"id": "capability_id", "args": {"param": "value"}}]}
 - repair_actions must contain only IDs from the provided capability list.
+- args may only name that capability's params, and each value must fully match the param pattern; omit args for capabilities without params.
 - If no repair action is needed, return repair_actions as [] or omit it.
 
 Repository context (JSON):
-%s`, strings.TrimSpace(originalSummary), strings.TrimSpace(failureContext), string(capsJSON), cfg.Budgets.MaxFilesChanged, cfg.Budgets.MaxLinesChanged, cfg.Budgets.MaxNewFiles, cfg.Security.AllowWorkflowEdits, string(d))
+%s`, strings.TrimSpace(originalSummary), strings.TrimSpace(failureContext), historyText, string(capsJSON), cfg.Budgets.MaxFilesChanged, cfg.Budgets.MaxLinesChanged, cfg.Budgets.MaxNewFiles, cfg.Security.AllowWorkflowEdits, budgetRules(cfg)+protectionRules(ctx, cfg)+apiCompatRules(cfg)+dependencyRules(cfg)+policyRules(ctx), string(d))
 }
 
 func buildRepairFixupPrompt(cfg *config.Config, failureContext string, capabilities []config.RepairCapability, lastText string, parseErr error) string {
@@ -293,12 +466,160 @@ Available repair capabilities (JSON):
 %s
 
 Return ONLY valid JSON matching this exact schema (no fences, no commentary):
-{"summary": "...", "files": [{"path": "...", "mode": "write", "content": "..."}], "changelog_entry": "", "roadmap_update": "", "repair_actions": ["capability_id"]}
+{"summary": "...", "files": [{"path": "...", "mode": "write", "content": "..."}], "changelog_entry": "", "repair_actions": [{"id": "capability_id", "args": {"param": "value"}}]}
 
 Previous invalid response:
 %s`, parseErr.Error(), strings.TrimSpace(failureContext), string(capsJSON), strings.TrimSpace(lastText))
 }
 
+func buildDiagnosticsFixupPrompt(cfg *config.Config, p *plan.Plan, diagnostics []string) string {
+	planJSON, _ := json.Marshal(p)
+	return fmt.Sprintf(`Your plan was not applied: its Go files do not compile.
+
+Diagnostics (path:line:column: message):
+%s
+
+Fix only these problems. Keep the intent, the other files, the changelog entry, the roadmap item and ops and any repair actions unchanged.
+Stay under %d files changed, %d lines changed, %d new files.
+
+Return ONLY valid JSON with the same schema as the plan below (no fences, no commentary).
+
+Plan:
+%s`, strings.Join(diagnostics, "\n"), cfg.Budgets.MaxFilesChanged, cfg.Budgets.MaxLinesChanged, cfg.Budgets.MaxNewFiles, string(planJSON))
+}
+
+func buildReviewPrompt(ctx *repoctx.Context, cfg *config.Config, prTitle string, comments []ReviewComment) string {
+	d, _ := json.Marshal(ctx)
+	commentsJSON, _ := json.Marshal(comments)
+	return fmt.Sprintf(`You are addressing reviewer feedback on a pull request you opened.
+
+Pull request: %s
+
+Review comments (JSON):
+%s
+
+Hard rules:
+- Change only what the comments ask for; if a comment is a question or you disagree, answer it and leave the code as is.
+- Stay under %d files changed, %d lines changed, %d new files.
+- Workflow edits: %t.%s
+- Give exactly one reply per comment id, saying briefly what you changed or why not.
+- Output ONLY valid JSON matching this exact schema (no markdown, no commentary):
+{"summary": "...", "files": [{"path": "...", "mode": "write", "content": "..."}], "changelog_entry": "", "replies": [{"comment_id": "...", "body": "..."}]}
+
+Repository context (JSON):
+%s`, strings.TrimSpace(prTitle), string(commentsJSON), cfg.Budgets.MaxFilesChanged, cfg.Budgets.MaxLinesChanged, cfg.Budgets.MaxNewFiles, cfg.Security.AllowWorkflowEdits, budgetRules(cfg)+protectionRules(ctx, cfg)+apiCompatRules(cfg)+dependencyRules(cfg)+policyRules(ctx), string(d))
+}
+
+func buildExplainPrompt(prTitle, prBody, diff string) string {
+	const maxDiff = 60000
+	if len(diff) > maxDiff {
+		diff = diff[:maxDiff] + "\n...<truncated>..."
+	}
+	return fmt.Sprintf(`You opened the pull request below. A reviewer asked you to explain it.
+
+Explain what the change does and why, file by file where it helps, and call out anything risky or worth a careful look. Be concise and factual; do not claim anything the diff does not show.
+
+Output ONLY valid JSON matching this exact schema (no markdown fences, no commentary), with the explanation as markdown in "summary":
+{"summary": "..."}
+
+Title: %s
+
+Description:
+%s
+
+Diff:
+%s`, strings.TrimSpace(prTitle), strings.TrimSpace(prBody), diff)
+}
+
+// budgetRules returns extra hard-rule lines for optional and path-scoped budgets.
+func budgetRules(cfg *config.Config) string {
+	b := cfg.Budgets
+	var rules string
+	if b.MaxAddedLines > 0 || b.MaxDeletedLines > 0 {
+		rules += fmt.Sprintf("\n- Added lines limit: %d, deleted lines limit: %d (0 means none).", b.MaxAddedLines, b.MaxDeletedLines)
+	}
+	if b.Tests.MaxLines > 0 {
+		rules += fmt.Sprintf("\n- Test code may change at most %d lines.", b.Tests.MaxLines)
+	}
+	if b.Code.MaxLines > 0 {
+		rules += fmt.Sprintf("\n- Non-test code may change at most %d lines.", b.Code.MaxLines)
+	}
+	for _, p := range b.Paths {
+		switch {
+		case p.Unlimited:
+			rules += fmt.Sprintf("\n- Files matching %s do not count toward any budget.", p.Glob)
+		case p.MaxLines > 0:
+			rules += fmt.Sprintf("\n- Files matching %s may change at most %d lines.", p.Glob, p.MaxLines)
+		}
+	}
+	return rules
+}
+
+// protectionRules returns an extra hard-rule line when the context has protected files.
+func protectionRules(ctx *repoctx.Context, cfg *config.Config) string {
+	if len(ctx.Protected) == 0 {
+		return ""
+	}
+	if plan.ProtectedEditsAllowed(cfg) {
+		return "\n- Avoid editing files listed in Protected; any edit to them needs explicit human review."
+	}
+	return "\n- Never edit files listed in Protected in the repository context; such plans are rejected."
+}
+
+// objectiveRules names the run's objective: the issue in the context, or
+// else the roadmap target item, which the plan must reference.
+func objectiveRules(ctx *repoctx.Context) string {
+	rules := "\n- Change ROADMAP.md only through roadmap_ops: check (id), add (text, section) or move (id, section), with sections now, next or later. Never write ROADMAP.md in files."
+	switch {
+	case ctx.Task != nil:
+		return rules + fmt.Sprintf("\n- Objective: resolve issue #%d (Task in the repository context). Work only toward it; ROADMAP.md is background, not the objective.", ctx.Task.Issue)
+	case ctx.Target != nil:
+		return rules + fmt.Sprintf("\n- Objective: roadmap item %s (%q, Target in the repository context). Work only toward it, set roadmap_item to %q, and check it in roadmap_ops once the change completes it.", ctx.Target.ID, ctx.Target.Text, ctx.Target.ID)
+	}
+	return rules
+}
+
+// changelogRules describes the changelog entry evolver files under Unreleased.
+func changelogRules() string {
+	return fmt.Sprintf("\n- changelog_entry is one line saying what changed, with no bullet, date or heading; evolver adds those. changelog_category is one of %s.", strings.Join(changelog.Categories, ", "))
+}
+
+// policyRules returns an extra hard-rule line when .evolver/policy.yml has rules.
+func policyRules(ctx *repoctx.Context) string {
+	if ctx.Rules == nil || ctx.Rules.Empty() {
+		return ""
+	}
+	return "\n- Follow Rules in the repository context; the diff is checked against them and violations fail verification."
+}
+
+// dependencyRules returns extra hard-rule lines for a configured dependency policy.
+func dependencyRules(cfg *config.Config) string {
+	dc := cfg.Dependencies
+	var rules string
+	if dc.ForbidNew {
+		rules += "\n- Do not add new dependencies to go.mod, package.json or requirements.txt."
+	}
+	if len(dc.Deny) > 0 {
+		rules += "\n- Never add or upgrade these dependencies: " + strings.Join(dc.Deny, ", ")
+	}
+	if len(dc.Allow) > 0 {
+		rules += "\n- Only these dependencies may be added or upgraded: " + strings.Join(dc.Allow, ", ")
+	}
+	return rules
+}
+
+// apiCompatRules returns extra hard-rule lines when the API compatibility gate is on.
+func apiCompatRules(cfg *config.Config) string {
+	if !cfg.APICompat.Enabled {
+		return ""
+	}
+	rules := "\n- Do not remove or change exported identifiers of non-internal Go packages; add new ones instead."
+	if cfg.APICompat.AllowDeclaredBreaks {
+		rules += "\n- If a breaking API change is truly intended, list each affected symbol as \"pkg/dir.Symbol\" in an extra \"breaking_changes\" array."
+	}
+	return rules
+}
+
 func summarizeCapabilities(caps []config.RepairCapability) []map[string]any {
 	out := make([]map[string]any, 0, len(caps))
 	for _, c := range caps {
@@ -309,6 +630,18 @@ func summarizeCapabilities(caps []config.RepairCapability) []map[string]any {
 		if len(c.AllowedFailureKinds) > 0 {
 			m["allowed_failure_kinds"] = c.AllowedFailureKinds
 		}
+		if len(c.Params) > 0 {
+			params := make([]map[string]any, 0, len(c.Params))
+			for _, p := range c.Params {
+				params = append(params, map[string]any{
+					"name":        p.Name,
+					"description": p.Description,
+					"pattern":     p.Pattern,
+					"required":    p.Required,
+				})
+			}
+			m["params"] = params
+		}
 		out = append(out, m)
 	}
 	return out
@@ -10,7 +10,10 @@ import (
 	"testing"
 
 	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/plan"
+	"github.com/mmrzaf/evolver/internal/repair"
 	"github.com/mmrzaf/evolver/internal/repoctx"
+	"github.com/mmrzaf/evolver/internal/roadmap"
 )
 
 type rewriteTLSTransport struct {
@@ -54,6 +57,70 @@ func TestBuildPromptIncludesBudgetsAndContext(t *testing.T) {
 	if !strings.Contains(prompt, "Workflow edits: false.") {
 		t.Fatalf("expected workflow flag in prompt")
 	}
+
+	cfg.Budgets.Paths = []config.PathBudget{{Glob: "docs/**", Unlimited: true}, {Glob: "internal/core/**", BudgetLimits: config.BudgetLimits{MaxLines: 50}}}
+	prompt = buildPrompt(ctx, cfg)
+	if !strings.Contains(prompt, "Files matching docs/** do not count toward any budget.") || !strings.Contains(prompt, "Files matching internal/core/** may change at most 50 lines.") {
+		t.Fatalf("expected path budgets in prompt, got %q", prompt)
+	}
+}
+
+func TestBuildRepairPromptIncludesHistory(t *testing.T) {
+	cfg := &config.Config{Budgets: config.Budgets{MaxFilesChanged: 1, MaxLinesChanged: 10, MaxNewFiles: 1}}
+
+	prompt := buildRepairPrompt(&repoctx.Context{}, cfg, "orig", "Kind: compile_failure", nil, nil)
+	if !strings.Contains(prompt, "None; this is the first repair attempt.") {
+		t.Fatalf("expected empty history note, got %q", prompt)
+	}
+
+	history := []repair.Attempt{{Number: 1, Summary: "add Foo", Files: []string{"a.go"}, ResultKind: "compile_failure", OutputDiff: "no change in failure output"}}
+	prompt = buildRepairPrompt(&repoctx.Context{}, cfg, "orig", "Kind: compile_failure", nil, history)
+	for _, want := range []string{`"summary":"add Foo"`, `"files_touched":["a.go"]`, `"output_diff":"no change in failure output"`, "Do NOT repeat a fix"} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("expected %q in repair prompt", want)
+		}
+	}
+}
+
+func TestBuildDiagnosticsFixupPromptIncludesPlanAndDiagnostics(t *testing.T) {
+	p := &plan.Plan{Summary: "add helper", Files: []plan.File{{Path: "a.go", Mode: "write", Content: "package a\nfunc F( {"}}}
+	prompt := buildDiagnosticsFixupPrompt(&config.Config{}, p, []string{"a.go:2:8: expected ')', found '{'"})
+	for _, want := range []string{"a.go:2:8: expected ')'", `"summary":"add helper"`, `"path":"a.go"`} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("expected prompt to contain %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestBuildPromptMakesIssueTheObjective(t *testing.T) {
+	cfg := &config.Config{Budgets: config.Budgets{MaxFilesChanged: 1, MaxLinesChanged: 1, MaxNewFiles: 1}}
+	if strings.Contains(buildPrompt(&repoctx.Context{}, cfg), "Objective:") {
+		t.Fatalf("expected no objective rule without a task")
+	}
+	prompt := buildPrompt(&repoctx.Context{Task: &repoctx.Task{Issue: 12, Title: "Add retries"}}, cfg)
+	if !strings.Contains(prompt, "Objective: resolve issue #12") || !strings.Contains(prompt, `"Title":"Add retries"`) {
+		t.Fatalf("expected issue objective in prompt, got %q", prompt)
+	}
+}
+
+func TestBuildPromptRequiresRoadmapTarget(t *testing.T) {
+	cfg := &config.Config{Budgets: config.Budgets{MaxFilesChanged: 1, MaxLinesChanged: 1, MaxNewFiles: 1}}
+	ctx := &repoctx.Context{Target: &roadmap.Item{ID: "R3", Text: "Add retries", Section: "Now"}}
+	prompt := buildPrompt(ctx, cfg)
+	if !strings.Contains(prompt, `Objective: roadmap item R3 ("Add retries"`) || !strings.Contains(prompt, `set roadmap_item to "R3"`) || !strings.Contains(prompt, `"roadmap_ops": [{"op": "check"`) {
+		t.Fatalf("expected roadmap objective in prompt, got %q", prompt)
+	}
+}
+
+func TestBuildReviewPromptIncludesCommentsAndRepliesSchema(t *testing.T) {
+	cfg := &config.Config{Budgets: config.Budgets{MaxFilesChanged: 2, MaxLinesChanged: 40, MaxNewFiles: 0}}
+	comments := []ReviewComment{{ID: "rc-11", Author: "alice", Body: "rename x", Path: "a.go", Line: 4, Excerpt: "4: x := 1"}}
+	prompt := buildReviewPrompt(&repoctx.Context{}, cfg, "Add parser", comments)
+	for _, want := range []string{"Pull request: Add parser", `"id":"rc-11"`, `"path":"a.go"`, `"replies": [{"comment_id"`, "Stay under 2 files changed, 40 lines changed, 0 new files."} {
+		if !strings.Contains(prompt, want) {
+			t.Fatalf("expected %q in prompt, got %q", want, prompt)
+		}
+	}
 }
 
 func TestGeneratePlanSuccess(t *testing.T) {
@@ -63,7 +130,7 @@ func TestGeneratePlanSuccess(t *testing.T) {
 				{
 					"content": map[string]any{
 						"parts": []map[string]string{
-							{"text": `{"summary":"safe change","files":[{"path":"a.txt","mode":"write","content":"ok"}],"changelog_entry":"- safe","roadmap_update":"done"}`},
+							{"text": `{"summary":"safe change","files":[{"path":"a.txt","mode":"write","content":"ok"}],"changelog_entry":"- safe","roadmap_ops":[{"op":"check","id":"R1"}]}`},
 						},
 					},
 				},
@@ -89,6 +156,40 @@ func TestGeneratePlanSuccess(t *testing.T) {
 	}
 }
 
+func TestWithSamplingSendsTemperatureAndSeed(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			GenerationConfig map[string]any `json:"generationConfig"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		got = req.GenerationConfig
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"candidates":    []map[string]any{{"content": map[string]any{"parts": []map[string]string{{"text": `{"summary":"x","files":[]}`}}}}},
+			"usageMetadata": map[string]int{"promptTokenCount": 120, "candidatesTokenCount": 30, "totalTokenCount": 150},
+		})
+	}))
+	defer srv.Close()
+
+	base := NewClient("k", "model")
+	base.RetryBaseDelay = 0
+	redirectClientToServer(t, base, srv)
+	c := base.WithSampling(0.7, 3)
+	if base.Temperature != nil || base.Seed != nil {
+		t.Fatalf("WithSampling must not modify the original client")
+	}
+
+	if _, err := c.GeneratePlan(&repoctx.Context{}, &config.Config{}); err != nil {
+		t.Fatalf("generate plan: %v", err)
+	}
+	if got["temperature"] != 0.7 || got["seed"] != float64(3) {
+		t.Fatalf("unexpected generation config: %v", got)
+	}
+	if calls, prompt, output, total := base.Usage.Snapshot(); calls != 1 || prompt != 120 || output != 30 || total != 150 {
+		t.Fatalf("expected sampled client to count usage on the shared Usage, got %d %d %d %d", calls, prompt, output, total)
+	}
+}
+
 func TestGeneratePlanEmptyResponse(t *testing.T) {
 	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_ = json.NewEncoder(w).Encode(map[string]any{"candidates": []any{}})
@@ -106,7 +207,7 @@ func TestGeneratePlanEmptyResponse(t *testing.T) {
 }
 
 func TestParsePlanStripsFences(t *testing.T) {
-	p, err := parsePlan("```json\n{\"summary\":\"x\",\"files\":[],\"changelog_entry\":\"- x\",\"roadmap_update\":\"\"}\n```")
+	p, err := parsePlan("```json\n{\"summary\":\"x\",\"files\":[],\"changelog_entry\":\"- x\"}\n```")
 	if err != nil {
 		t.Fatalf("parse: %v", err)
 	}
@@ -128,7 +229,7 @@ func TestGeneratePlanRetriesHTTPFailure(t *testing.T) {
 				{
 					"content": map[string]any{
 						"parts": []map[string]string{
-							{"text": `{"summary":"retry ok","files":[],"changelog_entry":"- retry","roadmap_update":""}`},
+							{"text": `{"summary":"retry ok","files":[],"changelog_entry":"- retry"}`},
 						},
 					},
 				},
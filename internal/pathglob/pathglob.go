@@ -0,0 +1,117 @@
+// Package pathglob matches slash-separated repository paths against
+// gitignore-style patterns.
+//
+// A pattern without a slash (other than a trailing one) matches a file or
+// directory name at any depth. A pattern with a slash is anchored at the
+// repository root. "*" and "?" never cross a slash, "**" matches any number
+// of directories, and a trailing slash matches everything below a directory.
+package pathglob
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	mu    sync.Mutex
+	cache = map[string]*regexp.Regexp{}
+)
+
+// Match reports whether path matches pattern. Invalid patterns match nothing.
+func Match(pattern, path string) bool {
+	re := compile(pattern)
+	if re == nil {
+		return false
+	}
+	path = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "./")
+	return re.MatchString(path)
+}
+
+// MatchAny reports whether path matches at least one of patterns.
+func MatchAny(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if Match(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the paths that match pattern, preserving order.
+func Filter(pattern string, paths []string) []string {
+	var out []string
+	for _, p := range paths {
+		if Match(pattern, p) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func compile(pattern string) *regexp.Regexp {
+	mu.Lock()
+	defer mu.Unlock()
+	if re, ok := cache[pattern]; ok {
+		return re
+	}
+	re, err := regexp.Compile(translate(pattern))
+	if err != nil {
+		re = nil
+	}
+	cache[pattern] = re
+	return re
+}
+
+func translate(pattern string) string {
+	p := filepath.ToSlash(strings.TrimSpace(pattern))
+	p = strings.TrimPrefix(p, "./")
+	dir := strings.HasSuffix(p, "/")
+	p = strings.TrimSuffix(p, "/")
+	anchored := strings.HasPrefix(p, "/") || strings.Contains(p, "/")
+	p = strings.TrimPrefix(p, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '*' && strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// A name or directory pattern also covers everything below it.
+	if dir {
+		b.WriteString("/.*")
+	} else {
+		b.WriteString("(?:/.*)?")
+	}
+	b.WriteString("$")
+	return b.String()
+}
@@ -0,0 +1,47 @@
+package pathglob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "internal/apply/apply.go", true},
+		{"*.go", "main.go.orig", false},
+		{"docs/**", "docs/guide/intro.md", true},
+		{"docs/**", "src/docs/x.md", false},
+		{"internal/core/**", "internal/core/a/b.go", true},
+		{"internal/core/**", "internal/corex/a.go", false},
+		{"**/testdata/**", "pkg/x/testdata/in.txt", true},
+		{"**/*_test.go", "a_test.go", true},
+		{"vendor/", "vendor/mod/a.go", true},
+		{"vendor/", "vendor", false},
+		{"/README.md", "README.md", true},
+		{"/README.md", "docs/README.md", false},
+		{"README.md", "docs/README.md", true},
+		{"cmd/*.go", "cmd/main.go", true},
+		{"cmd/*.go", "cmd/evolver/main.go", false},
+		{"file?.txt", "file1.txt", true},
+		{"[ab].txt", "b.txt", true},
+		{"[!ab].txt", "b.txt", false},
+		{"node_modules", "web/node_modules/x/index.js", true},
+		{"*.ts", "./src/app.ts", true},
+	}
+	for _, c := range cases {
+		if got := Match(c.pattern, c.path); got != c.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestFilterAndMatchAny(t *testing.T) {
+	paths := []string{"a.go", "b.ts", "docs/c.md"}
+	if got := Filter("*.go", paths); len(got) != 1 || got[0] != "a.go" {
+		t.Fatalf("unexpected filter result: %v", got)
+	}
+	if !MatchAny([]string{"*.md", "*.ts"}, "b.ts") || MatchAny([]string{"*.md"}, "a.go") {
+		t.Fatalf("unexpected MatchAny result")
+	}
+}
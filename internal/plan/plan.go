@@ -1,21 +1,163 @@
 package plan
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/protect"
+	"github.com/mmrzaf/evolver/internal/roadmap"
 )
 
 // Plan is the structured output describing repository updates.
 type Plan struct {
-	Summary        string   `json:"summary"`
-	Files          []File   `json:"files"`
-	ChangelogEntry string   `json:"changelog_entry"`
-	RoadmapUpdate  string   `json:"roadmap_update"`
-	RepairActions  []string `json:"repair_actions,omitempty"`
+	Summary        string `json:"summary"`
+	Files          []File `json:"files"`
+	ChangelogEntry string `json:"changelog_entry"`
+	// ChangelogCategory is the Keep a Changelog section for ChangelogEntry;
+	// empty means Changed.
+	ChangelogCategory string `json:"changelog_category,omitempty"`
+	// RoadmapItem is the ID of the roadmap item the plan works toward, and
+	// RoadmapOps the structured updates to ROADMAP.md.
+	RoadmapItem   string         `json:"roadmap_item,omitempty"`
+	RoadmapOps    []roadmap.Op   `json:"roadmap_ops,omitempty"`
+	RepairActions []RepairAction `json:"repair_actions,omitempty"`
+	// BreakingChanges declares intentional exported API breaks as
+	// "pkg/dir.Symbol" ("Symbol" for the root package).
+	BreakingChanges []string `json:"breaking_changes,omitempty"`
+	// Replies answer review comments when the plan addresses a review.
+	Replies []Reply `json:"replies,omitempty"`
+}
+
+// Reply is the answer to one review comment, by the comment's prompt ID.
+type Reply struct {
+	CommentID string `json:"comment_id"`
+	Body      string `json:"body"`
+}
+
+// RepairAction asks to run an allowlisted repair capability, optionally with
+// arguments for the parameters the capability declares.
+type RepairAction struct {
+	ID   string            `json:"id"`
+	Args map[string]string `json:"args,omitempty"`
+}
+
+// UnmarshalJSON accepts both a bare capability ID and an {"id", "args"} object.
+func (a *RepairAction) UnmarshalJSON(b []byte) error {
+	var id string
+	if err := json.Unmarshal(b, &id); err == nil {
+		*a = RepairAction{ID: id}
+		return nil
+	}
+	type plain RepairAction
+	var p plain
+	if err := json.Unmarshal(b, &p); err != nil {
+		return fmt.Errorf("repair action must be a capability id or {\"id\", \"args\"} object: %w", err)
+	}
+	*a = RepairAction(p)
+	return nil
+}
+
+// String renders the action as id or id(name=value, ...) with sorted names.
+func (a RepairAction) String() string {
+	if len(a.Args) == 0 {
+		return a.ID
+	}
+	names := make([]string, 0, len(a.Args))
+	for k := range a.Args {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		parts = append(parts, k+"="+a.Args[k])
+	}
+	return a.ID + "(" + strings.Join(parts, ", ") + ")"
+}
+
+// ResolveArgv validates a's arguments against the capability's parameter schema
+// and substitutes them into its argv. Each {name} placeholder is replaced in
+// place, so values never become extra argv elements; elements referencing an
+// omitted optional parameter are dropped.
+func ResolveArgv(a RepairAction, cap config.RepairCapability) ([]string, error) {
+	params := make(map[string]config.CapabilityParam, len(cap.Params))
+	for _, p := range cap.Params {
+		params[p.Name] = p
+	}
+	for name := range a.Args {
+		if _, ok := params[name]; !ok {
+			return nil, fmt.Errorf("unknown argument %q for capability %s", name, cap.ID)
+		}
+	}
+
+	values := make(map[string]string, len(cap.Params))
+	for _, p := range cap.Params {
+		v, ok := a.Args[p.Name]
+		if !ok {
+			if p.Required {
+				return nil, fmt.Errorf("missing required argument %q for capability %s", p.Name, cap.ID)
+			}
+			continue
+		}
+		if err := validateArg(p, v); err != nil {
+			return nil, fmt.Errorf("argument %q for capability %s: %w", p.Name, cap.ID, err)
+		}
+		values[p.Name] = v
+	}
+
+	// Substitute in a single pass so a value containing another parameter's
+	// placeholder is taken literally.
+	var pairs []string
+	for name, v := range values {
+		pairs = append(pairs, "{"+name+"}", v)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	argv := make([]string, 0, len(cap.Argv))
+	for _, el := range cap.Argv {
+		keep := true
+		for _, p := range cap.Params {
+			if _, ok := values[p.Name]; !ok && strings.Contains(el, "{"+p.Name+"}") {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			argv = append(argv, replacer.Replace(el))
+		}
+	}
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("capability %s has empty argv", cap.ID)
+	}
+	return argv, nil
+}
+
+func validateArg(p config.CapabilityParam, v string) error {
+	if v == "" {
+		return fmt.Errorf("empty value")
+	}
+	if strings.HasPrefix(v, "-") {
+		return fmt.Errorf("value %q looks like a flag", v)
+	}
+	if strings.ContainsAny(v, "\x00\n\r") {
+		return fmt.Errorf("value contains control characters")
+	}
+	if strings.TrimSpace(p.Pattern) == "" {
+		return fmt.Errorf("parameter has no pattern in config")
+	}
+	re, err := regexp.Compile("^(?:" + p.Pattern + ")$")
+	if err != nil {
+		return fmt.Errorf("invalid pattern in config: %w", err)
+	}
+	if !re.MatchString(v) {
+		return fmt.Errorf("value %q does not match %s", v, p.Pattern)
+	}
+	return nil
 }
 
 // File describes a single file operation from a plan.
@@ -42,6 +184,10 @@ func ValidatePaths(p *Plan, cfg *config.Config) error {
 			return fmt.Errorf("path %s is not within allow_paths", cleanPath)
 		}
 
+		if reason := protect.FileReason(cfg.ProtectedPaths, cleanPath); reason != "" && !ProtectedEditsAllowed(cfg) {
+			return fmt.Errorf("path %s is protected (%s)", cleanPath, reason)
+		}
+
 		// Apply deny rules (except workflows, handled above).
 		for _, deny := range cfg.DenyPaths {
 			denyClean, derr := normalizeRelPath(deny)
@@ -63,6 +209,13 @@ func ValidatePaths(p *Plan, cfg *config.Config) error {
 	return nil
 }
 
+// ProtectedEditsAllowed reports whether protected files may be edited, which
+// is only in pr mode with protection.allow_in_pr, where the pull request is
+// labeled and sent to CODEOWNERS for review.
+func ProtectedEditsAllowed(cfg *config.Config) bool {
+	return cfg.Mode == "pr" && cfg.Protection.AllowInPR
+}
+
 func isAllowed(path string, allow []string) bool {
 	// Default allow: everything under repo root.
 	if len(allow) == 0 {
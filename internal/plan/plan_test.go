@@ -1,6 +1,9 @@
 package plan
 
 import (
+	"encoding/json"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/mmrzaf/evolver/internal/config"
@@ -62,6 +65,35 @@ func TestValidatePathsRejectsAbsolute(t *testing.T) {
 	}
 }
 
+func TestValidatePathsRejectsProtectedFiles(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	if err := os.WriteFile("keep.sh", []byte("#!/bin/sh\n# evolver:do-not-edit\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cfg := &config.Config{Mode: "push", AllowPaths: []string{"."}, ProtectedPaths: []string{"LICENSE"}}
+	for _, path := range []string{"LICENSE", "keep.sh"} {
+		p := &Plan{Files: []File{{Path: path, Mode: "write", Content: "x"}}}
+		if err := ValidatePaths(p, cfg); err == nil || !strings.Contains(err.Error(), "protected") {
+			t.Fatalf("expected %s to be protected, got %v", path, err)
+		}
+	}
+
+	cfg.Mode = "pr"
+	cfg.Protection.AllowInPR = true
+	if err := ValidatePaths(&Plan{Files: []File{{Path: "LICENSE", Mode: "write", Content: "x"}}}, cfg); err != nil {
+		t.Fatalf("expected protected edit to be allowed in pr mode: %v", err)
+	}
+}
+
 func TestValidatePathsEnforcesAllowPaths(t *testing.T) {
 	cfg := &config.Config{
 		AllowPaths: []string{"docs"},
@@ -92,3 +124,76 @@ func TestValidatePathsAllowsSafeFiles(t *testing.T) {
 		t.Fatalf("expected safe paths to pass validation: %v", err)
 	}
 }
+
+func TestRepairActionsAcceptIDsAndObjects(t *testing.T) {
+	var p Plan
+	raw := `{"summary":"x","files":[],"repair_actions":["go_mod_tidy",{"id":"go_get","args":{"module":"golang.org/x/mod@v0.20.0"}}]}`
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(p.RepairActions) != 2 || p.RepairActions[0].ID != "go_mod_tidy" || p.RepairActions[1].Args["module"] != "golang.org/x/mod@v0.20.0" {
+		t.Fatalf("unexpected repair actions: %+v", p.RepairActions)
+	}
+	if got := p.RepairActions[1].String(); got != "go_get(module=golang.org/x/mod@v0.20.0)" {
+		t.Fatalf("unexpected action string: %q", got)
+	}
+}
+
+func TestResolveArgvValidatesAgainstParams(t *testing.T) {
+	cap := config.RepairCapability{
+		ID:   "run_test",
+		Argv: []string{"go", "test", "-run", "{name}", "{pkg}"},
+		Params: []config.CapabilityParam{
+			{Name: "name", Pattern: `[A-Za-z0-9_|^$]+`, Required: true},
+			{Name: "pkg", Pattern: `\./[a-z0-9/_.]*`},
+		},
+	}
+
+	argv, err := ResolveArgv(RepairAction{ID: "run_test", Args: map[string]string{"name": "^TestFoo$", "pkg": "./internal/foo"}}, cap)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if strings.Join(argv, " ") != "go test -run ^TestFoo$ ./internal/foo" {
+		t.Fatalf("unexpected argv: %q", argv)
+	}
+
+	argv, err = ResolveArgv(RepairAction{ID: "run_test", Args: map[string]string{"name": "TestFoo"}}, cap)
+	if err != nil || strings.Join(argv, " ") != "go test -run TestFoo" {
+		t.Fatalf("expected optional param element to be dropped, got %q (%v)", argv, err)
+	}
+
+	for _, args := range []map[string]string{
+		{},
+		{"name": "-exec=sh"},
+		{"name": "TestFoo; rm"},
+		{"name": "TestFoo", "extra": "x"},
+		{"name": "TestFoo", "pkg": "../outside"},
+	} {
+		if _, err := ResolveArgv(RepairAction{ID: "run_test", Args: args}, cap); err == nil {
+			t.Fatalf("expected args %v to be rejected", args)
+		}
+	}
+}
+
+func TestResolveArgvDoesNotSubstituteInsideValues(t *testing.T) {
+	cap := config.RepairCapability{
+		ID:   "sed",
+		Argv: []string{"tool", "{a}={b}"},
+		Params: []config.CapabilityParam{
+			{Name: "a", Pattern: `[a-z{}]+`, Required: true},
+			{Name: "b", Pattern: `[a-z{}-]+`, Required: true},
+		},
+	}
+	for _, args := range []map[string]string{
+		{"a": "{b}", "b": "x-y"},
+		{"a": "x", "b": "{a}"},
+	} {
+		argv, err := ResolveArgv(RepairAction{ID: "sed", Args: args}, cap)
+		if err != nil {
+			t.Fatalf("resolve %v: %v", args, err)
+		}
+		if want := args["a"] + "=" + args["b"]; argv[1] != want {
+			t.Fatalf("expected values to be substituted literally, got %q want %q", argv[1], want)
+		}
+	}
+}
@@ -3,15 +3,18 @@ package policy
 import (
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/mmrzaf/evolver/internal/changelog"
 	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/roadmap"
 	"gopkg.in/yaml.v3"
 )
 
 const (
 	policyTmpl    = "# POLICY\n- Small incremental changes.\n- Keep repo runnable.\n- Update CHANGELOG.\n- Add tests.\n- No secrets.\n"
-	roadmapTmpl   = "# ROADMAP\n## Current Objective\n%s\n\n## Now/Next/Later\n- [ ] Initial scaffold\n"
-	changelogTmpl = "# CHANGELOG\n"
+	roadmapTmpl   = "# ROADMAP\n## Current Objective\n%s\n\n## Now/Next/Later\n- [ ] R1: Initial scaffold\n"
+	changelogTmpl = "# Changelog\n\nAll notable changes to this project will be documented in this file.\n\n## [Unreleased]\n"
 )
 
 // Bootstrap initializes policy/config/changelog scaffolding files if absent.
@@ -51,25 +54,40 @@ func Bootstrap(cfg *config.Config) error {
 	return nil
 }
 
-// AppendChangelog appends a single entry to CHANGELOG.md.
-func AppendChangelog(entry string) (err error) {
-	if entry == "" {
-		return nil
+// AppendChangelog adds a plan's changelog entry under "## [Unreleased]" in
+// CHANGELOG.md, in its category, as "- YYYY-MM-DD: text (mode: m, commands:
+// c)". An empty entry is a no-op; a malformed one is an error.
+func AppendChangelog(category, entry, mode, commands string) error {
+	e, err := changelog.ParseEntry(category, entry)
+	if err != nil || e.Text == "" {
+		return err
 	}
-	f, err := os.OpenFile("CHANGELOG.md", os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
+	b, err := os.ReadFile("CHANGELOG.md")
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	defer func() {
-		if cerr := f.Close(); err == nil {
-			err = cerr
-		}
-	}()
-	_, err = f.WriteString("\n" + entry + "\n")
-	return err
+	c := changelog.Parse(string(b))
+	c.Add(e.Category, e.Line(time.Now().UTC(), mode, commands))
+	return os.WriteFile("CHANGELOG.md", []byte(c.String()), 0644)
 }
 
-// UpdateRoadmap overwrites ROADMAP.md with the provided content.
-func UpdateRoadmap(content string) error {
-	return os.WriteFile("ROADMAP.md", []byte(content), 0644)
+// ApplyRoadmap applies structured updates to ROADMAP.md and writes back the
+// IDs Parse assigned to unlabeled items, so they are fixed from the first
+// run that commits. The file is only written when every op applies.
+func ApplyRoadmap(ops []roadmap.Op) error {
+	b, err := os.ReadFile("ROADMAP.md")
+	if os.IsNotExist(err) && len(ops) == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r := roadmap.Parse(string(b))
+	if len(ops) == 0 && !r.AssignedIDs() {
+		return nil
+	}
+	if err := r.Apply(ops); err != nil {
+		return err
+	}
+	return os.WriteFile("ROADMAP.md", []byte(r.String()), 0644)
 }
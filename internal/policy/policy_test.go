@@ -5,8 +5,10 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/roadmap"
 )
 
 func TestBootstrapCreatesFilesAndIsIdempotent(t *testing.T) {
@@ -44,7 +46,7 @@ func TestBootstrapCreatesFilesAndIsIdempotent(t *testing.T) {
 	}
 }
 
-func TestAppendChangelogAndUpdateRoadmap(t *testing.T) {
+func TestAppendChangelogAndApplyRoadmap(t *testing.T) {
 	tmp := t.TempDir()
 	wd, err := os.Getwd()
 	if err != nil {
@@ -55,28 +57,47 @@ func TestAppendChangelogAndUpdateRoadmap(t *testing.T) {
 		t.Fatalf("chdir: %v", err)
 	}
 
-	if err := os.WriteFile("CHANGELOG.md", []byte("# CHANGELOG\n"), 0644); err != nil {
+	if err := os.WriteFile("CHANGELOG.md", []byte("# CHANGELOG\n\n## [1.0.0]\n- initial\n"), 0644); err != nil {
 		t.Fatalf("seed changelog: %v", err)
 	}
-	if err := AppendChangelog("- add retries"); err != nil {
+	if err := AppendChangelog("added", "- add retries", "pr", "pass"); err != nil {
 		t.Fatalf("append changelog: %v", err)
 	}
 	body, err := os.ReadFile("CHANGELOG.md")
 	if err != nil {
 		t.Fatalf("read changelog: %v", err)
 	}
-	if !strings.Contains(string(body), "- add retries") {
-		t.Fatalf("expected appended entry in changelog")
+	entry := "- " + time.Now().UTC().Format("2006-01-02") + ": add retries (mode: pr, commands: pass)"
+	if want := "# CHANGELOG\n\n## [Unreleased]\n\n### Added\n" + entry + "\n\n## [1.0.0]\n- initial\n"; string(body) != want {
+		t.Fatalf("unexpected changelog content: %q", string(body))
+	}
+	if err := AppendChangelog("", "one\ntwo", "pr", "pass"); err == nil {
+		t.Fatalf("expected multi-line entry to fail")
 	}
 
-	if err := UpdateRoadmap("# ROADMAP\n- [x] done\n"); err != nil {
-		t.Fatalf("update roadmap: %v", err)
+	if err := os.WriteFile("ROADMAP.md", []byte("# ROADMAP\n## Now\n- [ ] add retries\n"), 0644); err != nil {
+		t.Fatalf("seed roadmap: %v", err)
 	}
-	roadmap, err := os.ReadFile("ROADMAP.md")
+	if err := ApplyRoadmap(nil); err != nil {
+		t.Fatalf("persist roadmap ids: %v", err)
+	}
+	if b, _ := os.ReadFile("ROADMAP.md"); string(b) != "# ROADMAP\n## Now\n- [ ] R1: add retries\n" {
+		t.Fatalf("expected assigned IDs to be written without ops, got %q", string(b))
+	}
+	if err := ApplyRoadmap([]roadmap.Op{{Op: "check", ID: "R1"}, {Op: "add", Text: "retry metrics", Section: "next"}}); err != nil {
+		t.Fatalf("apply roadmap: %v", err)
+	}
+	roadmapBody, err := os.ReadFile("ROADMAP.md")
 	if err != nil {
 		t.Fatalf("read roadmap: %v", err)
 	}
-	if string(roadmap) != "# ROADMAP\n- [x] done\n" {
-		t.Fatalf("unexpected roadmap content: %q", string(roadmap))
+	if want := "# ROADMAP\n## Now\n- [x] R1: add retries\n\n## Next\n- [ ] R2: retry metrics\n"; string(roadmapBody) != want {
+		t.Fatalf("unexpected roadmap content: %q", string(roadmapBody))
+	}
+	if err := ApplyRoadmap([]roadmap.Op{{Op: "check", ID: "R1"}, {Op: "check", ID: "R9"}}); err == nil {
+		t.Fatalf("expected unknown item to fail")
+	}
+	if after, _ := os.ReadFile("ROADMAP.md"); string(after) != string(roadmapBody) {
+		t.Fatalf("expected failed update to leave the roadmap untouched")
 	}
 }
@@ -0,0 +1,330 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mmrzaf/evolver/internal/pathglob"
+	"gopkg.in/yaml.v3"
+)
+
+// RulesFile holds the machine-checked part of the policy; POLICY.md stays
+// free text for the model.
+const RulesFile = ".evolver/policy.yml"
+
+// Rules are policy rules checked against the applied diff. The zero value
+// checks nothing.
+type Rules struct {
+	// RequireTests needs a new or changed _test.go next to every new
+	// non-test Go file.
+	RequireTests bool `yaml:"require_tests" json:"require_tests,omitempty"`
+	// RequireChangelog needs CHANGELOG.md to change (or a changelog entry to
+	// be pending).
+	RequireChangelog bool `yaml:"require_changelog" json:"require_changelog,omitempty"`
+	// ForbidTODO rejects added lines containing TODO, FIXME or XXX.
+	ForbidTODO bool `yaml:"forbid_todo" json:"forbid_todo,omitempty"`
+	// MaxFunctionLines limits Go functions that are new or were within the
+	// limit before; 0 means no limit.
+	MaxFunctionLines int `yaml:"max_function_lines" json:"max_function_lines,omitempty"`
+	// ForbiddenImports are Go import paths no change may add; * matches any
+	// run of characters.
+	ForbiddenImports []string `yaml:"forbidden_imports" json:"forbidden_imports,omitempty"`
+	// Exclude are gitignore-style globs of files the rules skip.
+	Exclude []string `yaml:"exclude" json:"exclude,omitempty"`
+}
+
+// Empty reports whether no rule is enabled.
+func (r Rules) Empty() bool {
+	return !r.RequireTests && !r.RequireChangelog && !r.ForbidTODO && r.MaxFunctionLines <= 0 && len(r.ForbiddenImports) == 0
+}
+
+// LoadRules reads RulesFile. A missing file means no rules.
+func LoadRules() (Rules, error) {
+	b, err := os.ReadFile(RulesFile)
+	if os.IsNotExist(err) {
+		return Rules{}, nil
+	}
+	if err != nil {
+		return Rules{}, err
+	}
+	return ParseRules(b)
+}
+
+// ParseRules parses the content of RulesFile. Unknown keys are an error so
+// a misspelled rule is not silently off.
+func ParseRules(b []byte) (Rules, error) {
+	var r Rules
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+	if err := dec.Decode(&r); err != nil && !errors.Is(err, io.EOF) {
+		return Rules{}, fmt.Errorf("%s: %w", RulesFile, err)
+	}
+	if r.MaxFunctionLines < 0 {
+		return Rules{}, fmt.Errorf("%s: max_function_lines must not be negative", RulesFile)
+	}
+	return r, nil
+}
+
+// FileChange is one file of the applied diff. Before is nil for a new file
+// and After is nil for a deleted one.
+type FileChange struct {
+	Path   string
+	Before []byte
+	After  []byte
+}
+
+// Violation is a rule broken by the diff.
+type Violation struct {
+	Rule string
+	Path string
+	// Line is 1-based, or 0 when the violation is not about a line.
+	Line    int
+	Message string
+}
+
+func (v Violation) String() string {
+	switch {
+	case v.Path == "":
+		return fmt.Sprintf("%s: %s", v.Rule, v.Message)
+	case v.Line == 0:
+		return fmt.Sprintf("%s: %s: %s", v.Path, v.Rule, v.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", v.Path, v.Line, v.Rule, v.Message)
+}
+
+var todoRe = regexp.MustCompile(`\b(TODO|FIXME|XXX)\b`)
+
+// Check evaluates the rules against changes. changelogPending is set when a
+// changelog entry will be written after verification. Files under .evolver/
+// are evolver's own state and are never checked.
+func (r Rules) Check(changes []FileChange, changelogPending bool) []Violation {
+	var out []Violation
+	var files []FileChange
+	changelog := changelogPending
+	for _, c := range changes {
+		c.Path = filepath.ToSlash(filepath.Clean(c.Path))
+		if c.Path == "CHANGELOG.md" && c.After != nil {
+			changelog = true
+		}
+		if !strings.HasPrefix(c.Path, ".evolver/") && !pathglob.MatchAny(r.Exclude, c.Path) {
+			files = append(files, c)
+		}
+	}
+	if r.RequireChangelog && !changelog {
+		out = append(out, Violation{Rule: "require_changelog", Message: "CHANGELOG.md must change; set changelog_entry"})
+	}
+	if r.RequireTests {
+		out = append(out, missingTests(files)...)
+	}
+	for _, c := range files {
+		if c.After == nil {
+			continue
+		}
+		if r.ForbidTODO && !bytes.Contains(c.After, []byte{0}) {
+			out = append(out, addedTODOs(c)...)
+		}
+		if !strings.HasSuffix(c.Path, ".go") {
+			continue
+		}
+		if r.MaxFunctionLines > 0 {
+			out = append(out, longFunctions(c, r.MaxFunctionLines)...)
+		}
+		if len(r.ForbiddenImports) > 0 {
+			out = append(out, forbiddenImports(c, r.ForbiddenImports)...)
+		}
+	}
+	return out
+}
+
+func missingTests(files []FileChange) []Violation {
+	tested := map[string]bool{}
+	for _, c := range files {
+		if c.After != nil && strings.HasSuffix(c.Path, "_test.go") {
+			tested[filepath.Dir(c.Path)] = true
+		}
+	}
+	var out []Violation
+	for _, c := range files {
+		if c.Before != nil || c.After == nil || !strings.HasSuffix(c.Path, ".go") || strings.HasSuffix(c.Path, "_test.go") {
+			continue
+		}
+		if !tested[filepath.Dir(c.Path)] {
+			out = append(out, Violation{Rule: "require_tests", Path: c.Path, Message: "new Go file without a new or changed _test.go in its directory"})
+		}
+	}
+	return out
+}
+
+// addedTODOs reports TODO lines in After that Before does not have.
+func addedTODOs(c FileChange) []Violation {
+	existing := map[string]int{}
+	for _, l := range strings.Split(string(c.Before), "\n") {
+		if todoRe.MatchString(l) {
+			existing[strings.TrimSpace(l)]++
+		}
+	}
+	var out []Violation
+	for i, l := range strings.Split(string(c.After), "\n") {
+		m := todoRe.FindString(l)
+		if m == "" {
+			continue
+		}
+		if key := strings.TrimSpace(l); existing[key] > 0 {
+			existing[key]--
+			continue
+		}
+		out = append(out, Violation{Rule: "forbid_todo", Path: c.Path, Line: i + 1, Message: m + " introduced"})
+	}
+	return out
+}
+
+// longFunctions reports functions over max lines that are new or were
+// within the limit before, so existing long functions do not block a change.
+func longFunctions(c FileChange, max int) []Violation {
+	after, err := funcLengths(c.After)
+	if err != nil {
+		// The verification commands report unparsable Go.
+		return nil
+	}
+	before, _ := funcLengths(c.Before)
+	var names []string
+	for name := range after {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return after[names[i]].line < after[names[j]].line })
+	var out []Violation
+	for _, name := range names {
+		fn := after[name]
+		if fn.lines <= max {
+			continue
+		}
+		if old, ok := before[name]; ok && old.lines > max {
+			continue
+		}
+		out = append(out, Violation{Rule: "max_function_lines", Path: c.Path, Line: fn.line, Message: fmt.Sprintf("%s is %d lines (max %d)", name, fn.lines, max)})
+	}
+	return out
+}
+
+type funcSpan struct{ line, lines int }
+
+func funcLengths(src []byte) (map[string]funcSpan, error) {
+	if src == nil {
+		return nil, nil
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string]funcSpan{}
+	for _, d := range f.Decls {
+		fd, ok := d.(*ast.FuncDecl)
+		if !ok || fd.Body == nil {
+			continue
+		}
+		start, end := fset.Position(fd.Pos()).Line, fset.Position(fd.End()).Line
+		out[funcName(fd)] = funcSpan{line: start, lines: end - start + 1}
+	}
+	return out, nil
+}
+
+func funcName(fd *ast.FuncDecl) string {
+	if fd.Recv == nil || len(fd.Recv.List) == 0 {
+		return fd.Name.Name
+	}
+	t := fd.Recv.List[0].Type
+	if s, ok := t.(*ast.StarExpr); ok {
+		t = s.X
+	}
+	switch x := t.(type) {
+	case *ast.IndexExpr:
+		t = x.X
+	case *ast.IndexListExpr:
+		t = x.X
+	}
+	if id, ok := t.(*ast.Ident); ok {
+		return id.Name + "." + fd.Name.Name
+	}
+	return fd.Name.Name
+}
+
+// forbiddenImports reports imports matching patterns that Before lacks.
+func forbiddenImports(c FileChange, patterns []string) []Violation {
+	after, err := importList(c.After)
+	if err != nil {
+		return nil
+	}
+	before, _ := imports(c.Before)
+	var out []Violation
+	for _, imp := range after {
+		if _, ok := before[imp.path]; ok || !matchImport(patterns, imp.path) {
+			continue
+		}
+		out = append(out, Violation{Rule: "forbidden_imports", Path: c.Path, Line: imp.line, Message: fmt.Sprintf("import %q is forbidden", imp.path)})
+	}
+	return out
+}
+
+type importLine struct {
+	path string
+	line int
+}
+
+// imports returns the set of a file's import paths.
+func imports(src []byte) (map[string]struct{}, error) {
+	list, err := importList(src)
+	out := map[string]struct{}{}
+	for _, imp := range list {
+		out[imp.path] = struct{}{}
+	}
+	return out, err
+}
+
+func importList(src []byte) ([]importLine, error) {
+	if src == nil {
+		return nil, nil
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	var out []importLine
+	for _, s := range f.Imports {
+		p, err := strconv.Unquote(s.Path.Value)
+		if err != nil {
+			continue
+		}
+		out = append(out, importLine{path: p, line: fset.Position(s.Pos()).Line})
+	}
+	return out, nil
+}
+
+func matchImport(patterns []string, path string) bool {
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		parts := strings.Split(p, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		if regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
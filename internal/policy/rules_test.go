@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRulesRejectsUnknownKeys(t *testing.T) {
+	r, err := ParseRules([]byte("require_tests: true\nmax_function_lines: 40\nforbidden_imports: [unsafe]\n"))
+	if err != nil {
+		t.Fatalf("parse rules: %v", err)
+	}
+	if !r.RequireTests || r.MaxFunctionLines != 40 || r.Empty() {
+		t.Fatalf("unexpected rules: %+v", r)
+	}
+	if r, err := ParseRules(nil); err != nil || !r.Empty() {
+		t.Fatalf("expected empty file to mean no rules, got %+v (%v)", r, err)
+	}
+	if _, err := ParseRules([]byte("require_test: true\n")); err == nil {
+		t.Fatalf("expected misspelled rule to fail")
+	}
+}
+
+func TestCheckReportsViolations(t *testing.T) {
+	long := "package a\n\nfunc Long() {\n" + strings.Repeat("\tprintln()\n", 5) + "}\n"
+	changes := []FileChange{
+		{Path: "a/new.go", After: []byte("package a\n\nimport \"unsafe\"\n\nvar _ = unsafe.Sizeof(0)\n")},
+		{Path: "a/old.go", Before: []byte("package a\n\n// TODO: keep\n"), After: []byte("package a\n\n// TODO: keep\n// FIXME: new\n")},
+		{Path: "a/long.go", Before: []byte("package a\n"), After: []byte(long)},
+		{Path: "b/gen.go", After: []byte("package b\n// TODO: generated\n")},
+	}
+	rules := Rules{
+		RequireTests:     true,
+		RequireChangelog: true,
+		ForbidTODO:       true,
+		MaxFunctionLines: 5,
+		ForbiddenImports: []string{"unsafe", "github.com/pkg/*"},
+		Exclude:          []string{"b/"},
+	}
+	var got []string
+	for _, v := range rules.Check(changes, false) {
+		got = append(got, v.String())
+	}
+	want := []string{
+		"require_changelog: CHANGELOG.md must change; set changelog_entry",
+		"a/new.go: require_tests: new Go file without a new or changed _test.go in its directory",
+		"a/new.go:3: forbidden_imports: import \"unsafe\" is forbidden",
+		"a/old.go:4: forbid_todo: FIXME introduced",
+		"a/long.go:3: max_function_lines: Long is 7 lines (max 5)",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected violations:\n%s", strings.Join(got, "\n"))
+	}
+
+	changes = append(changes[1:], FileChange{Path: "a/new_test.go", After: []byte("package a\n")})
+	changes[1].Before = []byte(long)
+	if v := rules.Check(changes, true); len(v) != 1 || v[0].Rule != "forbid_todo" {
+		t.Fatalf("expected only the new FIXME once tests, changelog and the existing long function are accounted for, got %v", v)
+	}
+}
@@ -0,0 +1,62 @@
+// Package protect identifies files that must not be edited without review,
+// either because they match protected_paths or because they carry a marker
+// comment such as "// evolver:protected" or "# evolver:do-not-edit".
+package protect
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/mmrzaf/evolver/internal/pathglob"
+)
+
+// markerLines is how far into a file a marker is looked for; like generated-code
+// headers, markers belong at the top.
+const markerLines = 20
+
+var markerRE = regexp.MustCompile(`^\s*(?://|#|/\*|<!--|--|;)\s*evolver:(?:protected|do-not-edit)\b`)
+
+// HasMarker reports whether one of the first lines of content is a
+// protection marker comment.
+func HasMarker(content []byte) bool {
+	sc := bufio.NewScanner(bytes.NewReader(content))
+	for i := 0; i < markerLines && sc.Scan(); i++ {
+		if markerRE.Match(sc.Bytes()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reason returns why path is protected given its content, or "" when it is not.
+func Reason(patterns []string, path string, content []byte) string {
+	for _, p := range patterns {
+		if pathglob.Match(p, path) {
+			return "protected_paths: " + p
+		}
+	}
+	if HasMarker(content) {
+		return "evolver:protected marker"
+	}
+	return ""
+}
+
+// FileReason is Reason for the file at path on disk. A missing file can only
+// be protected by pattern.
+func FileReason(patterns []string, path string) string {
+	return Reason(patterns, path, readHead(path))
+}
+
+// readHead returns the beginning of a file, enough to hold the marker lines.
+func readHead(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+	b, _ := io.ReadAll(io.LimitReader(f, 8<<10))
+	return b
+}
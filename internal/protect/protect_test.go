@@ -0,0 +1,39 @@
+package protect
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasMarker(t *testing.T) {
+	for content, want := range map[string]bool{
+		"// evolver:protected\npackage x\n":               true,
+		"#!/bin/sh\n# evolver:do-not-edit\necho hi\n":     true,
+		"<!-- evolver:protected -->\n# Title\n":           true,
+		"package x\n\nvar s = \"// evolver:protected\"\n": false,
+		"See `# evolver:do-not-edit` in the docs.\n":      false,
+		"// evolver:protectedness is not a marker\n":      false,
+	} {
+		if got := HasMarker([]byte(content)); got != want {
+			t.Errorf("HasMarker(%q) = %v, want %v", content, got, want)
+		}
+	}
+}
+
+func TestFileReason(t *testing.T) {
+	dir := t.TempDir()
+	marked := filepath.Join(dir, "keep.go")
+	if err := os.WriteFile(marked, []byte("// evolver:protected\npackage x\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got := FileReason(nil, marked); got != "evolver:protected marker" {
+		t.Fatalf("expected marker reason, got %q", got)
+	}
+	if got := FileReason([]string{"LICENSE"}, "LICENSE"); got != "protected_paths: LICENSE" {
+		t.Fatalf("expected pattern reason for missing file, got %q", got)
+	}
+	if got := FileReason([]string{"LICENSE"}, filepath.Join(dir, "missing.go")); got != "" {
+		t.Fatalf("expected unprotected, got %q", got)
+	}
+}
@@ -0,0 +1,190 @@
+// Package repair keeps per-run memory of repair attempts so later attempts can
+// see what was already tried and the loop can stop when it stops making progress.
+package repair
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+// Attempt records one repair iteration and the verification failure it left behind.
+type Attempt struct {
+	Number  int      `json:"attempt"`
+	Summary string   `json:"summary"`
+	Files   []string `json:"files_touched,omitempty"`
+	Actions []string `json:"actions_run,omitempty"`
+	// ResultCommand and ResultKind describe the failure after the attempt; both
+	// are empty when verification passed.
+	ResultCommand string `json:"result_command,omitempty"`
+	ResultKind    string `json:"result_kind,omitempty"`
+	// OutputDiff summarizes how the failure output changed compared to the
+	// failure the attempt was trying to fix.
+	OutputDiff string `json:"output_diff,omitempty"`
+	// RolledBack is set when the attempt made things worse and was undone.
+	RolledBack bool   `json:"rolled_back,omitempty"`
+	Signature  string `json:"-"`
+}
+
+// Transcript is the ordered list of repair attempts made during a run.
+type Transcript struct {
+	Attempts []Attempt
+}
+
+// Record completes a with the failure observed after it (nil when verification
+// passed) and appends it. prev is the failure the attempt was trying to fix.
+func (t *Transcript) Record(a Attempt, prev, result *verify.CommandResult) {
+	if result != nil {
+		a.ResultCommand = result.Command
+		a.ResultKind = result.Kind
+		a.Signature = Signature(*result)
+		if prev != nil {
+			a.OutputDiff = OutputDiff(*prev, *result, 40)
+		}
+	}
+	t.Attempts = append(t.Attempts, a)
+}
+
+// Stalled reports whether the last two attempts ended with an identical failure signature.
+func (t *Transcript) Stalled() bool {
+	n := len(t.Attempts)
+	if n < 2 {
+		return false
+	}
+	last, prev := t.Attempts[n-1], t.Attempts[n-2]
+	return last.Signature != "" && last.Signature == prev.Signature
+}
+
+// MarkRolledBack flags the most recent attempt as undone.
+func (t *Transcript) MarkRolledBack() {
+	if n := len(t.Attempts); n > 0 {
+		t.Attempts[n-1].RolledBack = true
+	}
+}
+
+// Score ranks a verification outcome. More passing commands is better; ties are
+// broken by a less severe failure kind, then by less failure output.
+type Score struct {
+	Passed     int
+	Severity   int
+	ErrorLines int
+}
+
+// ScoreReport scores report whose first regression is failure.
+func ScoreReport(report *verify.Report, failure verify.CommandResult) Score {
+	s := Score{Severity: severity(failure.Kind), ErrorLines: len(orderedLines(failure))}
+	if report != nil {
+		for _, c := range report.Commands {
+			if c.Passed {
+				s.Passed++
+			}
+		}
+	}
+	return s
+}
+
+// Better reports whether s is strictly better than o.
+func (s Score) Better(o Score) bool {
+	if s.Passed != o.Passed {
+		return s.Passed > o.Passed
+	}
+	if s.Severity != o.Severity {
+		return s.Severity < o.Severity
+	}
+	return s.ErrorLines < o.ErrorLines
+}
+
+// severity orders failure kinds from "does not build" down to "builds but a check fails".
+func severity(kind string) int {
+	switch kind {
+	case "compile_failure", "format_failure", "dependency_manifest_invalid", "dependency_resolution", "dependency_manifest_missing", "verify_command_invalid":
+		return 4
+	case "vet_failure":
+		return 3
+	case "test_failure", "timeout_failure":
+		return 2
+	default:
+		return 1
+	}
+}
+
+var volatile = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`\(\d+(\.\d+)?m?s\)`), "(<dur>)"},
+	{regexp.MustCompile(`\t\d+(\.\d+)?s\b`), "\t<dur>"},
+	{regexp.MustCompile(`0x[0-9a-fA-F]+`), "0x<addr>"},
+	{regexp.MustCompile(`goroutine \d+`), "goroutine <n>"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), "<time>"},
+	{regexp.MustCompile(`/tmp/[^\s:]+`), "<tmp>"},
+}
+
+// normalizeOutput strips durations, addresses and other run-to-run noise.
+func normalizeOutput(s string) string {
+	for _, v := range volatile {
+		s = v.re.ReplaceAllString(s, v.repl)
+	}
+	return strings.TrimSpace(s)
+}
+
+// Signature fingerprints a failure by command, kind and normalized output.
+func Signature(res verify.CommandResult) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s", res.Command, res.Kind, normalizeOutput(res.Stdout), normalizeOutput(res.Stderr))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// OutputDiff lists normalized output lines that disappeared (-) or appeared (+)
+// between prev and cur, capped at maxLines.
+func OutputDiff(prev, cur verify.CommandResult, maxLines int) string {
+	before := lineSet(prev)
+	after := lineSet(cur)
+	var b strings.Builder
+	if prev.Command != cur.Command || prev.Kind != cur.Kind {
+		fmt.Fprintf(&b, "failure moved: %s (%s) -> %s (%s)\n", prev.Command, prev.Kind, cur.Command, cur.Kind)
+	}
+	n := 0
+	for _, l := range orderedLines(prev) {
+		if !after[l] && n < maxLines {
+			b.WriteString("- " + l + "\n")
+			n++
+		}
+	}
+	for _, l := range orderedLines(cur) {
+		if !before[l] && n < maxLines {
+			b.WriteString("+ " + l + "\n")
+			n++
+		}
+	}
+	if n == 0 && b.Len() == 0 {
+		return "no change in failure output"
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func orderedLines(res verify.CommandResult) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, l := range strings.Split(normalizeOutput(res.Stdout+"\n"+res.Stderr), "\n") {
+		l = strings.TrimSpace(l)
+		if l == "" || seen[l] {
+			continue
+		}
+		seen[l] = true
+		out = append(out, l)
+	}
+	return out
+}
+
+func lineSet(res verify.CommandResult) map[string]bool {
+	m := make(map[string]bool)
+	for _, l := range orderedLines(res) {
+		m[l] = true
+	}
+	return m
+}
@@ -0,0 +1,77 @@
+package repair
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+func TestSignatureIgnoresVolatileOutput(t *testing.T) {
+	a := verify.CommandResult{Command: "go test ./...", Kind: "test_failure", Stdout: "--- FAIL: TestX (0.01s)\nFAIL\texample.com/m\t0.123s\n"}
+	b := verify.CommandResult{Command: "go test ./...", Kind: "test_failure", Stdout: "--- FAIL: TestX (1.50s)\nFAIL\texample.com/m\t2.001s\n"}
+	if Signature(a) != Signature(b) {
+		t.Fatalf("expected equal signatures for output differing only in durations")
+	}
+	c := b
+	c.Stdout = "--- FAIL: TestY (0.01s)\n"
+	if Signature(a) == Signature(c) {
+		t.Fatalf("expected different signatures for different failing tests")
+	}
+}
+
+func TestTranscriptStalledAfterTwoIdenticalFailures(t *testing.T) {
+	fail := verify.CommandResult{Command: "go build ./...", Kind: "compile_failure", Stderr: "a.go:3:2: undefined: Foo"}
+	other := verify.CommandResult{Command: "go build ./...", Kind: "compile_failure", Stderr: "a.go:9:1: syntax error: unexpected }"}
+
+	var tr Transcript
+	tr.Record(Attempt{Number: 1, Summary: "add Foo"}, &fail, &other)
+	if tr.Stalled() {
+		t.Fatalf("single attempt cannot stall")
+	}
+	tr.Record(Attempt{Number: 2, Summary: "fix brace"}, &other, &fail)
+	if tr.Stalled() {
+		t.Fatalf("different consecutive failures should not stall")
+	}
+	tr.Record(Attempt{Number: 3, Summary: "add Foo again"}, &fail, &fail)
+	if !tr.Stalled() {
+		t.Fatalf("expected stall after identical consecutive failures")
+	}
+	if got := tr.Attempts[2].OutputDiff; got != "no change in failure output" {
+		t.Fatalf("unexpected output diff: %q", got)
+	}
+}
+
+func TestOutputDiffShowsRemovedAndAddedLines(t *testing.T) {
+	prev := verify.CommandResult{Command: "go test ./...", Kind: "compile_failure", Stderr: "x.go:1: undefined: A\nx.go:2: undefined: B"}
+	cur := verify.CommandResult{Command: "go test ./...", Kind: "test_failure", Stdout: "--- FAIL: TestB (0.00s)\nx.go:2: undefined: B"}
+	got := OutputDiff(prev, cur, 10)
+	for _, want := range []string{"failure moved: go test ./... (compile_failure) -> go test ./... (test_failure)", "- x.go:1: undefined: A", "+ --- FAIL: TestB (<dur>)"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in diff:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "undefined: B") {
+		t.Fatalf("unchanged line should not appear in diff:\n%s", got)
+	}
+}
+
+func TestScoreReportPrefersMorePassingThenLessSevere(t *testing.T) {
+	compile := verify.CommandResult{Command: "go build ./...", Kind: "compile_failure", Stderr: "a.go:1: undefined: A"}
+	biggerCompile := verify.CommandResult{Command: "go build ./...", Kind: "compile_failure", Stderr: "a.go:1: undefined: A\nb.go:2: undefined: B"}
+	test := verify.CommandResult{Command: "go test ./...", Kind: "test_failure", Stdout: "--- FAIL: TestA"}
+
+	base := ScoreReport(&verify.Report{Commands: []verify.CommandResult{compile}}, compile)
+	worse := ScoreReport(&verify.Report{Commands: []verify.CommandResult{biggerCompile}}, biggerCompile)
+	if !base.Better(worse) || worse.Better(base) {
+		t.Fatalf("expected larger compile failure to score worse: %+v vs %+v", base, worse)
+	}
+
+	progressed := ScoreReport(&verify.Report{Commands: []verify.CommandResult{{Command: "go build ./...", Passed: true}, test}}, test)
+	if !progressed.Better(base) {
+		t.Fatalf("expected passing build with failing tests to beat compile failure: %+v vs %+v", progressed, base)
+	}
+	if base.Better(base) {
+		t.Fatalf("equal scores must not be better")
+	}
+}
@@ -6,21 +6,45 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/mmrzaf/evolver/internal/changelog"
 	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/policy"
+	"github.com/mmrzaf/evolver/internal/protect"
+	"github.com/mmrzaf/evolver/internal/roadmap"
 )
 
 // Context contains repository metadata and excerpts used in prompting.
 type Context struct {
-	Files     []string
+	// Goal is repo_goal, or the goal given with /evolver goal.
+	Goal string `json:",omitempty"`
+	// Task is the issue the run resolves when tasks.source is issues.
+	Task  *Task `json:",omitempty"`
+	Files []string
+	// Protected lists the files that match protected_paths or carry a
+	// protection marker.
+	Protected []string `json:",omitempty"`
 	Excerpts  map[string]string
 	Policy    string
-	Roadmap   string
+	// Rules are the machine-checked rules from .evolver/policy.yml.
+	Rules   *policy.Rules    `json:",omitempty"`
+	Roadmap *roadmap.Roadmap `json:",omitempty"`
+	// Target is the roadmap item the run works toward, unless Task is set.
+	Target *roadmap.Item `json:",omitempty"`
+	// Changelog is the Unreleased section, the recent work, up to 2000 bytes.
 	Changelog string
 }
 
+// Task is a GitHub issue used as the run's objective.
+type Task struct {
+	Issue    int
+	Title    string
+	Body     string
+	Comments []string `json:",omitempty"`
+}
+
 // Gather collects repository file data while respecting deny rules.
 func Gather(cfg *config.Config) (*Context, error) {
-	ctx := &Context{Excerpts: make(map[string]string)}
+	ctx := &Context{Goal: cfg.RepoGoal, Excerpts: make(map[string]string)}
 
 	if err := filepath.Walk(".", func(path string, info fs.FileInfo, err error) error {
 		if err != nil || info.IsDir() {
@@ -32,6 +56,9 @@ func Gather(cfg *config.Config) (*Context, error) {
 			}
 		}
 		ctx.Files = append(ctx.Files, path)
+		if protect.FileReason(cfg.ProtectedPaths, path) != "" {
+			ctx.Protected = append(ctx.Protected, path)
+		}
 		if info.Size() < 5000 {
 			b, _ := os.ReadFile(path)
 			ctx.Excerpts[path] = string(b)
@@ -43,13 +70,26 @@ func Gather(cfg *config.Config) (*Context, error) {
 
 	p, _ := os.ReadFile("POLICY.md")
 	ctx.Policy = string(p)
-	r, _ := os.ReadFile("ROADMAP.md")
-	ctx.Roadmap = string(r)
-	c, _ := os.ReadFile("CHANGELOG.md")
-	if len(c) > 2000 {
-		ctx.Changelog = string(c[len(c)-2000:])
-	} else {
-		ctx.Changelog = string(c)
+	rules, err := policy.LoadRules()
+	if err != nil {
+		return nil, err
+	}
+	if !rules.Empty() {
+		ctx.Rules = &rules
+	}
+	if r, err := os.ReadFile("ROADMAP.md"); err == nil {
+		ctx.Roadmap = roadmap.Parse(string(r))
+		ctx.Target = ctx.Roadmap.Target()
+	}
+	if c, err := os.ReadFile("CHANGELOG.md"); err == nil {
+		ctx.Changelog = changelog.Parse(string(c)).Unreleased()
+		if len(ctx.Changelog) > 2000 {
+			cut := ctx.Changelog[:2000]
+			if i := strings.LastIndexByte(cut, '\n'); i > 0 {
+				cut = cut[:i+1]
+			}
+			ctx.Changelog = cut
+		}
 	}
 
 	return ctx, nil
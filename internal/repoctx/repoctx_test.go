@@ -35,12 +35,12 @@ func TestGatherCollectsExpectedContextAndRespectsDenyPaths(t *testing.T) {
 	if err := os.WriteFile("ROADMAP.md", []byte("# ROADMAP\n"), 0644); err != nil {
 		t.Fatalf("write roadmap: %v", err)
 	}
-	large := strings.Repeat("x", 2100)
+	large := "# Changelog\n\n## [Unreleased]\n\n### Added\n" + strings.Repeat("- 2026-03-04: recent work (mode: pr, commands: pass)\n", 60) + "\n## [0.1.0] - 2025-01-01\n\n### Added\n- old release\n"
 	if err := os.WriteFile("CHANGELOG.md", []byte(large), 0644); err != nil {
 		t.Fatalf("write changelog: %v", err)
 	}
 
-	cfg := &config.Config{DenyPaths: []string{".github/workflows/"}}
+	cfg := &config.Config{RepoGoal: "Ship a CLI", DenyPaths: []string{".github/workflows/"}}
 	ctx, err := Gather(cfg)
 	if err != nil {
 		t.Fatalf("gather: %v", err)
@@ -54,7 +54,41 @@ func TestGatherCollectsExpectedContextAndRespectsDenyPaths(t *testing.T) {
 	if _, ok := ctx.Excerpts["main.go"]; !ok {
 		t.Fatalf("expected excerpt for main.go")
 	}
-	if len(ctx.Changelog) != 2000 {
-		t.Fatalf("expected changelog tail of 2000 chars, got %d", len(ctx.Changelog))
+	if ctx.Goal != "Ship a CLI" {
+		t.Fatalf("expected repo goal in context, got %q", ctx.Goal)
+	}
+	if len(ctx.Changelog) > 2000 || !strings.HasPrefix(ctx.Changelog, "## [Unreleased]\n\n### Added\n- 2026-03-04: recent work") ||
+		!strings.HasSuffix(ctx.Changelog, "commands: pass)\n") || strings.Contains(ctx.Changelog, "old release") {
+		t.Fatalf("expected the Unreleased section cut at a line within 2000 bytes, got %d bytes:\n%s", len(ctx.Changelog), ctx.Changelog)
+	}
+}
+
+func TestGatherListsProtectedFiles(t *testing.T) {
+	tmp := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"LICENSE": "MIT\n",
+		"keep.go": "// evolver:protected\npackage main\n",
+		"main.go": "package main\n",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	ctx, err := Gather(&config.Config{ProtectedPaths: []string{"LICENSE"}})
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	if strings.Join(ctx.Protected, ",") != "LICENSE,keep.go" {
+		t.Fatalf("unexpected protected files: %v", ctx.Protected)
 	}
 }
@@ -0,0 +1,320 @@
+// Package roadmap parses ROADMAP.md into its objective and checkbox items
+// and applies structured updates to it.
+package roadmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	headingRe = regexp.MustCompile(`^##\s+(.+?)\s*$`)
+	// itemRe matches a top-level checkbox item with an optional ID prefix.
+	itemRe = regexp.MustCompile(`^[-*]\s+\[([ xX])\]\s+(?:(R\d+):\s+)?(.*?)\s*$`)
+)
+
+// Item is a checkbox item. Items without an ID in the file get the next free
+// R<n> when parsed, numbered in file order. Those IDs are only stable once
+// written back (see AssignedIDs): until then an unlabeled item inserted above
+// another shifts its ID. Once written, an item added later without an ID
+// gets a new number and existing IDs never change.
+type Item struct {
+	ID      string
+	Text    string
+	Done    bool
+	Section string
+	// detail holds the indented lines below the item, which move with it.
+	detail []string
+}
+
+// Section is a level-two heading and the lines below it.
+type Section struct {
+	Name string
+	body []entry
+}
+
+// entry is an item or a line kept verbatim.
+type entry struct {
+	item *Item
+	raw  string
+}
+
+// Items returns the section's checkbox items in file order.
+func (s *Section) Items() []*Item {
+	var out []*Item
+	for _, e := range s.body {
+		if e.item != nil {
+			out = append(out, e.item)
+		}
+	}
+	return out
+}
+
+// MarshalJSON renders the section as its name and items for prompts.
+func (s *Section) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name  string
+		Items []*Item `json:",omitempty"`
+	}{s.Name, s.Items()})
+}
+
+// Roadmap is a parsed ROADMAP.md.
+type Roadmap struct {
+	// Objective is the text of the Current Objective section.
+	Objective string
+	Sections  []*Section
+	preamble  []string
+	nextID    int
+	assigned  bool
+}
+
+// Parse reads roadmap content. Lines it does not model are kept as-is.
+func Parse(content string) *Roadmap {
+	r := &Roadmap{nextID: 1}
+	var cur *Section
+	var pending []*Item
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	for _, line := range lines {
+		if m := headingRe.FindStringSubmatch(line); m != nil {
+			cur = &Section{Name: m[1]}
+			r.Sections = append(r.Sections, cur)
+			continue
+		}
+		if cur == nil {
+			r.preamble = append(r.preamble, line)
+			continue
+		}
+		if m := itemRe.FindStringSubmatch(line); m != nil {
+			it := &Item{ID: m[2], Text: m[3], Done: m[1] != " ", Section: cur.Name}
+			if it.ID != "" {
+				if n, _ := strconv.Atoi(it.ID[1:]); n >= r.nextID {
+					r.nextID = n + 1
+				}
+			} else {
+				pending = append(pending, it)
+			}
+			cur.body = append(cur.body, entry{item: it})
+			continue
+		}
+		if n := len(cur.body); n > 0 && cur.body[n-1].item != nil && strings.TrimSpace(line) != "" && strings.TrimLeft(line, " \t") != line {
+			it := cur.body[n-1].item
+			it.detail = append(it.detail, line)
+			continue
+		}
+		cur.body = append(cur.body, entry{raw: line})
+	}
+	for _, it := range pending {
+		it.ID = r.newID()
+	}
+	r.assigned = len(pending) > 0
+	if s := r.section("current objective"); s != nil {
+		var text []string
+		for _, e := range s.body {
+			if e.item == nil && strings.TrimSpace(e.raw) != "" {
+				text = append(text, strings.TrimSpace(e.raw))
+			}
+		}
+		r.Objective = strings.Join(text, " ")
+	}
+	return r
+}
+
+// AssignedIDs reports whether Parse gave IDs to items that had none in the
+// file. Such a roadmap should be written back so the IDs stay fixed.
+func (r *Roadmap) AssignedIDs() bool {
+	return r.assigned
+}
+
+func (r *Roadmap) newID() string {
+	id := fmt.Sprintf("R%d", r.nextID)
+	r.nextID++
+	return id
+}
+
+// String renders the roadmap with every item's ID.
+func (r *Roadmap) String() string {
+	var b strings.Builder
+	for _, l := range r.preamble {
+		b.WriteString(l + "\n")
+	}
+	for _, s := range r.Sections {
+		b.WriteString("## " + s.Name + "\n")
+		for _, e := range s.body {
+			if e.item == nil {
+				b.WriteString(e.raw + "\n")
+				continue
+			}
+			mark := " "
+			if e.item.Done {
+				mark = "x"
+			}
+			fmt.Fprintf(&b, "- [%s] %s: %s\n", mark, e.item.ID, e.item.Text)
+			for _, l := range e.item.detail {
+				b.WriteString(l + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// Item returns the item with the ID, or nil.
+func (r *Roadmap) Item(id string) *Item {
+	for _, s := range r.Sections {
+		for _, it := range s.Items() {
+			if it.ID == id {
+				return it
+			}
+		}
+	}
+	return nil
+}
+
+// Target returns the run's objective: the first unchecked item of Now, then
+// Next, then Later. A combined "Now/Next/Later" section counts as Now. It
+// returns nil when every planning item is done.
+func (r *Roadmap) Target() *Item {
+	for _, name := range []string{"now", "next", "later"} {
+		if s := r.section(name); s != nil {
+			for _, it := range s.Items() {
+				if !it.Done {
+					return it
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// section finds a section by case-insensitive name; "now" also matches a
+// combined "Now/Next/Later" heading.
+func (r *Roadmap) section(name string) *Section {
+	for _, s := range r.Sections {
+		n := strings.ToLower(strings.TrimSpace(s.Name))
+		if n == name || (name == "now" && strings.ReplaceAll(n, " ", "") == "now/next/later") {
+			return s
+		}
+	}
+	return nil
+}
+
+// Op is a structured roadmap update from a plan.
+type Op struct {
+	// Op is check (mark ID done), add (Text as a new item in Section) or
+	// move (ID to Section). Section is now, next or later.
+	Op      string `json:"op"`
+	ID      string `json:"id,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Section string `json:"section,omitempty"`
+}
+
+// String describes the op for pull request bodies.
+func (o Op) String() string {
+	switch o.Op {
+	case "check":
+		return "check " + o.ID
+	case "add":
+		return fmt.Sprintf("add to %s: %s", o.Section, o.Text)
+	case "move":
+		return fmt.Sprintf("move %s to %s", o.ID, o.Section)
+	}
+	return o.Op
+}
+
+// Apply applies ops in order. It stops at the first invalid op; earlier
+// ops stay applied, so callers validate on a copy first.
+func (r *Roadmap) Apply(ops []Op) error {
+	for i, op := range ops {
+		if err := r.apply(op); err != nil {
+			return fmt.Errorf("roadmap op %d (%s): %w", i+1, op.Op, err)
+		}
+	}
+	return nil
+}
+
+func (r *Roadmap) apply(op Op) error {
+	switch op.Op {
+	case "check":
+		it := r.Item(op.ID)
+		if it == nil {
+			return fmt.Errorf("unknown item %q", op.ID)
+		}
+		it.Done = true
+		return nil
+	case "add":
+		text := strings.TrimSpace(op.Text)
+		if text == "" || strings.ContainsAny(text, "\n\r") {
+			return fmt.Errorf("item text must be a single non-empty line")
+		}
+		s, err := r.listSection(op.Section)
+		if err != nil {
+			return err
+		}
+		s.insert(&Item{ID: r.newID(), Text: text, Section: s.Name})
+		return nil
+	case "move":
+		it := r.Item(op.ID)
+		if it == nil {
+			return fmt.Errorf("unknown item %q", op.ID)
+		}
+		s, err := r.listSection(op.Section)
+		if err != nil {
+			return err
+		}
+		for _, from := range r.Sections {
+			from.remove(it)
+		}
+		it.Section = s.Name
+		s.insert(it)
+		return nil
+	}
+	return fmt.Errorf("unknown op %q (want check, add or move)", op.Op)
+}
+
+// listSection returns the Now, Next or Later section, adding a missing Next
+// or Later section at the end.
+func (r *Roadmap) listSection(name string) (*Section, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "now", "next", "later":
+	default:
+		return nil, fmt.Errorf("unknown section %q (want now, next or later)", name)
+	}
+	if s := r.section(name); s != nil {
+		return s, nil
+	}
+	if n := len(r.Sections); n > 0 {
+		last := r.Sections[n-1]
+		if len(last.body) == 0 || last.body[len(last.body)-1].item != nil || strings.TrimSpace(last.body[len(last.body)-1].raw) != "" {
+			last.body = append(last.body, entry{raw: ""})
+		}
+	}
+	s := &Section{Name: strings.ToUpper(name[:1]) + name[1:]}
+	r.Sections = append(r.Sections, s)
+	return s, nil
+}
+
+// insert adds it after the section's last item, or at the top of the
+// section when it has none.
+func (s *Section) insert(it *Item) {
+	at := 0
+	for i, e := range s.body {
+		if e.item != nil {
+			at = i + 1
+		}
+	}
+	s.body = append(s.body, entry{})
+	copy(s.body[at+1:], s.body[at:])
+	s.body[at] = entry{item: it}
+}
+
+func (s *Section) remove(it *Item) {
+	for i, e := range s.body {
+		if e.item == it {
+			s.body = append(s.body[:i], s.body[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package roadmap
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const sample = `# ROADMAP
+## Current Objective
+Ship a reliable CLI.
+
+## Now
+- [x] R1: Initial scaffold
+- [ ] Add retries
+  - [ ] nested detail
+## Next
+- [ ] R4: Config file support
+## Later
+- [ ] Plugins
+
+## Definition of Done
+Tests pass.
+`
+
+func TestParseAssignsIDsAndPicksTarget(t *testing.T) {
+	r := Parse(sample)
+	if r.Objective != "Ship a reliable CLI." {
+		t.Fatalf("unexpected objective %q", r.Objective)
+	}
+	target := r.Target()
+	if target == nil || target.ID != "R5" || target.Text != "Add retries" || target.Section != "Now" {
+		t.Fatalf("unexpected target %+v", target)
+	}
+	if it := r.Item("R6"); it == nil || it.Text != "Plugins" {
+		t.Fatalf("expected Plugins to get R6, got %+v", it)
+	}
+	if !r.AssignedIDs() {
+		t.Fatalf("expected assigned IDs to be reported")
+	}
+	// Once written back, an item inserted above keeps existing IDs.
+	persisted := Parse(r.String())
+	if persisted.AssignedIDs() {
+		t.Fatalf("expected a written-back roadmap to have every ID")
+	}
+	inserted := Parse(strings.Replace(persisted.String(), "## Now\n", "## Now\n- [ ] Urgent fix\n", 1))
+	if it := inserted.Item("R5"); it == nil || it.Text != "Add retries" {
+		t.Fatalf("expected Add retries to keep R5, got %+v", it)
+	}
+	if it := inserted.Target(); it == nil || it.ID != "R7" || it.Text != "Urgent fix" {
+		t.Fatalf("expected the inserted item to get R7, got %+v", it)
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if !strings.Contains(string(b), `{"Name":"Next","Items":[{"ID":"R4","Text":"Config file support","Done":false,"Section":"Next"}]}`) {
+		t.Fatalf("unexpected json %s", b)
+	}
+}
+
+func TestApplyOpsAndRender(t *testing.T) {
+	r := Parse(sample)
+	err := r.Apply([]Op{
+		{Op: "check", ID: "R5"},
+		{Op: "add", Text: "Retry budget metrics", Section: "next"},
+		{Op: "move", ID: "R6", Section: "now"},
+	})
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	want := `# ROADMAP
+## Current Objective
+Ship a reliable CLI.
+
+## Now
+- [x] R1: Initial scaffold
+- [x] R5: Add retries
+  - [ ] nested detail
+- [ ] R6: Plugins
+## Next
+- [ ] R4: Config file support
+- [ ] R7: Retry budget metrics
+## Later
+
+## Definition of Done
+Tests pass.
+`
+	if got := r.String(); got != want {
+		t.Fatalf("unexpected roadmap:\n%s", got)
+	}
+	if again := Parse(r.String()).String(); again != want {
+		t.Fatalf("expected rendering to round-trip, got:\n%s", again)
+	}
+}
+
+func TestApplyRejectsInvalidOps(t *testing.T) {
+	for _, op := range []Op{
+		{Op: "check", ID: "R99"},
+		{Op: "add", Text: " ", Section: "now"},
+		{Op: "add", Text: "x", Section: "someday"},
+		{Op: "move", ID: "R1", Section: ""},
+		{Op: "rewrite"},
+	} {
+		if err := Parse(sample).Apply([]Op{op}); err == nil {
+			t.Fatalf("expected %+v to be rejected", op)
+		}
+	}
+}
+
+func TestCombinedSectionAndMissingNext(t *testing.T) {
+	r := Parse("# ROADMAP\n## Current Objective\nGoal\n\n## Now/Next/Later\n- [ ] Initial scaffold\n")
+	if target := r.Target(); target == nil || target.ID != "R1" {
+		t.Fatalf("expected combined section to count as now, got %+v", target)
+	}
+	if err := r.Apply([]Op{{Op: "move", ID: "R1", Section: "later"}}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if got := r.String(); got != "# ROADMAP\n## Current Objective\nGoal\n\n## Now/Next/Later\n\n## Later\n- [ ] R1: Initial scaffold\n" {
+		t.Fatalf("unexpected roadmap %q", got)
+	}
+	if r.Target() == nil {
+		t.Fatalf("expected later item to remain a target")
+	}
+}
@@ -0,0 +1,170 @@
+package runstate
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/repair"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+// Record is one run in the history ledger, an append-only JSONL file.
+type Record struct {
+	RunID   string `json:"run_id"`
+	Trigger string `json:"trigger"`
+	Mode    string `json:"mode"`
+	// Outcome is changed, noop, skipped or error.
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMS int64     `json:"duration_ms"`
+	SHABefore  string    `json:"sha_before,omitempty"`
+	SHAAfter   string    `json:"sha_after,omitempty"`
+	Branch     string    `json:"branch,omitempty"`
+	Summary    string    `json:"summary,omitempty"`
+	Issue      int       `json:"issue,omitempty"`
+	// RoadmapItem is the ID the plan worked toward.
+	RoadmapItem  string           `json:"roadmap_item,omitempty"`
+	Files        []string         `json:"files,omitempty"`
+	Diff         *DiffStats       `json:"diff,omitempty"`
+	Verification []CommandSummary `json:"verification,omitempty"`
+	Repairs      []repair.Attempt `json:"repairs,omitempty"`
+	Tokens       *Tokens          `json:"tokens,omitempty"`
+	PRURL        string           `json:"pr_url,omitempty"`
+}
+
+// DiffStats is the size of the committed change.
+type DiffStats struct {
+	FilesChanged int `json:"files_changed"`
+	LinesChanged int `json:"lines_changed"`
+	NewFiles     int `json:"new_files"`
+}
+
+// CommandSummary is a verification result without its output.
+type CommandSummary struct {
+	Command    string `json:"command"`
+	Passed     bool   `json:"passed"`
+	ExitCode   int    `json:"exit_code"`
+	Kind       string `json:"kind,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+	Flaky      bool   `json:"flaky,omitempty"`
+}
+
+// Tokens is the provider token usage of a run.
+type Tokens struct {
+	Calls  int `json:"calls"`
+	Prompt int `json:"prompt"`
+	Output int `json:"output"`
+	Total  int `json:"total"`
+}
+
+// SummarizeReport drops command output from a verification report.
+func SummarizeReport(r *verify.Report) []CommandSummary {
+	if r == nil {
+		return nil
+	}
+	out := make([]CommandSummary, 0, len(r.Commands))
+	for _, c := range r.Commands {
+		out = append(out, CommandSummary{Command: c.Command, Passed: c.Passed, ExitCode: c.ExitCode, Kind: c.Kind, DurationMS: c.DurationMS, Flaky: c.Flaky})
+	}
+	return out
+}
+
+// AppendHistory appends rec to the ledger at path as one JSON line.
+func AppendHistory(path string, rec Record) (err error) {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := ensureParentDir(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+// HistoryFilter selects ledger records; zero fields match everything.
+type HistoryFilter struct {
+	Outcome string
+	Trigger string
+	// Kind matches a failure kind seen in verification or after a repair.
+	Kind  string
+	Since time.Time
+	// Limit keeps the newest records; 0 keeps all.
+	Limit int
+}
+
+// Match reports whether rec passes the filter.
+func (f HistoryFilter) Match(rec Record) bool {
+	if f.Outcome != "" && rec.Outcome != f.Outcome {
+		return false
+	}
+	if f.Trigger != "" && !strings.HasPrefix(rec.Trigger, f.Trigger) {
+		return false
+	}
+	if !f.Since.IsZero() && rec.StartedAt.Before(f.Since) {
+		return false
+	}
+	if f.Kind == "" {
+		return true
+	}
+	for _, c := range rec.Verification {
+		if c.Kind == f.Kind {
+			return true
+		}
+	}
+	for _, a := range rec.Repairs {
+		if a.ResultKind == f.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadHistory returns the records at path that match f, newest first. A
+// missing ledger has no records; lines that do not parse, such as one cut
+// short by a crash, are skipped.
+func ReadHistory(path string, f HistoryFilter) ([]Record, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var out []Record
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64*1024), 16<<20)
+	for sc.Scan() {
+		var rec Record
+		if json.Unmarshal(sc.Bytes(), &rec) != nil || !f.Match(rec) {
+			continue
+		}
+		out = append(out, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package runstate
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mmrzaf/evolver/internal/repair"
+	"github.com/mmrzaf/evolver/internal/verify"
+)
+
+func TestHistoryAppendAndFilter(t *testing.T) {
+	path := t.TempDir() + "/nested/history.jsonl"
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{RunID: "1", Trigger: "run", Outcome: "noop", StartedAt: day},
+		{RunID: "2", Trigger: "run", Outcome: "changed", StartedAt: day.Add(24 * time.Hour),
+			Verification: SummarizeReport(&verify.Report{Commands: []verify.CommandResult{{Command: "go test ./...", Passed: true, Stdout: "ok"}}}),
+			Repairs:      []repair.Attempt{{Number: 1, ResultKind: "test_failure"}}},
+		{RunID: "3", Trigger: "/evolver retry", Outcome: "error", Error: "boom", StartedAt: day.Add(48 * time.Hour)},
+	}
+	for _, rec := range records {
+		if err := AppendHistory(path, rec); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	// A line cut short by a crash is skipped.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = f.WriteString(`{"run_id": "4", "outc`)
+	_ = f.Close()
+
+	ids := func(f HistoryFilter) string {
+		t.Helper()
+		recs, err := ReadHistory(path, f)
+		if err != nil {
+			t.Fatalf("read history: %v", err)
+		}
+		var s string
+		for _, r := range recs {
+			s += r.RunID
+		}
+		return s
+	}
+	for _, tc := range []struct {
+		filter HistoryFilter
+		want   string
+	}{
+		{HistoryFilter{}, "321"},
+		{HistoryFilter{Limit: 2}, "32"},
+		{HistoryFilter{Outcome: "changed"}, "2"},
+		{HistoryFilter{Trigger: "/evolver"}, "3"},
+		{HistoryFilter{Kind: "test_failure"}, "2"},
+		{HistoryFilter{Since: day.Add(time.Hour)}, "32"},
+	} {
+		if got := ids(tc.filter); got != tc.want {
+			t.Fatalf("filter %+v: got %q, want %q", tc.filter, got, tc.want)
+		}
+	}
+
+	recs, _ := ReadHistory(path, HistoryFilter{Outcome: "changed"})
+	if len(recs[0].Verification) != 1 || !recs[0].Verification[0].Passed || recs[0].Verification[0].Command != "go test ./..." {
+		t.Fatalf("unexpected verification summary: %+v", recs[0].Verification)
+	}
+	if recs, err := ReadHistory(t.TempDir()+"/missing.jsonl", HistoryFilter{}); err != nil || recs != nil {
+		t.Fatalf("expected no records for a missing ledger, got %v (%v)", recs, err)
+	}
+}
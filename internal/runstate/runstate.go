@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -24,6 +25,9 @@ type State struct {
 	TotalChangedRuns    int    `json:"total_changed_runs"`
 	ConsecutiveFailures int    `json:"consecutive_failures"`
 	ConsecutiveNoop     int    `json:"consecutive_noop"`
+
+	LastFlakyTests  []string       `json:"last_flaky_tests,omitempty"`
+	FlakyTestCounts map[string]int `json:"flaky_test_counts,omitempty"`
 }
 
 // Recorder persists and appends run-state events.
@@ -109,6 +113,24 @@ func (r *Recorder) Finish(changed bool, summary string, runErr error) error {
 	return nil
 }
 
+// RecordFlakyTests stores tests that failed and passed on rerun during this run.
+func (r *Recorder) RecordFlakyTests(tests []string) error {
+	r.state.LastFlakyTests = tests
+	if len(tests) == 0 {
+		return r.save()
+	}
+	if r.state.FlakyTestCounts == nil {
+		r.state.FlakyTestCounts = make(map[string]int)
+	}
+	for _, t := range tests {
+		r.state.FlakyTestCounts[t]++
+	}
+	if err := r.save(); err != nil {
+		return err
+	}
+	return r.appendLog("flaky", strings.Join(tests, ","))
+}
+
 // AcquireLock acquires a lock file or recovers a stale lock.
 func AcquireLock(lockPath string, staleAfter time.Duration) (func(), error) {
 	if err := ensureParentDir(lockPath); err != nil {
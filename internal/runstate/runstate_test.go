@@ -71,6 +71,34 @@ func TestRecorderTracksFailures(t *testing.T) {
 	}
 }
 
+func TestRecorderRecordsFlakyTests(t *testing.T) {
+	tmp := t.TempDir()
+	r, err := NewRecorder(tmp+"/state.json", tmp+"/runs.log")
+	if err != nil {
+		t.Fatalf("new recorder: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.RecordFlakyTests([]string{"TestRetry"}); err != nil {
+			t.Fatalf("record flaky tests: %v", err)
+		}
+	}
+
+	b, err := os.ReadFile(tmp + "/state.json")
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	if !strings.Contains(string(b), "\"TestRetry\": 2") {
+		t.Fatalf("expected flaky count in state: %s", string(b))
+	}
+	logs, err := os.ReadFile(tmp + "/runs.log")
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if !strings.Contains(string(logs), "event=flaky") {
+		t.Fatalf("expected flaky log entry")
+	}
+}
+
 func TestAcquireLockAndRecoverStaleLock(t *testing.T) {
 	tmp := t.TempDir()
 	lockPath := tmp + "/run.lock"
@@ -0,0 +1,97 @@
+// Package sandbox runs untrusted verification and repair commands with
+// restricted filesystem, network and resource access.
+package sandbox
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Policy describes how a command is isolated. The zero value runs commands unsandboxed.
+type Policy struct {
+	Enabled bool
+	// Backend is auto, bwrap or namespaces.
+	Backend string
+	// Root is the absolute worktree path; it stays writable while the rest of
+	// the filesystem is read-only.
+	Root          string
+	WritablePaths []string
+	AllowNetwork  bool
+	// CPUSeconds and MemoryMB are enforced per process with prlimit when it
+	// is available; MemoryMB caps the data segment, not address space. 0
+	// means unlimited.
+	CPUSeconds int
+	MemoryMB   int
+	// MaxOutputBytes caps how much of each output stream is kept; 0 means unlimited.
+	MaxOutputBytes int
+}
+
+// Command returns a command that runs argv in dir under p. With p disabled it
+// is equivalent to exec.CommandContext.
+func Command(ctx context.Context, p Policy, dir string, argv []string) (*exec.Cmd, error) {
+	if !p.Enabled {
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Dir = dir
+		return cmd, nil
+	}
+	return command(ctx, p, dir, argv)
+}
+
+// GoCacheDirs returns the Go build and module caches, created if missing,
+// so a sandboxed go command can write them. It returns nil when go is not
+// installed. go env runs once per process.
+func GoCacheDirs() []string { return goCacheDirs() }
+
+var goCacheDirs = sync.OnceValue(func() []string {
+	out, err := exec.Command("go", "env", "GOCACHE", "GOMODCACHE").Output()
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, d := range strings.Fields(string(out)) {
+		if d == "off" || os.MkdirAll(d, 0o755) != nil {
+			continue
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs
+})
+
+// LimitOutput wraps w so that at most p.MaxOutputBytes are written when the
+// policy is enabled; the rest is discarded without failing the command.
+func LimitOutput(p Policy, w io.Writer) io.Writer {
+	if !p.Enabled || p.MaxOutputBytes <= 0 {
+		return w
+	}
+	return &limitWriter{w: w, remaining: p.MaxOutputBytes}
+}
+
+type limitWriter struct {
+	w         io.Writer
+	remaining int
+	truncated bool
+}
+
+func (l *limitWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	if l.remaining <= 0 {
+		if !l.truncated {
+			l.truncated = true
+			_, _ = io.WriteString(l.w, "\n...<output truncated by sandbox limit>...\n")
+		}
+		return n, nil
+	}
+	if len(b) > l.remaining {
+		b = b[:l.remaining]
+	}
+	written, err := l.w.Write(b)
+	l.remaining -= written
+	if err != nil {
+		return written, err
+	}
+	return n, nil
+}
@@ -0,0 +1,138 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// namespacesWarning and prlimitWarning are logged once per process, not per
+// command.
+var namespacesWarning, prlimitWarning sync.Once
+
+func command(ctx context.Context, p Policy, dir string, argv []string) (*exec.Cmd, error) {
+	if p.Root == "" {
+		return nil, fmt.Errorf("sandbox: root is required")
+	}
+	workDir, err := absDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	argv = withResourceLimits(p, argv)
+
+	// auto never degrades to namespaces: a sandbox without a read-only root
+	// has to be asked for by name.
+	backend := strings.ToLower(strings.TrimSpace(p.Backend))
+	if backend == "" || backend == "auto" {
+		backend = "bwrap"
+	}
+
+	switch backend {
+	case "bwrap":
+		bwrap, err := exec.LookPath("bwrap")
+		if err != nil {
+			return nil, fmt.Errorf("sandbox: bwrap not found (install bubblewrap, or set sandbox.backend: namespaces to run without a read-only root): %w", err)
+		}
+		args := append(bwrapArgs(p, workDir), argv...)
+		cmd := exec.CommandContext(ctx, bwrap, args...)
+		cmd.Dir = workDir
+		return cmd, nil
+	case "namespaces":
+		// Without a helper inside the new mount namespace the root cannot be
+		// remounted read-only; only user and network isolation apply.
+		namespacesWarning.Do(func() {
+			slog.Warn("sandbox namespaces backend does not make the filesystem read-only; install bubblewrap for full isolation")
+		})
+		cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+		cmd.Dir = workDir
+		flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS)
+		if !p.AllowNetwork {
+			flags |= syscall.CLONE_NEWNET
+		}
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags:  flags,
+			Pdeathsig:   syscall.SIGKILL,
+			UidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}},
+			GidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}},
+		}
+		return cmd, nil
+	default:
+		return nil, fmt.Errorf("sandbox: unknown backend %q", p.Backend)
+	}
+}
+
+// bwrapArgs mounts the host root read-only, the worktree and writable paths
+// read-write, and unshares every namespace except the network when allowed.
+func bwrapArgs(p Policy, workDir string) []string {
+	args := []string{
+		"--ro-bind", "/", "/",
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--bind", p.Root, p.Root,
+	}
+	for _, w := range p.WritablePaths {
+		w = strings.TrimSpace(w)
+		if w == "" {
+			continue
+		}
+		if _, err := os.Stat(w); err != nil {
+			continue
+		}
+		args = append(args, "--bind", w, w)
+	}
+	args = append(args,
+		"--unshare-user",
+		"--unshare-ipc",
+		"--unshare-pid",
+		"--unshare-uts",
+		"--unshare-cgroup-try",
+	)
+	if !p.AllowNetwork {
+		args = append(args, "--unshare-net")
+	}
+	return append(args, "--die-with-parent", "--chdir", workDir, "--")
+}
+
+// withResourceLimits prefixes argv with prlimit when limits are configured.
+// Memory is capped with RLIMIT_DATA rather than RLIMIT_AS: the Go runtime
+// reserves far more address space than it uses, so an address-space cap
+// breaks go build and go test long before memory runs out. Both limits apply
+// to each process separately, not to the command's process tree.
+func withResourceLimits(p Policy, argv []string) []string {
+	if p.CPUSeconds <= 0 && p.MemoryMB <= 0 {
+		return argv
+	}
+	prlimit, err := exec.LookPath("prlimit")
+	if err != nil {
+		prlimitWarning.Do(func() {
+			slog.Warn("prlimit not found; sandbox CPU and memory limits are not enforced")
+		})
+		return argv
+	}
+	out := []string{prlimit}
+	if p.CPUSeconds > 0 {
+		out = append(out, "--cpu="+strconv.Itoa(p.CPUSeconds))
+	}
+	if p.MemoryMB > 0 {
+		out = append(out, "--data="+strconv.FormatInt(int64(p.MemoryMB)<<20, 10))
+	}
+	out = append(out, "--")
+	return append(out, argv...)
+}
+
+func absDir(dir string) (string, error) {
+	if dir == "" {
+		return os.Getwd()
+	}
+	return filepath.Abs(dir)
+}
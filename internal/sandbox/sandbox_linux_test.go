@@ -0,0 +1,78 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBwrapArgsIsolateNetworkUnlessAllowed(t *testing.T) {
+	p := Policy{Enabled: true, Root: "/work/repo"}
+	args := strings.Join(bwrapArgs(p, "/work/repo/sub"), " ")
+	for _, want := range []string{"--ro-bind / /", "--bind /work/repo /work/repo", "--unshare-net", "--chdir /work/repo/sub --"} {
+		if !strings.Contains(args, want) {
+			t.Fatalf("expected %q in bwrap args: %s", want, args)
+		}
+	}
+
+	p.AllowNetwork = true
+	if args := strings.Join(bwrapArgs(p, "/work/repo"), " "); strings.Contains(args, "--unshare-net") {
+		t.Fatalf("expected network to be shared when allowed: %s", args)
+	}
+}
+
+func TestWithResourceLimitsUsesPrlimit(t *testing.T) {
+	argv := []string{"go", "test", "./..."}
+	if got := withResourceLimits(Policy{}, argv); len(got) != len(argv) {
+		t.Fatalf("expected argv unchanged without limits, got %v", got)
+	}
+	got := strings.Join(withResourceLimits(Policy{CPUSeconds: 60, MemoryMB: 512}, argv), " ")
+	if !strings.Contains(got, "prlimit") {
+		t.Skip("prlimit not installed")
+	}
+	if !strings.Contains(got, "--cpu=60") || !strings.Contains(got, "--data=536870912") || !strings.HasSuffix(got, "-- go test ./...") {
+		t.Fatalf("unexpected prlimit argv: %s", got)
+	}
+}
+
+func TestAutoBackendFailsClosedWithoutBwrap(t *testing.T) {
+	if _, err := exec.LookPath("bwrap"); err == nil {
+		t.Skip("bwrap installed")
+	}
+	if _, err := Command(context.Background(), Policy{Enabled: true, Backend: "auto", Root: t.TempDir()}, "", []string{"true"}); err == nil || !strings.Contains(err.Error(), "backend: namespaces") {
+		t.Fatalf("expected auto to fail without bwrap and name the opt-in, got %v", err)
+	}
+}
+
+func TestDefaultPolicyBuildsGoModule(t *testing.T) {
+	if _, err := exec.LookPath("bwrap"); err != nil {
+		t.Skip("bwrap not installed")
+	}
+	if err := exec.Command("bwrap", "--ro-bind", "/", "/", "--unshare-user", "true").Run(); err != nil {
+		t.Skipf("bwrap cannot create namespaces here: %v", err)
+	}
+	root := t.TempDir()
+	for name, body := range map[string]string{
+		"go.mod":  "module example.com/sandboxed\n\ngo 1.21\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	// The defaults of sandbox: in config, with the Go caches evolver adds.
+	p := Policy{Enabled: true, Backend: "auto", Root: root, WritablePaths: GoCacheDirs(), CPUSeconds: 1800, MemoryMB: 8192}
+	cmd, err := Command(context.Background(), p, root, []string{"go", "build", "./..."})
+	if err != nil {
+		t.Fatalf("sandbox command: %v", err)
+	}
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOTOOLCHAIN=local")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("sandboxed go build failed: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+func command(context.Context, Policy, string, []string) (*exec.Cmd, error) {
+	return nil, fmt.Errorf("sandbox: only supported on linux")
+}
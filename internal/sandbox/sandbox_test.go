@@ -0,0 +1,33 @@
+package sandbox
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCommandDisabledRunsDirectly(t *testing.T) {
+	cmd, err := Command(context.Background(), Policy{}, "sub", []string{"go", "version"})
+	if err != nil {
+		t.Fatalf("command: %v", err)
+	}
+	if cmd.Dir != "sub" || cmd.Args[0] != "go" || cmd.SysProcAttr != nil {
+		t.Fatalf("expected plain command, got args=%v dir=%q", cmd.Args, cmd.Dir)
+	}
+}
+
+func TestLimitOutputTruncates(t *testing.T) {
+	var b strings.Builder
+	w := LimitOutput(Policy{Enabled: true, MaxOutputBytes: 5}, &b)
+	for _, chunk := range []string{"abc", "defg", "hij"} {
+		if n, err := w.Write([]byte(chunk)); err != nil || n != len(chunk) {
+			t.Fatalf("write %q: n=%d err=%v", chunk, n, err)
+		}
+	}
+	if got := b.String(); !strings.HasPrefix(got, "abcde\n...<output truncated") || strings.Count(got, "truncated") != 1 {
+		t.Fatalf("unexpected limited output: %q", got)
+	}
+	if LimitOutput(Policy{MaxOutputBytes: 5}, &b) != &b {
+		t.Fatalf("expected disabled policy to leave writer unchanged")
+	}
+}
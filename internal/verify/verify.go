@@ -2,14 +2,20 @@ package verify
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/mmrzaf/evolver/internal/config"
+	"github.com/mmrzaf/evolver/internal/sandbox"
 )
 
 // CommandResult captures a single verification command execution.
@@ -23,6 +29,9 @@ type CommandResult struct {
 	DurationMS int64         `json:"duration_ms"`
 	Passed     bool          `json:"passed"`
 	Kind       string        `json:"kind,omitempty"`
+	Flaky      bool          `json:"flaky,omitempty"`
+	FlakyTests []string      `json:"flaky_tests,omitempty"`
+	Reruns     int           `json:"reruns,omitempty"`
 	Duration   time.Duration `json:"-"`
 }
 
@@ -44,6 +53,85 @@ func (r *Report) FirstFailure() *CommandResult {
 	return nil
 }
 
+// Failures returns every failing command result in report order.
+func (r *Report) Failures() []CommandResult {
+	if r == nil {
+		return nil
+	}
+	var out []CommandResult
+	for _, c := range r.Commands {
+		if !c.Passed {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// FlakyTests returns the tests that failed and then passed on rerun, across all commands.
+func (r *Report) FlakyTests() []string {
+	if r == nil {
+		return nil
+	}
+	var out []string
+	for _, c := range r.Commands {
+		if c.Flaky {
+			out = append(out, c.FlakyTests...)
+		}
+	}
+	return out
+}
+
+// NewFailures returns failing results that did not already fail the same way
+// in baseline. A command that failed before the change but now fails
+// differently (for example test_failure turning into compile_failure) counts
+// as new, and so does a go test command that fails a test or package that
+// passed on the baseline.
+func (r *Report) NewFailures(baseline *Report) []CommandResult {
+	if r == nil {
+		return nil
+	}
+	known := make(map[string]CommandResult)
+	if baseline != nil {
+		for _, c := range baseline.Commands {
+			if !c.Passed {
+				known[c.Command] = c
+			}
+		}
+	}
+	var out []CommandResult
+	for _, c := range r.Commands {
+		if c.Passed {
+			continue
+		}
+		if before, ok := known[c.Command]; ok && before.Kind == c.Kind && !failsMoreGoTests(before, c) {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// failsMoreGoTests reports whether after names a failing go test or package
+// that before did not.
+func failsMoreGoTests(before, after CommandResult) bool {
+	beforeTests, beforePkgs := failingGoTests(before.Stdout + "\n" + before.Stderr)
+	afterTests, afterPkgs := failingGoTests(after.Stdout + "\n" + after.Stderr)
+	return !subset(afterTests, beforeTests) || !subset(afterPkgs, beforePkgs)
+}
+
+func subset(items, of []string) bool {
+	seen := make(map[string]bool, len(of))
+	for _, s := range of {
+		seen[s] = true
+	}
+	for _, s := range items {
+		if !seen[s] {
+			return false
+		}
+	}
+	return true
+}
+
 // CommandFailureError is returned when a verification command fails.
 type CommandFailureError struct {
 	Result CommandResult
@@ -53,6 +141,20 @@ func (e *CommandFailureError) Error() string {
 	return fmt.Sprintf("command failed: %s (exit=%d, kind=%s)", e.Result.Command, e.Result.ExitCode, e.Result.Kind)
 }
 
+// Options controls how a verification run executes.
+type Options struct {
+	// ContinueOnFailure runs every command even after one fails so the Report
+	// describes the whole suite. The returned error still names the first failure.
+	ContinueOnFailure bool
+	// Retries reruns the failing tests of a test_failure up to this many times.
+	// If they pass on rerun the command is marked flaky and treated as passing.
+	Retries int
+	// MaxParallel bounds how many commands of a parallel group run at once.
+	MaxParallel int
+	// Sandbox isolates each command; per-command allow_network overrides its network setting.
+	Sandbox sandbox.Policy
+}
+
 // RunCommands preserves the old API for callers/tests that only care about pass/fail.
 func RunCommands(commands []string) error {
 	_, err := RunCommandsReport(commands)
@@ -62,74 +164,335 @@ func RunCommands(commands []string) error {
 // RunCommandsReport executes verification commands and returns structured results.
 // It stops at the first failure.
 func RunCommandsReport(commands []string) (*Report, error) {
+	return Run(config.Commands(commands...), Options{})
+}
+
+// Run executes verification commands according to opts and returns structured results.
+// Groups of commands (see config.Command) run concurrently; the Report stays in
+// command order and, unless ContinueOnFailure is set, no group starts after a failure.
+func Run(commands []config.Command, opts Options) (*Report, error) {
 	if len(commands) == 0 {
-		commands = inferCommands()
+		commands = config.Commands(inferCommands()...)
 	}
-	slog.Info("verification commands prepared", "count", len(commands))
+	slog.Info("verification commands prepared", "count", len(commands), "continue_on_failure", opts.ContinueOnFailure, "max_parallel", opts.MaxParallel)
 
 	report := &Report{Commands: make([]CommandResult, 0, len(commands))}
+	var firstFailure *CommandFailureError
+
+	for _, group := range groupCommands(commands) {
+		results := runGroup(commands, group, opts)
+		for _, res := range results {
+			if res == nil {
+				continue
+			}
+			report.Commands = append(report.Commands, *res)
+			if !res.Passed && firstFailure == nil {
+				firstFailure = &CommandFailureError{Result: *res}
+			}
+		}
+		if firstFailure != nil && !opts.ContinueOnFailure {
+			break
+		}
+	}
 
-	for i, cmdStr := range commands {
-		parts := strings.Fields(cmdStr)
-		if len(parts) == 0 {
-			continue
+	if firstFailure != nil {
+		return report, firstFailure
+	}
+	return report, nil
+}
+
+// groupCommands splits commands into ordered groups of indexes. Consecutive
+// commands that share a stage name, or are marked parallel without a stage, form
+// one concurrent group; every other command is a group of its own.
+func groupCommands(commands []config.Command) [][]int {
+	var groups [][]int
+	for i, c := range commands {
+		concurrent := c.Parallel || c.Stage != ""
+		if concurrent && i > 0 {
+			prev := commands[i-1]
+			if (prev.Parallel || prev.Stage != "") && prev.Stage == c.Stage {
+				groups[len(groups)-1] = append(groups[len(groups)-1], i)
+				continue
+			}
 		}
+		groups = append(groups, []int{i})
+	}
+	return groups
+}
+
+// runGroup runs one group and returns its results indexed like group; nil
+// entries are blank commands.
+func runGroup(commands []config.Command, group []int, opts Options) []*CommandResult {
+	results := make([]*CommandResult, len(group))
+	total := len(commands)
+
+	if len(group) == 1 {
+		i := group[0]
+		if res, ok := runOne(i, total, commands[i], opts, os.Stdout, os.Stderr); ok {
+			results[0] = &res
+		}
+		return results
+	}
 
-		startedAt := time.Now()
-		slog.Info("verification command started", "index", i+1, "total", len(commands), "command", cmdStr)
+	workers := opts.MaxParallel
+	if workers <= 0 || workers > len(group) {
+		workers = len(group)
+	}
+	if stage := commands[group[0]].Stage; stage != "" {
+		slog.Info("verification stage started", "stage", stage, "commands", len(group), "workers", workers)
+	}
+
+	var outMu sync.Mutex
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				i := group[j]
+				prefix := fmt.Sprintf("[%d:%s] ", i+1, shortCommand(commands[i].Run))
+				stdout := &prefixWriter{mu: &outMu, w: os.Stdout, prefix: prefix}
+				stderr := &prefixWriter{mu: &outMu, w: os.Stderr, prefix: prefix}
+				res, ok := runOne(i, total, commands[i], opts, stdout, stderr)
+				stdout.Flush()
+				stderr.Flush()
+				if ok {
+					results[j] = &res
+				}
+			}
+		}()
+	}
+	for j := range group {
+		jobs <- j
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// runOne runs a command and, for test failures, its flaky-test reruns.
+func runOne(i, total int, c config.Command, opts Options, stdout, stderr io.Writer) (CommandResult, bool) {
+	policy := opts.Sandbox
+	policy.AllowNetwork = c.AllowNetwork
+	res, ok := runArgv(i, total, c.Run, strings.Fields(c.Run), policy, stdout, stderr)
+	if ok && !res.Passed && res.Kind == "test_failure" && opts.Retries > 0 {
+		res = rerunFailingTests(res, opts.Retries, policy, stdout, stderr)
+	}
+	return res, ok
+}
+
+func runArgv(i, total int, cmdStr string, parts []string, policy sandbox.Policy, stdout, stderr io.Writer) (res CommandResult, ok bool) {
+	if len(parts) == 0 {
+		return CommandResult{}, false
+	}
+
+	startedAt := time.Now()
+	slog.Info("verification command started", "index", i+1, "total", total, "command", cmdStr, "sandboxed", policy.Enabled)
+
+	var stdoutBuf bytes.Buffer
+	var stderrBuf bytes.Buffer
+
+	var runErr error
+	cmd, err := sandbox.Command(context.Background(), policy, "", parts)
+	if err != nil {
+		runErr = err
+		stderrBuf.WriteString(err.Error())
+	} else {
+		cmd.Stdout = sandbox.LimitOutput(policy, io.MultiWriter(stdout, &stdoutBuf))
+		cmd.Stderr = sandbox.LimitOutput(policy, io.MultiWriter(stderr, &stderrBuf))
+		runErr = cmd.Run()
+	}
+	dur := time.Since(startedAt)
+
+	res = CommandResult{
+		Index:      i + 1,
+		Total:      total,
+		Command:    cmdStr,
+		Stdout:     stdoutBuf.String(),
+		Stderr:     stderrBuf.String(),
+		DurationMS: dur.Milliseconds(),
+		Duration:   dur,
+		Passed:     runErr == nil,
+	}
 
-		cmd := exec.Command(parts[0], parts[1:]...)
+	if runErr == nil {
+		res.ExitCode = 0
+		slog.Info("verification command succeeded",
+			"index", i+1, "total", total, "command", cmdStr, "duration_ms", res.DurationMS)
+		return res, true
+	}
 
-		var stdoutBuf bytes.Buffer
-		var stderrBuf bytes.Buffer
-		cmd.Stdout = io.MultiWriter(os.Stdout, &stdoutBuf)
-		cmd.Stderr = io.MultiWriter(os.Stderr, &stderrBuf)
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		res.ExitCode = exitErr.ExitCode()
+	} else {
+		res.ExitCode = -1
+	}
+	res.Kind = ClassifyFailure(res)
+
+	slog.Error("verification command failed",
+		"index", i+1,
+		"total", total,
+		"command", cmdStr,
+		"duration_ms", res.DurationMS,
+		"exit_code", res.ExitCode,
+		"kind", res.Kind,
+		"error", runErr,
+	)
+	return res, true
+}
 
-		runErr := cmd.Run()
-		dur := time.Since(startedAt)
+// prefixWriter writes complete lines to w with a prefix, serialized by mu, so
+// output from concurrently running commands interleaves by line.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
 
-		res := CommandResult{
-			Index:      i + 1,
-			Total:      len(commands),
-			Command:    cmdStr,
-			Stdout:     stdoutBuf.String(),
-			Stderr:     stderrBuf.String(),
-			DurationMS: dur.Milliseconds(),
-			Duration:   dur,
-			Passed:     runErr == nil,
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
 		}
+		p.writeLine(p.buf[:i+1])
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
+
+// Flush writes any trailing partial line.
+func (p *prefixWriter) Flush() {
+	if len(p.buf) == 0 {
+		return
+	}
+	p.writeLine(append(p.buf, '\n'))
+	p.buf = nil
+}
+
+func (p *prefixWriter) writeLine(line []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, _ = io.WriteString(p.w, p.prefix)
+	_, _ = p.w.Write(line)
+}
+
+func shortCommand(cmdStr string) string {
+	const max = 32
+	if len(cmdStr) <= max {
+		return cmdStr
+	}
+	return cmdStr[:max-3] + "..."
+}
+
+// rerunFailingTests reruns only the failing tests of res (or the whole command when
+// they cannot be identified) up to retries times. If a rerun passes, the result is
+// marked flaky and passed; otherwise the original failure is returned.
+func rerunFailingTests(res CommandResult, retries int, policy sandbox.Policy, stdout, stderr io.Writer) CommandResult {
+	tests, pkgs := failingGoTests(res.Stdout + "\n" + res.Stderr)
+	argv := goTestRerunArgv(res.Command, tests, pkgs)
+	if argv == nil {
+		argv = strings.Fields(res.Command)
+		tests = []string{res.Command}
+	}
 
-		if runErr == nil {
+	for attempt := 1; attempt <= retries; attempt++ {
+		slog.Warn("rerunning failing tests",
+			"command", res.Command,
+			"attempt", attempt,
+			"retries", retries,
+			"tests", strings.Join(tests, ","),
+		)
+		rerun, _ := runArgv(res.Index-1, res.Total, strings.Join(argv, " "), argv, policy, stdout, stderr)
+		res.Reruns = attempt
+		if rerun.Passed {
+			slog.Warn("failing tests passed on rerun; marking flaky", "command", res.Command, "tests", strings.Join(tests, ","))
+			res.Passed = true
+			res.Flaky = true
+			res.FlakyTests = tests
+			res.Kind = ""
 			res.ExitCode = 0
-			slog.Info("verification command succeeded",
-				"index", i+1, "total", len(commands), "command", cmdStr, "duration_ms", res.DurationMS)
-			report.Commands = append(report.Commands, res)
-			continue
+			return res
 		}
+	}
+	return res
+}
+
+var (
+	goTestFailLine = regexp.MustCompile(`^\s*--- FAIL: (\S+)`)
+	goPkgFailLine  = regexp.MustCompile(`^FAIL\s+(\S+)\s`)
+)
 
-		var exitErr *exec.ExitError
-		if errors.As(runErr, &exitErr) {
-			res.ExitCode = exitErr.ExitCode()
-		} else {
-			res.ExitCode = -1
+// failingGoTests extracts top-level failing test names and failing packages from go test output.
+func failingGoTests(output string) (tests, pkgs []string) {
+	seenTests := make(map[string]bool)
+	seenPkgs := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		if m := goTestFailLine.FindStringSubmatch(line); m != nil {
+			name, _, _ := strings.Cut(m[1], "/")
+			if !seenTests[name] {
+				seenTests[name] = true
+				tests = append(tests, name)
+			}
+			continue
 		}
-		res.Kind = ClassifyFailure(res)
-
-		slog.Error("verification command failed",
-			"index", i+1,
-			"total", len(commands),
-			"command", cmdStr,
-			"duration_ms", res.DurationMS,
-			"exit_code", res.ExitCode,
-			"kind", res.Kind,
-			"error", runErr,
-		)
+		if m := goPkgFailLine.FindStringSubmatch(line + " "); m != nil {
+			if !seenPkgs[m[1]] {
+				seenPkgs[m[1]] = true
+				pkgs = append(pkgs, m[1])
+			}
+		}
+	}
+	return tests, pkgs
+}
+
+// goValueFlags are go test flags whose value may be passed as a separate argument.
+var goValueFlags = map[string]bool{
+	"-run": true, "-skip": true, "-count": true, "-tags": true, "-timeout": true,
+	"-p": true, "-parallel": true, "-cpu": true, "-bench": true, "-benchtime": true,
+	"-coverprofile": true, "-covermode": true, "-coverpkg": true, "-o": true,
+	"-exec": true, "-ldflags": true, "-gcflags": true, "-mod": true,
+}
 
-		report.Commands = append(report.Commands, res)
-		return report, &CommandFailureError{Result: res}
+// goTestRerunArgv builds a go test invocation that reruns only tests in pkgs.
+// It returns nil when cmdStr is not a go test command or no tests were identified.
+func goTestRerunArgv(cmdStr string, tests, pkgs []string) []string {
+	fields := strings.Fields(cmdStr)
+	if len(fields) < 2 || fields[0] != "go" || fields[1] != "test" || len(tests) == 0 {
+		return nil
 	}
 
-	return report, nil
+	argv := []string{"go", "test"}
+	var origPkgs []string
+	for i := 2; i < len(fields); i++ {
+		f := fields[i]
+		if !strings.HasPrefix(f, "-") {
+			origPkgs = append(origPkgs, f)
+			continue
+		}
+		name, _, hasValue := strings.Cut(f, "=")
+		name = "-" + strings.TrimLeft(name, "-")
+		skip := name == "-run" || name == "-count"
+		if !skip {
+			argv = append(argv, f)
+		}
+		if !hasValue && goValueFlags[name] && i+1 < len(fields) {
+			i++
+			if !skip {
+				argv = append(argv, fields[i])
+			}
+		}
+	}
+
+	argv = append(argv, "-count=1", "-run", "^("+strings.Join(tests, "|")+")$")
+	if len(pkgs) > 0 {
+		return append(argv, pkgs...)
+	}
+	return append(argv, origPkgs...)
 }
 
 // ClassifyFailure performs failure classification with strong Go coverage.
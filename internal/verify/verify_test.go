@@ -2,9 +2,14 @@ package verify
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/mmrzaf/evolver/internal/config"
 )
 
 func TestRunCommandsSuccess(t *testing.T) {
@@ -50,6 +55,175 @@ func TestRunCommandsReportFailureIncludesStructuredResult(t *testing.T) {
 	}
 }
 
+func TestRunContinueOnFailureRunsEveryCommand(t *testing.T) {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	fail := os.Args[0] + " -test.run=TestVerifyHelperProcess -- fail"
+	ok := os.Args[0] + " -test.run=TestVerifyHelperProcess -- ok"
+
+	report, err := Run(config.Commands(fail, ok), Options{ContinueOnFailure: true})
+	if err == nil {
+		t.Fatalf("expected failure")
+	}
+	if len(report.Commands) != 2 {
+		t.Fatalf("expected both commands to run, got %d", len(report.Commands))
+	}
+	if report.Commands[0].Passed || !report.Commands[1].Passed {
+		t.Fatalf("unexpected results: %#v", report.Commands)
+	}
+
+	report, _ = Run(config.Commands(fail, ok), Options{})
+	if len(report.Commands) != 1 {
+		t.Fatalf("expected run to stop at first failure, got %d results", len(report.Commands))
+	}
+}
+
+func TestGroupCommandsByStageAndParallel(t *testing.T) {
+	cmds := []config.Command{
+		{Run: "build"},
+		{Run: "vet", Stage: "static"},
+		{Run: "lint", Stage: "static"},
+		{Run: "unit", Parallel: true},
+		{Run: "integration", Parallel: true},
+		{Run: "e2e"},
+		{Run: "docs", Stage: "static"},
+	}
+	got := fmt.Sprint(groupCommands(cmds))
+	if want := "[[0] [1 2] [3 4] [5] [6]]"; got != want {
+		t.Fatalf("expected groups %s, got %s", want, got)
+	}
+}
+
+func TestRunParallelGroupPreservesOrderAndStopsAfterFailure(t *testing.T) {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	fail := os.Args[0] + " -test.run=TestVerifyHelperProcess -- fail"
+	ok := os.Args[0] + " -test.run=TestVerifyHelperProcess -- ok"
+	cmds := []config.Command{
+		{Run: ok, Stage: "checks"},
+		{Run: fail, Stage: "checks"},
+		{Run: ok + " ", Stage: "checks"},
+		{Run: ok},
+	}
+
+	report, err := Run(cmds, Options{MaxParallel: 2})
+	var cf *CommandFailureError
+	if !errors.As(err, &cf) || cf.Result.Index != 2 {
+		t.Fatalf("expected failure of command 2, got %v", err)
+	}
+	if len(report.Commands) != 3 {
+		t.Fatalf("expected the whole stage but not the next command to run, got %d results", len(report.Commands))
+	}
+	for i, res := range report.Commands {
+		if res.Index != i+1 {
+			t.Fatalf("expected ordered report, got index %d at position %d", res.Index, i)
+		}
+	}
+}
+
+func TestPrefixWriterPrefixesEachLine(t *testing.T) {
+	var mu sync.Mutex
+	var buf strings.Builder
+	w := &prefixWriter{mu: &mu, w: &buf, prefix: "[1:go vet] "}
+	_, _ = w.Write([]byte("one\ntw"))
+	_, _ = w.Write([]byte("o\nthree"))
+	w.Flush()
+	if got, want := buf.String(), "[1:go vet] one\n[1:go vet] two\n[1:go vet] three\n"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestReportNewFailuresIgnoresBaselineFailures(t *testing.T) {
+	baseline := &Report{Commands: []CommandResult{
+		{Command: "go test ./...", Passed: false, Kind: "test_failure"},
+		{Command: "go vet ./...", Passed: true},
+	}}
+
+	same := &Report{Commands: []CommandResult{
+		{Command: "go test ./...", Passed: false, Kind: "test_failure"},
+		{Command: "go vet ./...", Passed: true},
+	}}
+	if got := same.NewFailures(baseline); len(got) != 0 {
+		t.Fatalf("expected pre-existing failure to be ignored, got %#v", got)
+	}
+
+	worse := &Report{Commands: []CommandResult{
+		{Command: "go test ./...", Passed: false, Kind: "compile_failure"},
+		{Command: "go vet ./...", Passed: false, Kind: "vet_failure"},
+	}}
+	if got := worse.NewFailures(baseline); len(got) != 2 {
+		t.Fatalf("expected changed kind and newly failing command as regressions, got %#v", got)
+	}
+	if got := worse.NewFailures(nil); len(got) != 2 {
+		t.Fatalf("expected all failures to be new without a baseline, got %#v", got)
+	}
+}
+
+func TestReportNewFailuresComparesFailingGoTests(t *testing.T) {
+	const pkg = "FAIL\texample.com/mod/pkg\t0.012s\n"
+	baseline := &Report{Commands: []CommandResult{
+		{Command: "go test ./...", Kind: "test_failure", Stdout: "--- FAIL: TestA (0.00s)\n" + pkg},
+	}}
+
+	same := &Report{Commands: []CommandResult{
+		{Command: "go test ./...", Kind: "test_failure", Stdout: "--- FAIL: TestA (0.01s)\n" + pkg},
+	}}
+	if got := same.NewFailures(baseline); len(got) != 0 {
+		t.Fatalf("expected the baseline's failing test to be ignored, got %#v", got)
+	}
+
+	worse := &Report{Commands: []CommandResult{
+		{Command: "go test ./...", Kind: "test_failure", Stdout: "--- FAIL: TestA (0.00s)\n--- FAIL: TestB (0.00s)\n" + pkg},
+	}}
+	if got := worse.NewFailures(baseline); len(got) != 1 {
+		t.Fatalf("expected newly failing TestB to be a regression, got %#v", got)
+	}
+
+	otherPkg := &Report{Commands: []CommandResult{
+		{Command: "go test ./...", Kind: "test_failure", Stdout: "--- FAIL: TestA (0.00s)\n" + pkg + "FAIL\texample.com/mod/other [build failed]\n"},
+	}}
+	if got := otherPkg.NewFailures(baseline); len(got) != 1 {
+		t.Fatalf("expected newly failing package to be a regression, got %#v", got)
+	}
+}
+
+func TestFailingGoTestsAndRerunArgv(t *testing.T) {
+	out := "--- FAIL: TestAlpha (0.00s)\n    --- FAIL: TestAlpha/sub (0.00s)\n--- FAIL: TestBeta (0.01s)\nFAIL\nFAIL\texample.com/mod/pkg\t0.012s\n"
+	tests, pkgs := failingGoTests(out)
+	if len(tests) != 2 || tests[0] != "TestAlpha" || tests[1] != "TestBeta" {
+		t.Fatalf("unexpected tests: %#v", tests)
+	}
+	if len(pkgs) != 1 || pkgs[0] != "example.com/mod/pkg" {
+		t.Fatalf("unexpected packages: %#v", pkgs)
+	}
+
+	got := strings.Join(goTestRerunArgv("go test -race -run Old -tags integration ./...", tests, pkgs), " ")
+	want := "go test -race -tags integration -count=1 -run ^(TestAlpha|TestBeta)$ example.com/mod/pkg"
+	if got != want {
+		t.Fatalf("unexpected rerun argv:\n got %q\nwant %q", got, want)
+	}
+	if argv := goTestRerunArgv("npm test", tests, pkgs); argv != nil {
+		t.Fatalf("expected nil argv for non-go command, got %#v", argv)
+	}
+}
+
+func TestRunRetriesMarksFlakyWhenRerunPasses(t *testing.T) {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	marker := filepath.Join(t.TempDir(), "ran-once")
+	t.Setenv("VERIFY_FLAKY_MARKER", marker)
+	cmd := os.Args[0] + " -test.run=TestVerifyHelperProcess -- flaky"
+
+	report, err := Run(config.Commands(cmd), Options{Retries: 1})
+	if err != nil {
+		t.Fatalf("expected flaky command to pass after rerun: %v", err)
+	}
+	res := report.Commands[0]
+	if !res.Passed || !res.Flaky || res.Reruns != 1 {
+		t.Fatalf("expected flaky passing result, got %#v", res)
+	}
+	if got := report.FlakyTests(); len(got) != 1 || got[0] != cmd {
+		t.Fatalf("unexpected flaky tests: %#v", got)
+	}
+}
+
 func TestInferCommandsByProjectType(t *testing.T) {
 	tmp := t.TempDir()
 	wd, err := os.Getwd()
@@ -118,6 +292,15 @@ func TestVerifyHelperProcess(t *testing.T) {
 				os.Exit(0)
 			case "fail":
 				os.Exit(1)
+			case "flaky":
+				// Fails like a go test run the first time, passes afterwards.
+				marker := os.Getenv("VERIFY_FLAKY_MARKER")
+				if _, err := os.Stat(marker); err != nil {
+					_ = os.WriteFile(marker, []byte("1"), 0644)
+					fmt.Println("panic: flaky")
+					os.Exit(1)
+				}
+				os.Exit(0)
 			}
 		}
 	}